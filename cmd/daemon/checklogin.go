@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/config"
+	"github.com/florian/screentime-guardian/internal/notifier"
+	"github.com/florian/screentime-guardian/internal/rules"
+	"github.com/florian/screentime-guardian/internal/scheduler"
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+// checkLogin implements "screentime-guardian check-login [user]", meant to
+// run from pam_exec before a login. It reads the daemon's database directly
+// and exits 1 with the reason on stdout, which pam_exec shows at the login
+// screen, when the user may not log in now. The user defaults to PAM_USER.
+// The database is opened read-only and never migrated, since the daemon
+// owns it. Errors allow the login, so a broken install never locks the
+// parents out, except for a child whose degraded mode is "closed" when
+// their record can still be read. An unreadable database allows everyone.
+func checkLogin(args []string) int {
+	flags := flag.NewFlagSet("check-login", flag.ExitOnError)
+	configPath := flags.String("config", defaultConfigPath, "Path to config file")
+	flags.Parse(args)
+
+	log.SetFlags(0)
+	log.SetPrefix("screentime-guardian: ")
+
+	username := flags.Arg(0)
+	if username == "" {
+		username = os.Getenv("PAM_USER")
+	}
+	if username == "" {
+		log.Print("usage: screentime-guardian check-login [-config path] <user>")
+		return 2
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		cfg = config.Default()
+	}
+
+	store, err := storage.OpenReadOnly(cfg.DatabasePath)
+	if err != nil {
+		log.Printf("Allowing login of %s: %v", username, err)
+		return 0
+	}
+	defer store.Close()
+
+	sched := scheduler.New(store, nil, notifier.NewChain(), cfg)
+	if cfg.RulesFile != "" {
+		if ruleset, err := rules.Load(cfg.RulesFile); err != nil {
+			log.Printf("Ignoring rules: %v", err)
+		} else {
+			sched.SetRules(rules.NewEngine(ruleset))
+		}
+	}
+
+	sessionType := scheduler.PAMSessionType(os.Getenv("PAM_SERVICE"), os.Getenv("PAM_TTY"))
+	allowed, reason, err := sched.CheckLogin(username, sessionType, time.Now())
+	if err != nil {
+		log.Printf("Allowing login of %s: %v", username, err)
+		return 0
+	}
+	if !allowed {
+		fmt.Println(reason)
+		return 1
+	}
+	return 0
+}
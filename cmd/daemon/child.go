@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/config"
+)
+
+// childClient talks to the daemon's child socket, which knows the caller
+// from the socket itself, so children need no password
+type childClient struct {
+	http *http.Client
+}
+
+func newChildClient(socket string) *childClient {
+	return &childClient{http: &http.Client{
+		Timeout: 15 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	}}
+}
+
+// call sends body as JSON and decodes the answer into out, turning the
+// daemon's {"error": ...} answers into errors
+func (c *childClient) call(method, path string, body, out interface{}) error {
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, "http://screentime-guardian"+path, &reader)
+	if err != nil {
+		return err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("is screentime-guardian running? %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&failure); err != nil || failure.Error == "" {
+			return fmt.Errorf("request failed: %s", resp.Status)
+		}
+		return errors.New(failure.Error)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// requestTime implements "screentime-guardian request <minutes> [reason]",
+// run by a child to ask the parents for more time
+func requestTime(args []string) int {
+	flags := flag.NewFlagSet("request", flag.ExitOnError)
+	socket := flags.String("socket", config.DefaultChildSocket, "Path to the daemon's child socket")
+	flags.Parse(args)
+
+	log.SetFlags(0)
+	log.SetPrefix("screentime-guardian: ")
+
+	minutes, err := strconv.Atoi(flags.Arg(0))
+	if err != nil || minutes <= 0 {
+		log.Print("usage: screentime-guardian request [-socket path] <minutes> [reason]")
+		return 2
+	}
+	reason := strings.Join(flags.Args()[1:], " ")
+
+	body := map[string]interface{}{"minutes": minutes, "reason": reason}
+	if err := newChildClient(*socket).call(http.MethodPost, "/requests", body, nil); err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	fmt.Printf("Asked for %d more minutes. You will get a notification when a parent answers.\n", minutes)
+	return 0
+}
+
+// listChores implements "screentime-guardian chores", which shows a child
+// their chores and the IDs to report them with
+func listChores(args []string) int {
+	flags := flag.NewFlagSet("chores", flag.ExitOnError)
+	socket := flags.String("socket", config.DefaultChildSocket, "Path to the daemon's child socket")
+	flags.Parse(args)
+
+	log.SetFlags(0)
+	log.SetPrefix("screentime-guardian: ")
+
+	var chores []struct {
+		ID            int64
+		Name          string
+		MinutesReward int
+	}
+	if err := newChildClient(*socket).call(http.MethodGet, "/chores", nil, &chores); err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	if len(chores) == 0 {
+		fmt.Println("No chores set up yet.")
+		return 0
+	}
+	for _, chore := range chores {
+		fmt.Printf("%4d  %-30s +%d min\n", chore.ID, chore.Name, chore.MinutesReward)
+	}
+	fmt.Println("\nReport one with: screentime-guardian chore-done <id>")
+	return 0
+}
+
+// choreDone implements "screentime-guardian chore-done <id>", run by a
+// child to report a chore for a parent to approve
+func choreDone(args []string) int {
+	flags := flag.NewFlagSet("chore-done", flag.ExitOnError)
+	socket := flags.String("socket", config.DefaultChildSocket, "Path to the daemon's child socket")
+	flags.Parse(args)
+
+	log.SetFlags(0)
+	log.SetPrefix("screentime-guardian: ")
+
+	id, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+	if err != nil || flags.NArg() != 1 {
+		log.Print("usage: screentime-guardian chore-done [-socket path] <chore id>")
+		return 2
+	}
+
+	var completion struct {
+		ChoreName string
+		Minutes   int
+	}
+	if err := newChildClient(*socket).call(http.MethodPost, fmt.Sprintf("/chores/%d/complete", id), nil, &completion); err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	fmt.Printf("Reported %q. You get %d minutes once a parent approves it.\n", completion.ChoreName, completion.Minutes)
+	return 0
+}
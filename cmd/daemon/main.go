@@ -11,18 +11,37 @@ import (
 	"time"
 
 	"github.com/florian/screentime-guardian/internal/api"
+	"github.com/florian/screentime-guardian/internal/calendar"
 	"github.com/florian/screentime-guardian/internal/config"
 	"github.com/florian/screentime-guardian/internal/dbus"
 	"github.com/florian/screentime-guardian/internal/mdns"
 	"github.com/florian/screentime-guardian/internal/notifier"
+	"github.com/florian/screentime-guardian/internal/rules"
 	"github.com/florian/screentime-guardian/internal/scheduler"
 	"github.com/florian/screentime-guardian/internal/storage"
 )
 
 var Version = "dev"
 
+const defaultConfigPath = "/etc/screentime-guardian/config.yaml"
+
 func main() {
-	configPath := flag.String("config", "/etc/screentime-guardian/config.yaml", "Path to config file")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check-login":
+			os.Exit(checkLogin(os.Args[2:]))
+		case "simulate":
+			os.Exit(simulateDay(os.Args[2:]))
+		case "request":
+			os.Exit(requestTime(os.Args[2:]))
+		case "chores":
+			os.Exit(listChores(os.Args[2:]))
+		case "chore-done":
+			os.Exit(choreDone(os.Args[2:]))
+		}
+	}
+
+	configPath := flag.String("config", defaultConfigPath, "Path to config file")
 	flag.Parse()
 
 	log.Printf("Screentime Guardian Daemon %s starting...", Version)
@@ -34,6 +53,10 @@ func main() {
 		cfg = config.Default()
 	}
 
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
 	// Initialize storage
 	store, err := storage.New(cfg.DatabasePath)
 	if err != nil {
@@ -54,8 +77,9 @@ func main() {
 		dbusNotifier = nil
 	}
 
-	// Create notifier chain (allows adding Telegram later)
-	var notifiers []notifier.Notifier
+	// Create notifier chain (allows adding Telegram later). The log notifier
+	// is always there, so parent alerts reach the journal at least.
+	notifiers := []notifier.Notifier{notifier.NewLogNotifier()}
 	if dbusNotifier != nil {
 		notifiers = append(notifiers, notifier.NewDBusNotifier(dbusNotifier))
 	}
@@ -63,8 +87,26 @@ func main() {
 
 	// Initialize scheduler
 	sched := scheduler.New(store, logindClient, notifierChain, cfg)
+
+	// Load declarative rules; a broken rules file is fatal rather than
+	// silently unenforced
+	if cfg.RulesFile != "" {
+		ruleset, err := rules.Load(cfg.RulesFile)
+		if err != nil {
+			log.Fatalf("Failed to load rules: %v", err)
+		}
+		sched.SetRules(rules.NewEngine(ruleset))
+		log.Printf("Loaded %d rule(s) from %s", len(ruleset.Rules), cfg.RulesFile)
+	}
+
 	go sched.Run(context.Background())
 
+	// Watch the school holiday calendar, if configured
+	if cfg.HolidayCalendarFile != "" {
+		watcher := calendar.NewWatcher(store, cfg.HolidayCalendarFile, cfg.HolidayLimitMins, time.Minute)
+		go watcher.Run(context.Background())
+	}
+
 	// Start mDNS advertisement
 	mdnsService, err := mdns.Start(context.Background(), cfg.ListenAddr)
 	if err != nil {
@@ -82,6 +124,23 @@ func main() {
 		WriteTimeout: 15 * time.Second,
 	}
 
+	// Let children ask for time without the admin password
+	var childServer *http.Server
+	if cfg.ChildSocket != "" {
+		listener, err := api.ListenChildSocket(cfg.ChildSocket)
+		if err != nil {
+			log.Printf("Warning: Child socket unavailable: %v", err)
+		} else {
+			childServer = api.NewChildServer(api.NewChildHandler(store, notifierChain, cfg))
+			go func() {
+				if err := childServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+					log.Printf("Child socket error: %v", err)
+				}
+			}()
+			log.Printf("Child requests accepted on %s", cfg.ChildSocket)
+		}
+	}
+
 	// Graceful shutdown
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGTERM)
@@ -115,6 +174,9 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		log.Printf("HTTP server shutdown error: %v", err)
 	}
+	if childServer != nil {
+		childServer.Shutdown(ctx)
+	}
 
 	log.Println("Shutdown complete")
 }
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+
+	"github.com/florian/screentime-guardian/internal/config"
+	"github.com/florian/screentime-guardian/internal/simulate"
+)
+
+// simulateDay implements "screentime-guardian simulate <script>". It replays
+// a scripted day against a throwaway database with the warning and
+// enforcement settings of the config file and prints what would happen.
+// The daemon's own database is never touched.
+func simulateDay(args []string) int {
+	flags := flag.NewFlagSet("simulate", flag.ExitOnError)
+	configPath := flags.String("config", defaultConfigPath, "Path to config file")
+	verbose := flags.Bool("v", false, "Show the scheduler's log")
+	flags.Parse(args)
+
+	log.SetFlags(0)
+	log.SetPrefix("screentime-guardian: ")
+
+	if flags.NArg() != 1 {
+		log.Print("usage: screentime-guardian simulate [-config path] [-v] <script.yaml>")
+		return 2
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Printf("Using the default settings: %v", err)
+		cfg = config.Default()
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Printf("Invalid configuration: %v", err)
+		return 1
+	}
+
+	script, err := simulate.Load(flags.Arg(0))
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+	if err := simulate.Run(context.Background(), script, cfg, os.Stdout); err != nil {
+		log.SetOutput(os.Stderr)
+		log.Printf("Simulation failed: %v", err)
+		return 1
+	}
+	return 0
+}
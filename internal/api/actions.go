@@ -0,0 +1,117 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+// parseRunAt accepts a clock time (HH:MM, meaning today), the value of a
+// datetime-local input (YYYY-MM-DDTHH:MM) or a full RFC 3339 timestamp
+func parseRunAt(value string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02T15:04", value, now.Location()); err == nil {
+		return t, nil
+	}
+	return parseValidUntil(value, now)
+}
+
+func (s *Server) apiListScheduledActions(w http.ResponseWriter, r *http.Request) {
+	var userID int64
+	if idStr := r.URL.Query().Get("user_id"); idStr != "" {
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			jsonError(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		userID = id
+	}
+
+	actions, err := s.store.ListScheduledActions(userID, time.Now().AddDate(0, 0, -7))
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, actions)
+}
+
+func (s *Server) apiScheduleAction(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Action  string `json:"action"`
+		RunAt   string `json:"run_at"`
+		Minutes int    `json:"minutes"`
+		Message string `json:"message"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	runAt, err := parseRunAt(req.RunAt, time.Now())
+	if err != nil {
+		jsonError(w, "Run at must be HH:MM, YYYY-MM-DDTHH:MM or RFC 3339", http.StatusBadRequest)
+		return
+	}
+	if !runAt.After(time.Now()) {
+		jsonError(w, "Run at must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	if err := storage.ValidateScheduledAction(req.Action, req.Minutes, req.Message); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.store.GetUserByID(id)
+	if err != nil || user == nil {
+		jsonError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	action, err := s.store.ScheduleAction(id, req.Action, runAt, req.Minutes, req.Message, adminName(r))
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, action)
+}
+
+func (s *Server) apiCancelScheduledAction(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid action ID", http.StatusBadRequest)
+		return
+	}
+
+	action, err := s.store.GetScheduledAction(id)
+	if err != nil || action == nil {
+		jsonError(w, "Scheduled action not found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.store.CancelScheduledAction(id); errors.Is(err, storage.ErrActionNotPending) {
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, map[string]string{"status": "cancelled"})
+}
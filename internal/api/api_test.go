@@ -1,21 +1,31 @@
 package api
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"html/template"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/florian/screentime-guardian/internal/calendar"
 	"github.com/florian/screentime-guardian/internal/config"
+	"github.com/florian/screentime-guardian/internal/dbus"
 	"github.com/florian/screentime-guardian/internal/notifier"
 	"github.com/florian/screentime-guardian/internal/storage"
 )
 
 // TestTemplatesParsing tests that templates can be parsed with required functions
 func TestTemplatesParsing(t *testing.T) {
-	// Templates need divf function for division
+	// Templates need divf and mins functions for division
 	funcMap := template.FuncMap{
 		"divf": func(a, b float64) float64 {
 			if b == 0 {
@@ -23,6 +33,9 @@ func TestTemplatesParsing(t *testing.T) {
 			}
 			return a / b
 		},
+		"mins": func(seconds int) int {
+			return seconds / 60
+		},
 	}
 
 	tmpl := template.New("").Funcs(funcMap)
@@ -166,3 +179,1105 @@ func TestStaticFiles(t *testing.T) {
 		t.Errorf("Static file handler returned unexpected status: %d", w.Code)
 	}
 }
+
+func TestTimeRequestFlow(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = ""
+
+	mock := notifier.NewMockNotifier()
+	router := NewRouter(store, nil, notifier.NewChain(mock), cfg)
+
+	user, _ := store.CreateUser("testuser", 60)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/users/"+strconv.FormatInt(user.ID, 10)+"/requests",
+		strings.NewReader(`{"minutes": 30, "reason": "finish my game"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 creating request, got %d: %s", w.Code, w.Body.String())
+	}
+
+	pending, _ := store.ListPendingTimeRequests()
+	if len(pending) != 1 {
+		t.Fatalf("Expected 1 pending request, got %d", len(pending))
+	}
+
+	// Pending requests are shown on the dashboard
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(w.Body.String(), "finish my game") {
+		t.Error("Expected dashboard to list the pending request")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/requests/"+strconv.FormatInt(pending[0].ID, 10)+"/approve",
+		strings.NewReader(`{"minutes": 10}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 approving request, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if len(mock.DecisionCalls) != 1 || mock.DecisionCalls[0].Minutes != 10 {
+		t.Errorf("Expected child to be told about 10 approved minutes, got %v", mock.DecisionCalls)
+	}
+
+	remaining, _ := store.GetRemainingMinutes(user.ID)
+	if remaining != 70 {
+		t.Errorf("Expected 70 minutes remaining after partial approval, got %d", remaining)
+	}
+}
+
+func TestExtendWithReasonAndRevoke(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = "secret"
+
+	router := NewRouter(store, nil, notifier.NewChain(notifier.NewMockNotifier()), cfg)
+
+	user, _ := store.CreateUser("testuser", 60)
+	userPath := "/api/users/" + strconv.FormatInt(user.ID, 10)
+
+	req := httptest.NewRequest(http.MethodPost, userPath+"/extend",
+		strings.NewReader(`{"minutes": 30, "reason": "school project"}`))
+	req.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 extending time, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"remaining_secs":5400`) {
+		t.Errorf("Expected remaining time in seconds, got %s", w.Body.String())
+	}
+
+	extensions, _ := store.ListTodayExtensions(user.ID)
+	if len(extensions) != 1 || extensions[0].GrantedBy != "admin" || extensions[0].Reason != "school project" {
+		t.Fatalf("Expected extension granted by admin with reason, got %+v", extensions)
+	}
+
+	// The detail page lists today's extensions
+	req = httptest.NewRequest(http.MethodGet, "/users/"+strconv.FormatInt(user.ID, 10), nil)
+	req.SetBasicAuth("admin", "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), "school project") {
+		t.Error("Expected user detail page to list the extension")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/extensions/"+strconv.FormatInt(extensions[0].ID, 10)+"/revoke", nil)
+	req.SetBasicAuth("admin", "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 revoking extension, got %d: %s", w.Code, w.Body.String())
+	}
+
+	remaining, _ := store.GetRemainingMinutes(user.ID)
+	if remaining != 60 {
+		t.Errorf("Expected 60 minutes after revocation, got %d", remaining)
+	}
+}
+
+func TestParseValidUntil(t *testing.T) {
+	now := time.Date(2024, 3, 1, 14, 0, 0, 0, time.Local)
+
+	got, err := parseValidUntil("18:00", now)
+	if err != nil {
+		t.Fatalf("parseValidUntil failed: %v", err)
+	}
+	if want := time.Date(2024, 3, 1, 18, 0, 0, 0, time.Local); !got.Equal(want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if _, err := parseValidUntil("tonight", now); err == nil {
+		t.Error("Expected error for unparseable time")
+	}
+}
+
+func TestDeductTime(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = "secret"
+
+	router := NewRouter(store, nil, notifier.NewChain(notifier.NewMockNotifier()), cfg)
+
+	user, _ := store.CreateUser("testuser", 60)
+	userPath := "/api/users/" + strconv.FormatInt(user.ID, 10)
+
+	deduct := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path+"/deduct", strings.NewReader(body))
+		req.SetBasicAuth("admin", "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := deduct(userPath, `{"minutes": 10, "reason": "homework not done"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 deducting time, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"remaining_secs":3000`) {
+		t.Errorf("Expected 50 minutes remaining, got %s", w.Body.String())
+	}
+
+	deductions, _ := store.ListDeductions(user.ID, time.Now().Format("2006-01-02"))
+	if len(deductions) != 1 || deductions[0].Minutes != -10 || deductions[0].GrantedBy != "admin" || deductions[0].Reason != "homework not done" {
+		t.Fatalf("Expected a 10 minute deduction by admin with reason, got %+v", deductions)
+	}
+
+	// A deduction for tomorrow leaves today alone
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	w = deduct(userPath, `{"minutes": 15, "reason": "late to bed", "date": "`+tomorrow+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 deducting tomorrow's time, got %d: %s", w.Code, w.Body.String())
+	}
+	if remaining, _ := store.GetRemainingMinutes(user.ID); remaining != 50 {
+		t.Errorf("Expected tomorrow's deduction not to count today, got %d minutes remaining", remaining)
+	}
+
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	for _, body := range []string{
+		`{"minutes": 0, "reason": "nothing"}`,
+		`{"minutes": -5, "reason": "gives time back"}`,
+		`{"minutes": 5}`,
+		`{"minutes": 5, "reason": "too late", "date": "` + yesterday + `"}`,
+		`{"minutes": 5, "reason": "bad date", "date": "tomorrow"}`,
+	} {
+		if w := deduct(userPath, body); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, w.Code)
+		}
+	}
+
+	if w := deduct("/api/users/9999", `{"minutes": 5, "reason": "who?"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown user, got %d", w.Code)
+	}
+
+	if remaining, _ := store.GetRemainingMinutes(user.ID); remaining != 50 {
+		t.Errorf("Expected rejected deductions not to count, got %d minutes remaining", remaining)
+	}
+}
+
+func TestCreateOverride(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = ""
+	router := NewRouter(store, nil, notifier.NewChain(), cfg)
+
+	user, _ := store.CreateUser("testuser", 60)
+	today := time.Now().Format("2006-01-02")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/overrides",
+		strings.NewReader(`{"start_date": "`+today+`", "limit_mins": 0, "label": "Grounded"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 creating override, got %d: %s", w.Code, w.Body.String())
+	}
+
+	remaining, _ := store.GetRemainingMinutes(user.ID)
+	if remaining != 0 {
+		t.Errorf("Expected household grounding to leave 0 minutes, got %d", remaining)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/overrides",
+		strings.NewReader(`{"start_date": "2024-12-31", "end_date": "2024-12-23"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for reversed date range, got %d", w.Code)
+	}
+
+	for _, path := range []string{"/", "/settings", "/users/" + strconv.FormatInt(user.ID, 10)} {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if !strings.Contains(w.Body.String(), "Grounded") {
+			t.Errorf("Expected %s to show the override", path)
+		}
+	}
+}
+
+func TestUserScheduleFeed(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = ""
+	router := NewRouter(store, nil, notifier.NewChain(), cfg)
+
+	user, _ := store.CreateUser("testuser", 90)
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	store.CreateDateOverride(&storage.DateOverride{
+		UserID:    user.ID,
+		StartDate: tomorrow,
+		EndDate:   tomorrow,
+		Unlimited: true,
+		Label:     "Birthday",
+	})
+
+	path := "/api/users/" + strconv.FormatInt(user.ID, 10) + "/schedule.ics?weeks=1"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/calendar") {
+		t.Errorf("Expected text/calendar content type, got %q", ct)
+	}
+
+	// The feed must round-trip through the parser
+	events, err := calendar.Parse(w.Body)
+	if err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+	if len(events) != 7 {
+		t.Fatalf("Expected 7 daily events, got %d", len(events))
+	}
+	if events[0].Summary != "testuser: 90 min screen time" {
+		t.Errorf("Unexpected summary for today: %q", events[0].Summary)
+	}
+	if events[1].StartDate != tomorrow || events[1].Summary != "testuser: unlimited screen time" || events[1].Description != "Birthday" {
+		t.Errorf("Unexpected event for override: %+v", events[1])
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/users/999/schedule.ics", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown user, got %d", w.Code)
+	}
+}
+
+func TestProfileSwitching(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = ""
+	router := NewRouter(store, nil, notifier.NewChain(), cfg)
+
+	user, _ := store.CreateUser("testuser", 60)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/profiles", strings.NewReader(
+		`{"name": "Holidays", "weekday_limit_mins": 240, "weekend_limit_mins": 240, "windows": "09:00-21:00"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 creating profile, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/profiles", strings.NewReader(
+		`{"name": "Broken", "windows": "21:00-09:00"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for reversed window, got %d", w.Code)
+	}
+
+	profiles, _ := store.ListProfiles()
+	if len(profiles) != 1 {
+		t.Fatalf("Expected 1 profile, got %d", len(profiles))
+	}
+
+	userPath := "/api/users/" + strconv.FormatInt(user.ID, 10) + "/profile"
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	req = httptest.NewRequest(http.MethodPost, userPath, strings.NewReader(
+		`{"profile_id": `+strconv.FormatInt(profiles[0].ID, 10)+`, "until": "`+yesterday+`"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for past revert date, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, userPath, strings.NewReader(
+		`{"profile_id": `+strconv.FormatInt(profiles[0].ID, 10)+`}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 switching profile, got %d: %s", w.Code, w.Body.String())
+	}
+
+	remaining, _ := store.GetRemainingMinutes(user.ID)
+	if remaining != 240 {
+		t.Errorf("Expected profile limit of 240 minutes, got %d", remaining)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(w.Body.String(), "Holidays") {
+		t.Error("Expected dashboard to show the active profile")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, userPath, strings.NewReader(`{"profile_id": 0}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	remaining, _ = store.GetRemainingMinutes(user.ID)
+	if remaining != 60 {
+		t.Errorf("Expected own limit after clearing profile, got %d", remaining)
+	}
+}
+
+func TestCreateUserFromTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = ""
+	router := NewRouter(store, nil, notifier.NewChain(), cfg)
+
+	templates, _ := store.ListTemplates()
+	teen := templates[len(templates)-1]
+
+	req := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(
+		`{"username": "alice", "template_id": `+strconv.FormatInt(teen.ID, 10)+`}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 creating user, got %d: %s", w.Code, w.Body.String())
+	}
+
+	user, _ := store.GetUserByUsername("alice")
+	assignment, _ := store.GetUserProfile(user.ID)
+	if assignment == nil || assignment.ProfileName != "alice" {
+		t.Errorf("Expected alice to be on her own profile, got %+v", assignment)
+	}
+
+	// A profile already called like the new user gets a name of its own
+	store.CreateProfile(&storage.Profile{Name: "carol", WeekdayLimitMins: 60, WeekendLimitMins: 60})
+	req = httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(
+		`{"username": "carol", "template_id": `+strconv.FormatInt(teen.ID, 10)+`}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 creating a user named like a profile, got %d: %s", w.Code, w.Body.String())
+	}
+	carol, _ := store.GetUserByUsername("carol")
+	if assignment, _ := store.GetUserProfile(carol.ID); assignment == nil || assignment.ProfileName != "carol ("+teen.Name+")" {
+		t.Errorf("Expected carol on a profile named after her and the template, got %+v", assignment)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(
+		`{"username": "bob", "template_id": 999}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown template, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/templates/"+strconv.FormatInt(teen.ID, 10), nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 deleting a built-in template, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if !strings.Contains(w.Body.String(), teen.Name) {
+		t.Error("Expected users page to list templates")
+	}
+}
+
+func TestPauseUsageCounter(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = ""
+	mock := notifier.NewMockNotifier()
+	router := NewRouter(store, nil, notifier.NewChain(mock), cfg)
+
+	user, _ := store.CreateUser("testuser", 60)
+	userPath := "/api/users/" + strconv.FormatInt(user.ID, 10)
+
+	req := httptest.NewRequest(http.MethodPost, userPath+"/pause", strings.NewReader(
+		`{"minutes": 30, "reason": "online lesson"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 pausing, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(mock.MessageCalls) != 1 || mock.MessageCalls[0].Username != "testuser" {
+		t.Errorf("Expected the user to be told about the pause, got %+v", mock.MessageCalls)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, userPath+"/pause", strings.NewReader(`{"minutes": 0}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 when already paused, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(w.Body.String(), "online lesson") {
+		t.Error("Expected dashboard to show the pause banner")
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, userPath+"/resume", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 resuming, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, userPath+"/resume", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 when not paused, got %d", w.Code)
+	}
+}
+
+func TestCorrectUsage(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = ""
+	router := NewRouter(store, nil, notifier.NewChain(), cfg)
+
+	user, _ := store.CreateUser("testuser", 120)
+	store.AddUsageTime(user.ID, 100*60)
+	userPath := "/api/users/" + strconv.FormatInt(user.ID, 10)
+
+	req := httptest.NewRequest(http.MethodPost, userPath+"/usage", strings.NewReader(`{"adjust_mins": -90}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without a note, got %d", w.Code)
+	}
+
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	req = httptest.NewRequest(http.MethodPost, userPath+"/usage", strings.NewReader(
+		`{"date": "`+tomorrow+`", "used_mins": 10, "note": "typo"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a future date, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, userPath+"/usage", strings.NewReader(
+		`{"adjust_mins": -90, "note": "watched a film together"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 correcting usage, got %d: %s", w.Code, w.Body.String())
+	}
+
+	used, _ := store.GetTodayUsageSeconds(user.ID)
+	if used != 10*60 {
+		t.Errorf("Expected 10 minutes used after correction, got %d seconds", used)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/"+strconv.FormatInt(user.ID, 10), nil))
+	body := w.Body.String()
+	if !strings.Contains(body, "watched a film together") || !strings.Contains(body, "100 min") {
+		t.Error("Expected user page to show the correction with the original value")
+	}
+}
+
+func TestScheduleAction(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = ""
+	router := NewRouter(store, nil, notifier.NewChain(), cfg)
+
+	user, _ := store.CreateUser("testuser", 60)
+	actionsPath := "/api/users/" + strconv.FormatInt(user.ID, 10) + "/actions"
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02") + "T16:00"
+
+	req := httptest.NewRequest(http.MethodPost, actionsPath, strings.NewReader(
+		`{"action": "extend", "run_at": "`+tomorrow+`"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for extend without minutes, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, actionsPath, strings.NewReader(
+		`{"action": "extend", "run_at": "`+tomorrow+`", "minutes": 30}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 scheduling action, got %d: %s", w.Code, w.Body.String())
+	}
+
+	actions, _ := store.ListScheduledActions(user.ID, time.Now())
+	if len(actions) != 1 || actions[0].RunAt.Local().Format("2006-01-02T15:04") != tomorrow {
+		t.Fatalf("Expected one action tomorrow at 16:00, got %+v", actions)
+	}
+
+	cancelPath := "/api/actions/" + strconv.FormatInt(actions[0].ID, 10)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, cancelPath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 cancelling action, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, cancelPath, nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 cancelling twice, got %d", w.Code)
+	}
+}
+
+func TestHouseholdBudget(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = ""
+	router := NewRouter(store, nil, notifier.NewChain(), cfg)
+
+	user, _ := store.CreateUser("testuser", 180)
+	store.AddUsageTime(user.ID, 100*60)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/household/budget", strings.NewReader(`{"minutes": -5}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for negative budget, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/household/budget", strings.NewReader(`{"minutes": 300}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 setting budget, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/household", nil))
+	if !strings.Contains(w.Body.String(), `"remaining_mins":200`) {
+		t.Errorf("Expected 200 minutes left in the budget, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(w.Body.String(), "used 100 of 300 minutes") {
+		t.Error("Expected dashboard to show the household budget")
+	}
+}
+
+func TestSetDegradedMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = ""
+	router := NewRouter(store, nil, notifier.NewChain(), cfg)
+
+	user, _ := store.CreateUser("testuser", 60)
+	path := "/api/users/" + strconv.FormatInt(user.ID, 10) + "/degraded-mode"
+
+	req := httptest.NewRequest(http.MethodPut, path, strings.NewReader(`{"mode": "closed"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 setting degraded mode, got %d: %s", w.Code, w.Body.String())
+	}
+	if updated, _ := store.GetUserByID(user.ID); updated.DegradedMode != storage.DegradedClosed {
+		t.Errorf("Expected degraded mode %q, got %q", storage.DegradedClosed, updated.DegradedMode)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, path, strings.NewReader(`{"mode": "sometimes"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown mode, got %d", w.Code)
+	}
+}
+
+func TestLockFailures(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = ""
+	router := NewRouter(store, nil, notifier.NewChain(), cfg)
+
+	user, _ := store.CreateUser("testuser", 60)
+	failure, _ := store.RecordLockFailure(user.ID, "The screen of testuser could not be locked")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/lock-failures", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "could not be locked") {
+		t.Errorf("Expected the lock failure to be listed, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/health", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `"lock_failures":1`) {
+		t.Errorf("Expected health to count the lock failure, got %s", w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), "Lock failed for testuser") {
+		t.Error("Expected the dashboard to show the lock failure")
+	}
+
+	path := "/api/lock-failures/" + strconv.FormatInt(failure.ID, 10) + "/acknowledge"
+	req = httptest.NewRequest(http.MethodPost, path, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 acknowledging, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, path, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 acknowledging twice, got %d", w.Code)
+	}
+
+	if failures, _ := store.ListLockFailures(); len(failures) != 0 {
+		t.Errorf("Expected no failures left, got %+v", failures)
+	}
+}
+
+func TestSessionsThroughMock(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = ""
+	logind := dbus.NewMockLogindClient()
+	logind.Sessions = []dbus.Session{{ID: "7", UserName: "testuser"}}
+	router := NewRouter(store, logind, notifier.NewChain(), cfg)
+
+	user, _ := store.CreateUser("testuser", 60)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for status, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"is_logged_in":true`) {
+		t.Errorf("Expected testuser to be logged in, got %s", w.Body.String())
+	}
+
+	path := "/api/users/" + strconv.FormatInt(user.ID, 10) + "/lock"
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 locking, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(logind.LockedSessions) != 1 || logind.LockedSessions[0] != "7" {
+		t.Errorf("Expected session 7 to be locked, got %v", logind.LockedSessions)
+	}
+
+	// A logind outage shows on the health endpoint
+	logind.ShouldError = true
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+	if !strings.Contains(w.Body.String(), `"healthy":false`) {
+		t.Errorf("Expected logind to be reported unhealthy, got %s", w.Body.String())
+	}
+}
+
+// serveChildSocket serves the child handler on a temporary socket as if
+// every caller were username, and returns a client connected to it
+func serveChildSocket(t *testing.T, store storage.Store, cfg *config.Config, username string) *http.Client {
+	t.Helper()
+
+	lookup := lookupUsername
+	lookupUsername = func(uid uint32) (string, error) { return username, nil }
+	t.Cleanup(func() { lookupUsername = lookup })
+
+	socket := filepath.Join(t.TempDir(), "child.sock")
+	listener, err := ListenChildSocket(socket)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := NewChildServer(NewChildHandler(store, notifier.NewChain(), cfg))
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+}
+
+func TestChildSocketTimeRequest(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials need Linux")
+	}
+
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = "secret"
+
+	user, _ := store.CreateUser("kid", 60)
+
+	// The web API wants the parent's password
+	router := NewRouter(store, nil, notifier.NewChain(), cfg)
+	req := httptest.NewRequest(http.MethodPost, "/api/users/"+strconv.FormatInt(user.ID, 10)+"/requests",
+		strings.NewReader(`{"minutes": 30}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401 from the web API without auth, got %d", w.Code)
+	}
+
+	// The child socket does not
+	client := serveChildSocket(t, store, cfg, "kid")
+	resp, err := client.Post("http://child/requests", "application/json", strings.NewReader(`{"minutes": 30, "reason": "homework"}`))
+	if err != nil {
+		t.Fatalf("Failed to submit: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected 200 from the child socket, got %d", resp.StatusCode)
+	}
+
+	pending, _ := store.ListPendingTimeRequests()
+	if len(pending) != 1 || pending[0].UserID != user.ID || pending[0].Reason != "homework" {
+		t.Fatalf("Expected kid's pending request, got %+v", pending)
+	}
+
+	// Users the daemon does not manage are turned away
+	client = serveChildSocket(t, store, cfg, "stranger")
+	resp, err = client.Post("http://child/requests", "application/json", strings.NewReader(`{"minutes": 30}`))
+	if err != nil {
+		t.Fatalf("Failed to submit: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for an unmanaged user, got %d", resp.StatusCode)
+	}
+}
+
+func TestChildSocketChores(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("peer credentials need Linux")
+	}
+
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = "secret"
+
+	kid, _ := store.CreateUser("kid", 60)
+	sibling, _ := store.CreateUser("sibling", 60)
+	dishes, _ := store.CreateChore(kid.ID, "Dishes", 15, 1)
+	laundry, _ := store.CreateChore(sibling.ID, "Laundry", 20, 1)
+
+	client := serveChildSocket(t, store, cfg, "kid")
+
+	resp, err := client.Get("http://child/chores")
+	if err != nil {
+		t.Fatalf("Failed to list chores: %v", err)
+	}
+	var chores []storage.Chore
+	json.NewDecoder(resp.Body).Decode(&chores)
+	resp.Body.Close()
+	if len(chores) != 1 || chores[0].ID != dishes.ID {
+		t.Errorf("Expected only kid's chore, got %+v", chores)
+	}
+
+	post := func(chore *storage.Chore) int {
+		resp, err := client.Post("http://child/chores/"+strconv.FormatInt(chore.ID, 10)+"/complete", "application/json", nil)
+		if err != nil {
+			t.Fatalf("Failed to complete chore: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := post(dishes); code != http.StatusOK {
+		t.Fatalf("Expected 200 completing own chore, got %d", code)
+	}
+	if code := post(laundry); code != http.StatusNotFound {
+		t.Errorf("Expected 404 completing a sibling's chore, got %d", code)
+	}
+
+	pending, _ := store.ListPendingChoreCompletions()
+	if len(pending) != 1 || pending[0].ChoreID != dishes.ID {
+		t.Errorf("Expected the dishes to await approval, got %+v", pending)
+	}
+}
+
+func TestChoreFlow(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = ""
+
+	mock := notifier.NewMockNotifier()
+	router := NewRouter(store, nil, notifier.NewChain(mock), cfg)
+
+	user, _ := store.CreateUser("testuser", 60)
+	userPath := "/api/users/" + strconv.FormatInt(user.ID, 10)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		return w
+	}
+
+	// A missing cap means once a day, like the column default
+	w := post(userPath+"/chores", `{"name": "Dishes", "minutes_reward": 15}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 creating chore, got %d: %s", w.Code, w.Body.String())
+	}
+	var dishes storage.Chore
+	json.NewDecoder(w.Body).Decode(&dishes)
+	if dishes.DailyCap != storage.DefaultChoreDailyCap {
+		t.Errorf("Expected the default daily cap, got %d", dishes.DailyCap)
+	}
+
+	w = post(userPath+"/chores", `{"name": "Reading", "minutes_reward": 10, "daily_cap": 0}`)
+	var reading storage.Chore
+	json.NewDecoder(w.Body).Decode(&reading)
+	if w.Code != http.StatusOK || reading.DailyCap != 0 {
+		t.Errorf("Expected an unlimited chore, got %d: %+v", w.Code, reading)
+	}
+
+	for _, body := range []string{`{"minutes_reward": 15}`, `{"name": "Dishes", "minutes_reward": 0}`, `{"name": "Dishes", "minutes_reward": 15, "daily_cap": -1}`} {
+		if w := post(userPath+"/chores", body); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, w.Code)
+		}
+	}
+
+	// Complete, then the cap stops a second completion
+	dishesPath := "/api/chores/" + strconv.FormatInt(dishes.ID, 10) + "/complete"
+	w = post(dishesPath, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 completing chore, got %d: %s", w.Code, w.Body.String())
+	}
+	var completion storage.ChoreCompletion
+	json.NewDecoder(w.Body).Decode(&completion)
+	if w := post(dishesPath, ""); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 over the daily cap, got %d", w.Code)
+	}
+	if w := post("/api/chores/999/complete", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown chore, got %d", w.Code)
+	}
+
+	// Approving grants the reward and tells the child
+	approvePath := "/api/chore-completions/" + strconv.FormatInt(completion.ID, 10) + "/approve"
+	if w := post(approvePath, ""); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 approving chore, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(mock.ExtensionCalls) != 1 || mock.ExtensionCalls[0].Minutes != 15 {
+		t.Errorf("Expected child to be told about 15 minutes, got %v", mock.ExtensionCalls)
+	}
+	if remaining, _ := store.GetRemainingMinutes(user.ID); remaining != 75 {
+		t.Errorf("Expected 75 minutes remaining after approval, got %d", remaining)
+	}
+	if w := post(approvePath, ""); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 approving twice, got %d", w.Code)
+	}
+
+	// Rejecting grants nothing
+	w = post("/api/chores/"+strconv.FormatInt(reading.ID, 10)+"/complete", "")
+	json.NewDecoder(w.Body).Decode(&completion)
+	rejectPath := "/api/chore-completions/" + strconv.FormatInt(completion.ID, 10) + "/reject"
+	if w := post(rejectPath, ""); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 rejecting chore, got %d: %s", w.Code, w.Body.String())
+	}
+	if remaining, _ := store.GetRemainingMinutes(user.ID); remaining != 75 {
+		t.Errorf("Expected rejection to grant nothing, got %d minutes remaining", remaining)
+	}
+	if w := post(rejectPath, ""); w.Code != http.StatusConflict {
+		t.Errorf("Expected 409 rejecting twice, got %d", w.Code)
+	}
+}
+
+func TestApproveTimeRequestAmounts(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = ""
+	router := NewRouter(store, nil, notifier.NewChain(), cfg)
+
+	user, _ := store.CreateUser("testuser", 60)
+	timeRequest, _ := store.CreateTimeRequest(user.ID, 30, "", time.Hour)
+	approvePath := "/api/requests/" + strconv.FormatInt(timeRequest.ID, 10) + "/approve"
+
+	// A typo must not hand out the full amount
+	for _, body := range []string{`{"minutes": -5}`, `{"minutes": 0}`, `{"minutes": 31}`} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, approvePath, strings.NewReader(body)))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", body, w.Code)
+		}
+	}
+	if pending, _ := store.ListPendingTimeRequests(); len(pending) != 1 {
+		t.Fatalf("Expected the request to stay pending, got %d pending", len(pending))
+	}
+
+	// Omitting the minutes approves the full amount
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, approvePath, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 approving in full, got %d: %s", w.Code, w.Body.String())
+	}
+	if remaining, _ := store.GetRemainingMinutes(user.ID); remaining != 90 {
+		t.Errorf("Expected 90 minutes remaining after full approval, got %d", remaining)
+	}
+}
+
+func TestImportHolidaysRejectsBadUploads(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.AdminPassword = ""
+	router := NewRouter(store, nil, notifier.NewChain(), cfg)
+
+	upload := func(content string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", "holidays.ics")
+		part.Write([]byte(content))
+		form.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/holidays/import", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	calendar := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20241028\r\nDTEND;VALUE=DATE:20241102\r\nSUMMARY:Autumn\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	if w := upload(calendar); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 importing a calendar, got %d: %s", w.Code, w.Body.String())
+	}
+
+	for name, content := range map[string]string{
+		"garbage": "name,date\nAutumn,2024-10-28\n",
+		"empty":   "",
+	} {
+		if w := upload(content); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 uploading %s, got %d", name, w.Code)
+		}
+	}
+
+	imported, _ := store.ListDateOverridesBySource(storage.OverrideSourceUpload)
+	if len(imported) != 1 {
+		t.Errorf("Expected the earlier import to survive bad uploads, got %d ranges", len(imported))
+	}
+}
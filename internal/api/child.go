@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/florian/screentime-guardian/internal/config"
+	"github.com/florian/screentime-guardian/internal/notifier"
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+type childContextKey int
+
+const (
+	childNameKey childContextKey = iota // Linux username of the peer
+	childUserKey                        // *storage.User of the peer
+)
+
+// lookupUsername maps a peer's UID to its Linux username
+var lookupUsername = func(uid uint32) (string, error) {
+	u, err := user.LookupId(strconv.FormatUint(uint64(uid), 10))
+	if err != nil {
+		return "", err
+	}
+	return u.Username, nil
+}
+
+// NewChildHandler creates the router for the local child socket. There is
+// no password: the caller is the Linux user on the other end of the socket,
+// so a child can only ask for time and report chores for themselves.
+func NewChildHandler(store storage.Store, notifier *notifier.Chain, cfg *config.Config) http.Handler {
+	s := &Server{
+		store:    store,
+		notifier: notifier,
+		config:   cfg,
+	}
+
+	r := chi.NewRouter()
+	r.Use(middleware.Recoverer)
+	r.Use(s.requireChild)
+
+	r.Post("/requests", s.childCreateTimeRequest)
+	r.Get("/chores", s.childListChores)
+	r.Post("/chores/{id}/complete", s.childCompleteChore)
+
+	return r
+}
+
+// ListenChildSocket creates the child socket at path, replacing one left
+// behind by a previous run. Anyone may connect; requests are only accepted
+// from managed users.
+func ListenChildSocket(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0666); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to open up %s: %w", path, err)
+	}
+
+	return listener, nil
+}
+
+// NewChildServer creates the HTTP server for the child socket, which tags
+// every connection with the peer's username
+func NewChildServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:      handler,
+		ConnContext:  childConnContext,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+	}
+}
+
+func childConnContext(ctx context.Context, conn net.Conn) context.Context {
+	uid, err := peerUID(conn)
+	if err != nil {
+		log.Printf("Child socket: %v", err)
+		return ctx
+	}
+
+	username, err := lookupUsername(uid)
+	if err != nil {
+		log.Printf("Child socket: unknown UID %d: %v", uid, err)
+		return ctx
+	}
+
+	return context.WithValue(ctx, childNameKey, username)
+}
+
+// requireChild rejects callers that are not managed users
+func (s *Server) requireChild(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, _ := r.Context().Value(childNameKey).(string)
+		if username == "" {
+			jsonError(w, "Could not identify the caller", http.StatusForbidden)
+			return
+		}
+
+		user, err := s.store.GetUserByUsername(username)
+		if err != nil || user == nil {
+			jsonError(w, fmt.Sprintf("%s is not managed by Screentime Guardian", username), http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), childUserKey, user)))
+	})
+}
+
+// child returns the caller authenticated by requireChild
+func child(r *http.Request) *storage.User {
+	return r.Context().Value(childUserKey).(*storage.User)
+}
+
+func (s *Server) childCreateTimeRequest(w http.ResponseWriter, r *http.Request) {
+	s.submitTimeRequest(w, r, child(r))
+}
+
+func (s *Server) childListChores(w http.ResponseWriter, r *http.Request) {
+	chores, err := s.store.ListChores(child(r).ID)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, chores)
+}
+
+func (s *Server) childCompleteChore(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid chore ID", http.StatusBadRequest)
+		return
+	}
+
+	// Another child's chore is reported as missing rather than forbidden
+	chore, err := s.store.GetChore(id)
+	if err != nil || chore == nil || chore.UserID != child(r).ID {
+		jsonError(w, "Chore not found", http.StatusNotFound)
+		return
+	}
+
+	s.submitChoreCompletion(w, chore)
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+func (s *Server) apiListChores(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	chores, err := s.store.ListChores(id)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, chores)
+}
+
+func (s *Server) apiCreateChore(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Name          string `json:"name"`
+		MinutesReward int    `json:"minutes_reward"`
+		DailyCap      *int   `json:"daily_cap"` // omitted means the default cap, 0 unlimited
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" {
+		jsonError(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	if req.MinutesReward <= 0 {
+		jsonError(w, "Minutes reward must be positive", http.StatusBadRequest)
+		return
+	}
+
+	dailyCap := storage.DefaultChoreDailyCap
+	if req.DailyCap != nil {
+		dailyCap = *req.DailyCap
+	}
+	if dailyCap < 0 {
+		jsonError(w, "Daily cap cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.store.GetUserByID(id)
+	if err != nil || user == nil {
+		jsonError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	chore, err := s.store.CreateChore(id, req.Name, req.MinutesReward, dailyCap)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, chore)
+}
+
+func (s *Server) apiDeleteChore(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid chore ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.DeleteChore(id); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, map[string]string{"status": "deleted"})
+}
+
+func (s *Server) apiCompleteChore(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid chore ID", http.StatusBadRequest)
+		return
+	}
+
+	chore, err := s.store.GetChore(id)
+	if err != nil || chore == nil {
+		jsonError(w, "Chore not found", http.StatusNotFound)
+		return
+	}
+
+	s.submitChoreCompletion(w, chore)
+}
+
+// submitChoreCompletion queues the chore for a parent's review. Parents use
+// it through the web API and children through the local socket.
+func (s *Server) submitChoreCompletion(w http.ResponseWriter, chore *storage.Chore) {
+	completion, err := s.store.SubmitChoreCompletion(chore.ID)
+	if errors.Is(err, storage.ErrChoreCapReached) {
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, completion)
+}
+
+func (s *Server) apiApproveChore(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid completion ID", http.StatusBadRequest)
+		return
+	}
+
+	completion, err := s.store.ApproveChoreCompletion(id)
+	if errors.Is(err, storage.ErrCompletionNotPending) || errors.Is(err, storage.ErrChoreCapReached) {
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Notify the user
+	ctx := context.Background()
+	s.notifier.SendTimeExtended(ctx, completion.Username, completion.Minutes)
+
+	remaining, _ := s.store.GetRemainingSeconds(completion.UserID)
+	jsonResponse(w, map[string]interface{}{
+		"status":         "approved",
+		"minutes_added":  completion.Minutes,
+		"remaining_secs": remaining,
+		"remaining_mins": storage.MinutesRoundedUp(remaining),
+	})
+}
+
+func (s *Server) apiRejectChore(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid completion ID", http.StatusBadRequest)
+		return
+	}
+
+	err = s.store.RejectChoreCompletion(id)
+	if errors.Is(err, storage.ErrCompletionNotPending) {
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, map[string]string{"status": "rejected"})
+}
@@ -3,12 +3,15 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/florian/screentime-guardian/internal/calendar"
+	"github.com/florian/screentime-guardian/internal/clock"
 	"github.com/florian/screentime-guardian/internal/dbus"
 	"github.com/florian/screentime-guardian/internal/storage"
 )
@@ -46,7 +49,13 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 		UsedMins       int
 		DailyLimitMins int
 		ExtensionMins  int
+		DeductionMins  int
+		Unlimited      bool
+		OverrideLabel  string
+		Profile        *storage.ProfileAssignment
+		Pause          *storage.Pause
 		Enabled        bool
+		DegradedMode   string
 		PercentUsed    int
 	}
 
@@ -55,10 +64,25 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 		remaining, _ := s.store.GetRemainingMinutes(user.ID)
 		usedSecs, _ := s.store.GetTodayUsageSeconds(user.ID)
 		extensions, _ := s.store.GetTodayExtensions(user.ID)
+		deductions, _ := s.store.GetTodayDeductions(user.ID)
+
+		limit := user.DailyLimitMins
+		unlimited := false
+		overrideLabel := ""
+		if policy, err := s.store.GetTodayPolicy(user.ID); err == nil {
+			limit = policy.LimitMins
+			unlimited = policy.Unlimited
+			if policy.Override != nil {
+				overrideLabel = policy.Override.Label
+			}
+		}
 
-		totalLimit := user.DailyLimitMins + extensions
+		assignment, _ := s.store.GetUserProfile(user.ID)
+		pause, _ := s.store.GetActivePause(user.ID, time.Now())
+
+		totalLimit := limit + extensions - deductions
 		percentUsed := 0
-		if totalLimit > 0 {
+		if totalLimit > 0 && !unlimited {
 			percentUsed = (usedSecs / 60) * 100 / totalLimit
 			if percentUsed > 100 {
 				percentUsed = 100
@@ -71,18 +95,43 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
 			IsLoggedIn:     loggedIn[user.Username],
 			RemainingMins:  remaining,
 			UsedMins:       usedSecs / 60,
-			DailyLimitMins: user.DailyLimitMins,
+			DailyLimitMins: limit,
 			ExtensionMins:  extensions,
+			DeductionMins:  deductions,
+			Unlimited:      unlimited,
+			OverrideLabel:  overrideLabel,
+			Profile:        assignment,
+			Pause:          pause,
 			Enabled:        user.Enabled,
+			DegradedMode:   user.DegradedMode,
 			PercentUsed:    percentUsed,
 		})
 	}
 
+	pendingChores, _ := s.store.ListPendingChoreCompletions()
+	pendingRequests, _ := s.store.ListPendingTimeRequests()
+	profiles, _ := s.store.ListProfiles()
+	budget, _ := s.store.GetHouseholdBudget()
+	lockFailures, _ := s.store.ListLockFailures()
+
+	var health *dbus.Health
+	if s.logind != nil {
+		h := s.logind.Health()
+		health = &h
+	}
+
 	data := map[string]interface{}{
-		"Title":      "Dashboard",
-		"Users":      userData,
-		"Now":        time.Now().Format("15:04"),
-		"NeedsSetup": s.config.AdminPassword == "",
+		"Title":           "Dashboard",
+		"Budget":          budget,
+		"Health":          health,
+		"LockFailures":    lockFailures,
+		"Users":           userData,
+		"PendingChores":   pendingChores,
+		"PendingRequests": pendingRequests,
+		"Profiles":        profiles,
+		"Tomorrow":        time.Now().AddDate(0, 0, 1).Format("2006-01-02"),
+		"Now":             time.Now().Format("15:04"),
+		"NeedsSetup":      s.config.AdminPassword == "",
 	}
 
 	s.tmpl.ExecuteTemplate(w, "dashboard.html", data)
@@ -95,9 +144,14 @@ func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	templates, _ := s.store.ListTemplates()
+	profiles, _ := s.store.ListProfiles()
+
 	data := map[string]interface{}{
-		"Title": "Manage Users",
-		"Users": users,
+		"Title":     "Manage Users",
+		"Users":     users,
+		"Templates": templates,
+		"Profiles":  profiles,
 	}
 
 	s.tmpl.ExecuteTemplate(w, "users.html", data)
@@ -125,14 +179,33 @@ func (s *Server) handleUserDetail(w http.ResponseWriter, r *http.Request) {
 	remaining, _ := s.store.GetRemainingMinutes(id)
 	usedSecs, _ := s.store.GetTodayUsageSeconds(id)
 	extensions, _ := s.store.GetTodayExtensions(id)
+	deductions, _ := s.store.GetTodayDeductions(id)
+	chores, _ := s.store.ListChores(id)
+	todayExtensions, _ := s.store.ListTodayExtensions(id)
+	upcomingDeductions, _ := s.store.ListDeductions(id, time.Now().Format("2006-01-02"))
+	overrides, _ := s.store.ListUserDateOverrides(id, time.Now().Format("2006-01-02"))
+	policy, _ := s.store.GetTodayPolicy(id)
+	pauses, _ := s.store.ListPauses(id, 20)
+	corrections, _ := s.store.ListUsageCorrections(id, time.Now().AddDate(0, 0, -7).Format("2006-01-02"))
+	actions, _ := s.store.ListScheduledActions(id, time.Now().AddDate(0, 0, -1))
 
 	data := map[string]interface{}{
 		"Title":         user.Username,
 		"User":          user,
 		"History":       history,
+		"Chores":        chores,
+		"Extensions":    todayExtensions,
+		"Deductions":    upcomingDeductions,
+		"Overrides":     overrides,
+		"Policy":        policy,
+		"Pauses":        pauses,
+		"Corrections":   corrections,
+		"Actions":       actions,
 		"RemainingMins": remaining,
 		"UsedMins":      usedSecs / 60,
 		"ExtensionMins": extensions,
+		"DeductionMins": deductions,
+		"Today":         time.Now().Format("2006-01-02"),
 	}
 
 	s.tmpl.ExecuteTemplate(w, "user_detail.html", data)
@@ -168,9 +241,30 @@ func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
+	users, _ := s.store.ListUsers()
+	overrides, _ := s.store.ListDateOverrides(time.Now().Format("2006-01-02"))
+
+	var holidays []*storage.DateOverride
+	for _, source := range []string{storage.OverrideSourceCalendar, storage.OverrideSourceUpload} {
+		imported, _ := s.store.ListDateOverridesBySource(source)
+		holidays = append(holidays, imported...)
+	}
+	profiles, _ := s.store.ListProfiles()
+	lastImport, _ := s.store.GetSetting(calendar.SettingLastImport)
+	importError, _ := s.store.GetSetting(calendar.SettingLastImportError)
+	budgetMins, _ := s.store.GetHouseholdBudgetMins()
+
 	data := map[string]interface{}{
-		"Title":  "Settings",
-		"Config": s.config,
+		"Title":              "Settings",
+		"Config":             s.config,
+		"Users":              users,
+		"Overrides":          overrides,
+		"Today":              time.Now().Format("2006-01-02"),
+		"Holidays":           holidays,
+		"Profiles":           profiles,
+		"CalendarLastImport": lastImport,
+		"CalendarError":      importError,
+		"BudgetMins":         budgetMins,
 	}
 
 	s.tmpl.ExecuteTemplate(w, "settings.html", data)
@@ -210,28 +304,58 @@ func (s *Server) apiGetStatus(w http.ResponseWriter, r *http.Request) {
 	type Status struct {
 		Username      string `json:"username"`
 		IsLoggedIn    bool   `json:"is_logged_in"`
+		RemainingSecs int    `json:"remaining_secs"`
 		RemainingMins int    `json:"remaining_mins"`
 		UsedMins      int    `json:"used_mins"`
 		LimitMins     int    `json:"limit_mins"`
 		ExtensionMins int    `json:"extension_mins"`
+		DeductionMins int    `json:"deduction_mins"`
+		Unlimited     bool   `json:"unlimited"`
+		Override      string `json:"override,omitempty"`
+		Profile       string `json:"profile,omitempty"`
+		Paused        bool   `json:"paused"`
+		PausedUntil   string `json:"paused_until,omitempty"`
 		Enabled       bool   `json:"enabled"`
 	}
 
 	var statuses []Status
 	for _, user := range users {
-		remaining, _ := s.store.GetRemainingMinutes(user.ID)
+		remaining, _ := s.store.GetRemainingSeconds(user.ID)
 		usedSecs, _ := s.store.GetTodayUsageSeconds(user.ID)
 		extensions, _ := s.store.GetTodayExtensions(user.ID)
+		deductions, _ := s.store.GetTodayDeductions(user.ID)
 
-		statuses = append(statuses, Status{
+		status := Status{
 			Username:      user.Username,
 			IsLoggedIn:    loggedIn[user.Username],
-			RemainingMins: remaining,
+			RemainingSecs: remaining,
+			RemainingMins: storage.MinutesRoundedUp(remaining),
 			UsedMins:      usedSecs / 60,
 			LimitMins:     user.DailyLimitMins,
 			ExtensionMins: extensions,
+			DeductionMins: deductions,
 			Enabled:       user.Enabled,
-		})
+		}
+
+		if policy, err := s.store.GetTodayPolicy(user.ID); err == nil {
+			status.LimitMins = policy.LimitMins
+			status.Unlimited = policy.Unlimited
+			if policy.Override != nil {
+				status.Override = policy.Override.Label
+			}
+			if policy.Profile != nil {
+				status.Profile = policy.Profile.Name
+			}
+		}
+
+		if pause, _ := s.store.GetActivePause(user.ID, time.Now()); pause != nil {
+			status.Paused = true
+			if pause.EndsAt != nil {
+				status.PausedUntil = pause.EndsAt.Local().Format(time.RFC3339)
+			}
+		}
+
+		statuses = append(statuses, status)
 	}
 
 	jsonResponse(w, statuses)
@@ -241,6 +365,7 @@ func (s *Server) apiCreateUser(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Username       string `json:"username"`
 		DailyLimitMins int    `json:"daily_limit_mins"`
+		TemplateID     int64  `json:"template_id"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -253,6 +378,25 @@ func (s *Server) apiCreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A template sets the limit, windows and warnings through a profile of
+	// the user's own
+	if req.TemplateID != 0 {
+		template, err := s.store.GetTemplate(req.TemplateID)
+		if err != nil || template == nil {
+			jsonError(w, "Template not found", http.StatusNotFound)
+			return
+		}
+
+		user, err := s.store.CreateUserFromTemplate(req.Username, req.TemplateID)
+		if err != nil {
+			jsonError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		jsonResponse(w, user)
+		return
+	}
+
 	if req.DailyLimitMins <= 0 {
 		req.DailyLimitMins = 120 // Default 2 hours
 	}
@@ -292,6 +436,43 @@ func (s *Server) apiUpdateUser(w http.ResponseWriter, r *http.Request) {
 	jsonResponse(w, map[string]string{"status": "updated"})
 }
 
+// apiSetDegradedMode sets what happens to a child while logind or the
+// database cannot be reached: open, closed or count
+func (s *Server) apiSetDegradedMode(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Mode string `json:"mode"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.store.GetUserByID(id)
+	if err != nil || user == nil {
+		jsonError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.store.SetUserDegradedMode(id, req.Mode); err != nil {
+		if errors.Is(err, storage.ErrInvalidDegradedMode) {
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, map[string]string{"status": "updated", "mode": req.Mode})
+}
+
 func (s *Server) apiDeleteUser(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -317,7 +498,9 @@ func (s *Server) apiExtendTime(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Minutes int `json:"minutes"`
+		Minutes    int    `json:"minutes"`
+		Reason     string `json:"reason"`
+		ValidUntil string `json:"valid_until"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -330,13 +513,27 @@ func (s *Server) apiExtendTime(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var validUntil *time.Time
+	if req.ValidUntil != "" {
+		t, err := parseValidUntil(req.ValidUntil, time.Now())
+		if err != nil {
+			jsonError(w, "Valid until must be HH:MM or RFC 3339", http.StatusBadRequest)
+			return
+		}
+		if !t.After(time.Now()) {
+			jsonError(w, "Valid until must be in the future", http.StatusBadRequest)
+			return
+		}
+		validUntil = &t
+	}
+
 	user, err := s.store.GetUserByID(id)
 	if err != nil || user == nil {
 		jsonError(w, "User not found", http.StatusNotFound)
 		return
 	}
 
-	if err := s.store.AddTimeExtension(id, req.Minutes, "parent"); err != nil {
+	if _, err := s.store.GrantTimeExtension(id, req.Minutes, adminName(r), req.Reason, validUntil); err != nil {
 		jsonError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -345,11 +542,224 @@ func (s *Server) apiExtendTime(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
 	s.notifier.SendTimeExtended(ctx, user.Username, req.Minutes)
 
-	remaining, _ := s.store.GetRemainingMinutes(id)
+	remaining, _ := s.store.GetRemainingSeconds(id)
 	jsonResponse(w, map[string]interface{}{
 		"status":         "extended",
 		"minutes_added":  req.Minutes,
-		"remaining_mins": remaining,
+		"remaining_secs": remaining,
+		"remaining_mins": storage.MinutesRoundedUp(remaining),
+	})
+}
+
+func (s *Server) apiDeductTime(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Minutes int    `json:"minutes"`
+		Reason  string `json:"reason"`
+		Date    string `json:"date"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Minutes <= 0 {
+		jsonError(w, "Minutes must be positive", http.StatusBadRequest)
+		return
+	}
+
+	if req.Reason == "" {
+		jsonError(w, "Reason is required", http.StatusBadRequest)
+		return
+	}
+
+	today := time.Now().Format("2006-01-02")
+	if req.Date == "" {
+		req.Date = today
+	}
+
+	if _, err := time.Parse("2006-01-02", req.Date); err != nil {
+		jsonError(w, "Date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	if req.Date < today {
+		jsonError(w, "Date cannot be in the past", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.store.GetUserByID(id)
+	if err != nil || user == nil {
+		jsonError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.store.AddTimeDeduction(id, req.Minutes, req.Reason, req.Date, adminName(r)); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	remaining, _ := s.store.GetRemainingSeconds(id)
+	jsonResponse(w, map[string]interface{}{
+		"status":          "deducted",
+		"minutes_removed": req.Minutes,
+		"date":            req.Date,
+		"remaining_secs":  remaining,
+		"remaining_mins":  storage.MinutesRoundedUp(remaining),
+	})
+}
+
+func (s *Server) apiCorrectUsage(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	// Either used_mins sets the day's usage or adjust_mins changes it
+	var req struct {
+		Date       string `json:"date"`
+		UsedMins   *int   `json:"used_mins"`
+		AdjustMins int    `json:"adjust_mins"`
+		Note       string `json:"note"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Note == "" {
+		jsonError(w, "Note is required", http.StatusBadRequest)
+		return
+	}
+
+	if req.UsedMins == nil && req.AdjustMins == 0 {
+		jsonError(w, "Either used_mins or adjust_mins is required", http.StatusBadRequest)
+		return
+	}
+
+	if req.UsedMins != nil && (*req.UsedMins < 0 || *req.UsedMins > 1440) {
+		jsonError(w, "Used minutes must be between 0 and 1440", http.StatusBadRequest)
+		return
+	}
+
+	today := time.Now().Format("2006-01-02")
+	if req.Date == "" {
+		req.Date = today
+	}
+
+	if _, err := time.Parse("2006-01-02", req.Date); err != nil {
+		jsonError(w, "Date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	if req.Date > today {
+		jsonError(w, "Date cannot be in the future", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.store.GetUserByID(id)
+	if err != nil || user == nil {
+		jsonError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	var correction *storage.UsageCorrection
+	if req.UsedMins != nil {
+		correction, err = s.store.SetUsageSeconds(id, req.Date, *req.UsedMins*60, req.Note, adminName(r))
+	} else {
+		correction, err = s.store.AdjustUsageSeconds(id, req.Date, req.AdjustMins*60, req.Note, adminName(r))
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, map[string]interface{}{
+		"status":       "corrected",
+		"date":         correction.Date,
+		"old_seconds":  correction.OldSeconds,
+		"used_seconds": correction.NewSeconds,
+	})
+}
+
+func (s *Server) apiListUsageCorrections(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	from := r.URL.Query().Get("from")
+	if from == "" {
+		from = time.Now().AddDate(0, 0, -30).Format("2006-01-02")
+	}
+
+	corrections, err := s.store.ListUsageCorrections(id, from)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, corrections)
+}
+
+func (s *Server) apiListExtensions(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	extensions, err := s.store.ListTodayExtensions(id)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, extensions)
+}
+
+func (s *Server) apiRevokeExtension(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid extension ID", http.StatusBadRequest)
+		return
+	}
+
+	ext, err := s.store.GetTimeExtension(id)
+	if err != nil || ext == nil {
+		jsonError(w, "Extension not found", http.StatusNotFound)
+		return
+	}
+
+	err = s.store.RevokeTimeExtension(id, adminName(r))
+	if errors.Is(err, storage.ErrExtensionRevoked) {
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	remaining, _ := s.store.GetRemainingSeconds(ext.UserID)
+	jsonResponse(w, map[string]interface{}{
+		"status":         "revoked",
+		"remaining_secs": remaining,
+		"remaining_mins": storage.MinutesRoundedUp(remaining),
 	})
 }
 
@@ -399,6 +809,24 @@ func (s *Server) apiUnlockUser(w http.ResponseWriter, r *http.Request) {
 
 // --- Helpers ---
 
+// adminName returns the name the request was authenticated with, used to
+// record who granted or revoked time
+func adminName(r *http.Request) string {
+	if user, _, ok := r.BasicAuth(); ok && user != "" {
+		return user
+	}
+	return "parent"
+}
+
+// parseValidUntil accepts either a clock time (HH:MM, meaning today) or a
+// full RFC 3339 timestamp
+func parseValidUntil(value string, now time.Time) (time.Time, error) {
+	if mins, err := clock.Parse(value); err == nil {
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, mins, 0, 0, now.Location()), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
 func jsonResponse(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(data)
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+func (s *Server) apiGetHealth(w http.ResponseWriter, r *http.Request) {
+	// Locks that failed count as unhealthy until a parent acknowledges them
+	failures, _ := s.store.ListLockFailures()
+
+	if s.logind == nil {
+		jsonResponse(w, map[string]interface{}{
+			"logind":        map[string]interface{}{"healthy": false, "error": "logind is not available"},
+			"lock_failures": len(failures),
+		})
+		return
+	}
+
+	health := s.logind.Health()
+	logind := map[string]interface{}{
+		"healthy": health.Healthy,
+		"since":   health.Since.Format(time.RFC3339),
+	}
+	if health.LastError != "" {
+		logind["last_error"] = health.LastError
+	}
+	if !health.Healthy && !health.RetryAt.IsZero() {
+		logind["retry_at"] = health.RetryAt.Format(time.RFC3339)
+	}
+
+	jsonResponse(w, map[string]interface{}{"logind": logind, "lock_failures": len(failures)})
+}
+
+func (s *Server) apiListLockFailures(w http.ResponseWriter, r *http.Request) {
+	failures, err := s.store.ListLockFailures()
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, failures)
+}
+
+func (s *Server) apiAcknowledgeLockFailure(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid lock failure ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.AcknowledgeLockFailure(id); errors.Is(err, storage.ErrLockFailureAcknowledged) {
+		jsonError(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, map[string]string{"status": "acknowledged"})
+}
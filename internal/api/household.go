@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+func (s *Server) apiGetHousehold(w http.ResponseWriter, r *http.Request) {
+	budget, err := s.store.GetHouseholdBudget()
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if budget == nil {
+		jsonResponse(w, map[string]interface{}{"budget_mins": 0})
+		return
+	}
+
+	jsonResponse(w, map[string]interface{}{
+		"budget_mins":    budget.LimitMins,
+		"used_mins":      budget.UsedMins(),
+		"remaining_secs": budget.RemainingSeconds,
+		"remaining_mins": budget.RemainingMins,
+	})
+}
+
+func (s *Server) apiSetHouseholdBudget(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Minutes int `json:"minutes"` // 0 removes the budget
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Minutes < 0 || req.Minutes > 1440 {
+		jsonError(w, "Minutes must be between 0 and 1440", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.SetHouseholdBudgetMins(req.Minutes); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, map[string]string{"status": "updated"})
+}
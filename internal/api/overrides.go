@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/florian/screentime-guardian/internal/calendar"
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+func (s *Server) apiListOverrides(w http.ResponseWriter, r *http.Request) {
+	overrides, err := s.store.ListDateOverrides(time.Now().Format("2006-01-02"))
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, overrides)
+}
+
+func (s *Server) apiCreateOverride(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserID    int64  `json:"user_id"`
+		StartDate string `json:"start_date"`
+		EndDate   string `json:"end_date"`
+		LimitMins int    `json:"limit_mins"`
+		Unlimited bool   `json:"unlimited"`
+		Label     string `json:"label"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.EndDate == "" {
+		req.EndDate = req.StartDate
+	}
+
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		jsonError(w, "Start date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	end, err := time.Parse("2006-01-02", req.EndDate)
+	if err != nil {
+		jsonError(w, "End date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	if end.Before(start) {
+		jsonError(w, "End date cannot be before start date", http.StatusBadRequest)
+		return
+	}
+
+	if req.LimitMins < 0 || req.LimitMins > 1440 {
+		jsonError(w, "Limit must be between 0 and 1440 minutes", http.StatusBadRequest)
+		return
+	}
+
+	if req.UserID != 0 {
+		user, err := s.store.GetUserByID(req.UserID)
+		if err != nil || user == nil {
+			jsonError(w, "User not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	override, err := s.store.CreateDateOverride(&storage.DateOverride{
+		UserID:    req.UserID,
+		StartDate: req.StartDate,
+		EndDate:   req.EndDate,
+		LimitMins: req.LimitMins,
+		Unlimited: req.Unlimited,
+		Label:     req.Label,
+	})
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, override)
+}
+
+func (s *Server) apiDeleteOverride(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid override ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.DeleteDateOverride(id); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, map[string]string{"status": "deleted"})
+}
+
+// apiImportHolidays replaces the uploaded holiday calendar with a new .ics
+// file. Ranges from the watched calendar file are kept.
+func (s *Server) apiImportHolidays(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		jsonError(w, "Invalid upload", http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		jsonError(w, "Missing calendar file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	limitMins := s.config.HolidayLimitMins
+	if value := r.FormValue("limit_mins"); value != "" {
+		limitMins, err = strconv.Atoi(value)
+		if err != nil || limitMins < 0 || limitMins > 1440 {
+			jsonError(w, "Limit must be between 0 and 1440 minutes", http.StatusBadRequest)
+			return
+		}
+	}
+
+	count, err := calendar.Import(s.store, file, limitMins, storage.OverrideSourceUpload)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	jsonResponse(w, map[string]interface{}{
+		"status":   "imported",
+		"imported": count,
+	})
+}
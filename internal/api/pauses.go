@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+func (s *Server) apiPauseUser(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Minutes int    `json:"minutes"` // 0 pauses until resumed
+		Reason  string `json:"reason"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Minutes < 0 {
+		jsonError(w, "Minutes cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.store.GetUserByID(id)
+	if err != nil || user == nil {
+		jsonError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	pause, err := s.store.PauseUser(id, time.Duration(req.Minutes)*time.Minute, req.Reason, adminName(r))
+	if errors.Is(err, storage.ErrAlreadyPaused) {
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	body := "Your screen time is paused until a parent resumes it."
+	if pause.EndsAt != nil {
+		body = fmt.Sprintf("Your screen time is paused until %s.", pause.EndsAt.Local().Format("15:04"))
+	}
+	s.notifier.SendMessage(context.Background(), user.Username, "Screen Time Paused", body)
+
+	resp := map[string]interface{}{"status": "paused"}
+	if pause.EndsAt != nil {
+		resp["paused_until"] = pause.EndsAt.Local().Format(time.RFC3339)
+	}
+	jsonResponse(w, resp)
+}
+
+func (s *Server) apiResumeUser(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.store.GetUserByID(id)
+	if err != nil || user == nil {
+		jsonError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if _, err := s.store.ResumeUser(id, adminName(r)); errors.Is(err, storage.ErrNotPaused) {
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.notifier.SendMessage(context.Background(), user.Username, "Screen Time Resumed",
+		"Your pause has ended. Screen time is counting again.")
+
+	jsonResponse(w, map[string]string{"status": "resumed"})
+}
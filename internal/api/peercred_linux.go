@@ -0,0 +1,33 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// peerUID returns the UID of the process on the other end of a Unix socket
+func peerUID(conn net.Conn) (uint32, error) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return 0, fmt.Errorf("not a Unix socket connection")
+	}
+
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get peer credentials: %w", err)
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return 0, fmt.Errorf("failed to get peer credentials: %w", err)
+	}
+	if credErr != nil {
+		return 0, fmt.Errorf("failed to get peer credentials: %w", credErr)
+	}
+
+	return cred.Uid, nil
+}
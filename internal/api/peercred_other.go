@@ -0,0 +1,14 @@
+//go:build !linux
+
+package api
+
+import (
+	"fmt"
+	"net"
+)
+
+// peerUID is only implemented on Linux, so the child socket rejects every
+// caller elsewhere
+func peerUID(conn net.Conn) (uint32, error) {
+	return 0, fmt.Errorf("peer credentials are not supported on this platform")
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+// profileRequest is the body for creating and updating profiles. Windows and
+// warning intervals are comma separated, e.g. "07:00-08:00,15:00-20:00".
+type profileRequest struct {
+	Name             string `json:"name"`
+	WeekdayLimitMins int    `json:"weekday_limit_mins"`
+	WeekendLimitMins int    `json:"weekend_limit_mins"`
+	Windows          string `json:"windows"`
+	EnforcementMode  string `json:"enforcement_mode"`
+	WarningIntervals string `json:"warning_intervals"`
+}
+
+// toProfile validates the request and converts it into a profile
+func (req *profileRequest) toProfile() (*storage.Profile, error) {
+	if req.Name == "" {
+		return nil, errors.New("name is required")
+	}
+
+	if req.WeekdayLimitMins < 0 || req.WeekdayLimitMins > 1440 ||
+		req.WeekendLimitMins < 0 || req.WeekendLimitMins > 1440 {
+		return nil, errors.New("limits must be between 0 and 1440 minutes")
+	}
+
+	if req.EnforcementMode == "" {
+		req.EnforcementMode = storage.EnforcementLock
+	}
+	if !storage.ValidEnforcementMode(req.EnforcementMode) {
+		return nil, storage.ErrInvalidEnforcementMode
+	}
+
+	windows, err := storage.ParseWindows(req.Windows)
+	if err != nil {
+		return nil, err
+	}
+
+	intervals, err := storage.ParseIntervals(req.WarningIntervals)
+	if err != nil {
+		return nil, err
+	}
+
+	return &storage.Profile{
+		Name:             req.Name,
+		WeekdayLimitMins: req.WeekdayLimitMins,
+		WeekendLimitMins: req.WeekendLimitMins,
+		Windows:          windows,
+		EnforcementMode:  req.EnforcementMode,
+		WarningIntervals: intervals,
+	}, nil
+}
+
+func (s *Server) apiListProfiles(w http.ResponseWriter, r *http.Request) {
+	profiles, err := s.store.ListProfiles()
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, profiles)
+}
+
+func (s *Server) apiCreateProfile(w http.ResponseWriter, r *http.Request) {
+	var req profileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := req.toProfile()
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	profile, err = s.store.CreateProfile(profile)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, profile)
+}
+
+func (s *Server) apiUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid profile ID", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := s.store.GetProfile(id)
+	if err != nil || existing == nil {
+		jsonError(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	var req profileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := req.toProfile()
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	profile.ID = id
+	if err := s.store.UpdateProfile(profile); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, map[string]string{"status": "updated"})
+}
+
+func (s *Server) apiDeleteProfile(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid profile ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.DeleteProfile(id); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, map[string]string{"status": "deleted"})
+}
+
+// apiSetUserProfile switches a child's active profile. A profile_id of 0
+// returns the child to their own daily limit.
+func (s *Server) apiSetUserProfile(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.store.GetUserByID(id)
+	if err != nil || user == nil {
+		jsonError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		ProfileID int64  `json:"profile_id"`
+		Until     string `json:"until"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.ProfileID != 0 {
+		profile, err := s.store.GetProfile(req.ProfileID)
+		if err != nil || profile == nil {
+			jsonError(w, "Profile not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	if req.Until != "" {
+		until, err := time.ParseInLocation("2006-01-02", req.Until, time.Local)
+		if err != nil {
+			jsonError(w, "Revert date must be YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		if !until.After(time.Now()) {
+			jsonError(w, "Revert date must be in the future", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := s.store.SetUserProfile(id, req.ProfileID, req.Until, adminName(r)); err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, map[string]string{"status": "updated"})
+}
+
+func (s *Server) apiListTemplates(w http.ResponseWriter, r *http.Request) {
+	templates, err := s.store.ListTemplates()
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, templates)
+}
+
+// apiCreateTemplate saves an existing profile as a reusable template
+func (s *Server) apiCreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ProfileID   int64  `json:"profile_id"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := s.store.GetProfile(req.ProfileID)
+	if err != nil || profile == nil {
+		jsonError(w, "Profile not found", http.StatusNotFound)
+		return
+	}
+
+	template, err := s.store.SaveProfileAsTemplate(req.ProfileID, req.Name, req.Description)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, template)
+}
+
+func (s *Server) apiDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid template ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.store.DeleteTemplate(id); err != nil {
+		if errors.Is(err, storage.ErrBuiltInTemplate) {
+			jsonError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, map[string]string{"status": "deleted"})
+}
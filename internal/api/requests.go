@@ -0,0 +1,155 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+func (s *Server) apiListTimeRequests(w http.ResponseWriter, r *http.Request) {
+	requests, err := s.store.ListPendingTimeRequests()
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, requests)
+}
+
+func (s *Server) apiCreateTimeRequest(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.store.GetUserByID(id)
+	if err != nil || user == nil {
+		jsonError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	s.submitTimeRequest(w, r, user)
+}
+
+// submitTimeRequest queues the request in r's body for user. Parents use it
+// through the web API and children through the local socket.
+func (s *Server) submitTimeRequest(w http.ResponseWriter, r *http.Request, user *storage.User) {
+	var req struct {
+		Minutes int    `json:"minutes"`
+		Reason  string `json:"reason"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Minutes <= 0 {
+		jsonError(w, "Minutes must be positive", http.StatusBadRequest)
+		return
+	}
+
+	timeRequest, err := s.store.CreateTimeRequest(user.ID, req.Minutes, req.Reason, s.config.TimeRequestTimeout)
+	if errors.Is(err, storage.ErrRequestAlreadyPending) {
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, timeRequest)
+}
+
+func (s *Server) apiApproveTimeRequest(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid request ID", http.StatusBadRequest)
+		return
+	}
+
+	// An empty body or no minutes approves the full amount; a smaller
+	// number of minutes is a partial approval
+	var req struct {
+		Minutes *int `json:"minutes"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		jsonError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	timeRequest, err := s.store.GetTimeRequest(id)
+	if err != nil || timeRequest == nil {
+		jsonError(w, "Request not found", http.StatusNotFound)
+		return
+	}
+
+	minutes := timeRequest.Minutes
+	if req.Minutes != nil {
+		minutes = *req.Minutes
+	}
+	if minutes <= 0 || minutes > timeRequest.Minutes {
+		jsonError(w, fmt.Sprintf("Minutes must be between 1 and the %d requested", timeRequest.Minutes), http.StatusBadRequest)
+		return
+	}
+
+	timeRequest, err = s.store.ApproveTimeRequest(id, minutes, adminName(r))
+	if errors.Is(err, storage.ErrRequestNotPending) {
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Notify the user
+	ctx := context.Background()
+	s.notifier.SendRequestDecision(ctx, timeRequest.Username, timeRequest.Status, timeRequest.GrantedMinutes)
+
+	remaining, _ := s.store.GetRemainingSeconds(timeRequest.UserID)
+	jsonResponse(w, map[string]interface{}{
+		"status":         timeRequest.Status,
+		"minutes_added":  timeRequest.GrantedMinutes,
+		"remaining_secs": remaining,
+		"remaining_mins": storage.MinutesRoundedUp(remaining),
+	})
+}
+
+func (s *Server) apiDenyTimeRequest(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid request ID", http.StatusBadRequest)
+		return
+	}
+
+	timeRequest, err := s.store.DenyTimeRequest(id)
+	if errors.Is(err, storage.ErrRequestNotPending) {
+		jsonError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Notify the user
+	ctx := context.Background()
+	s.notifier.SendRequestDecision(ctx, timeRequest.Username, timeRequest.Status, 0)
+
+	jsonResponse(w, map[string]string{"status": timeRequest.Status})
+}
@@ -24,15 +24,15 @@ var staticFS embed.FS
 
 // Server holds the API dependencies
 type Server struct {
-	store    *storage.Storage
-	logind   *dbus.LogindClient
+	store    storage.Store
+	logind   dbus.SessionManager
 	notifier *notifier.Chain
 	config   *config.Config
 	tmpl     *template.Template
 }
 
 // NewRouter creates a new HTTP router with all routes configured
-func NewRouter(store *storage.Storage, logind *dbus.LogindClient, notifier *notifier.Chain, cfg *config.Config) http.Handler {
+func NewRouter(store storage.Store, logind dbus.SessionManager, notifier *notifier.Chain, cfg *config.Config) http.Handler {
 	// Parse templates with custom functions
 	funcMap := template.FuncMap{
 		"divf": func(a, b float64) float64 {
@@ -41,6 +41,9 @@ func NewRouter(store *storage.Storage, logind *dbus.LogindClient, notifier *noti
 			}
 			return a / b
 		},
+		"mins": func(seconds int) int {
+			return seconds / 60
+		},
 	}
 
 	tmpl := template.New("").Funcs(funcMap)
@@ -89,12 +92,65 @@ func NewRouter(store *storage.Storage, logind *dbus.LogindClient, notifier *noti
 	// API endpoints
 	r.Route("/api", func(r chi.Router) {
 		r.Get("/status", s.apiGetStatus)
+		r.Get("/health", s.apiGetHealth)
+		r.Get("/lock-failures", s.apiListLockFailures)
+		r.Post("/lock-failures/{id}/acknowledge", s.apiAcknowledgeLockFailure)
 		r.Post("/users", s.apiCreateUser)
 		r.Put("/users/{id}", s.apiUpdateUser)
+		r.Put("/users/{id}/degraded-mode", s.apiSetDegradedMode)
 		r.Delete("/users/{id}", s.apiDeleteUser)
 		r.Post("/users/{id}/extend", s.apiExtendTime)
+		r.Post("/users/{id}/deduct", s.apiDeductTime)
+		r.Get("/users/{id}/extensions", s.apiListExtensions)
+		r.Post("/users/{id}/usage", s.apiCorrectUsage)
+		r.Get("/users/{id}/usage/corrections", s.apiListUsageCorrections)
+		r.Post("/extensions/{id}/revoke", s.apiRevokeExtension)
 		r.Post("/users/{id}/lock", s.apiLockUser)
 		r.Post("/users/{id}/unlock", s.apiUnlockUser)
+		r.Post("/users/{id}/pause", s.apiPauseUser)
+		r.Post("/users/{id}/resume", s.apiResumeUser)
+		r.Get("/users/{id}/schedule.ics", s.apiUserSchedule)
+
+		// Household budget
+		r.Get("/household", s.apiGetHousehold)
+		r.Put("/household/budget", s.apiSetHouseholdBudget)
+
+		// Chores
+		r.Get("/users/{id}/chores", s.apiListChores)
+		r.Post("/users/{id}/chores", s.apiCreateChore)
+		r.Delete("/chores/{id}", s.apiDeleteChore)
+		r.Post("/chores/{id}/complete", s.apiCompleteChore)
+		r.Post("/chore-completions/{id}/approve", s.apiApproveChore)
+		r.Post("/chore-completions/{id}/reject", s.apiRejectChore)
+
+		// Date overrides
+		r.Get("/overrides", s.apiListOverrides)
+		r.Post("/overrides", s.apiCreateOverride)
+		r.Delete("/overrides/{id}", s.apiDeleteOverride)
+		r.Post("/holidays/import", s.apiImportHolidays)
+
+		// Profiles
+		r.Get("/profiles", s.apiListProfiles)
+		r.Post("/profiles", s.apiCreateProfile)
+		r.Put("/profiles/{id}", s.apiUpdateProfile)
+		r.Delete("/profiles/{id}", s.apiDeleteProfile)
+		r.Post("/users/{id}/profile", s.apiSetUserProfile)
+
+		// Templates
+		r.Get("/templates", s.apiListTemplates)
+		r.Post("/templates", s.apiCreateTemplate)
+		r.Delete("/templates/{id}", s.apiDeleteTemplate)
+
+		// Scheduled actions
+		r.Get("/actions", s.apiListScheduledActions)
+		r.Post("/users/{id}/actions", s.apiScheduleAction)
+		r.Delete("/actions/{id}", s.apiCancelScheduledAction)
+
+		// Time requests
+		r.Get("/requests", s.apiListTimeRequests)
+		r.Post("/users/{id}/requests", s.apiCreateTimeRequest)
+		r.Post("/requests/{id}/approve", s.apiApproveTimeRequest)
+		r.Post("/requests/{id}/deny", s.apiDenyTimeRequest)
 	})
 
 	return r
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/florian/screentime-guardian/internal/calendar"
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+const (
+	defaultScheduleWeeks = 4
+	maxScheduleWeeks     = 26
+)
+
+// apiUserSchedule renders the user's effective daily schedule for the coming
+// weeks as an iCalendar feed that parents can subscribe to
+func (s *Server) apiUserSchedule(w http.ResponseWriter, r *http.Request) {
+	idStr := chi.URLParam(r, "id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		jsonError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.store.GetUserByID(id)
+	if err != nil || user == nil {
+		jsonError(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	weeks := defaultScheduleWeeks
+	if value := r.URL.Query().Get("weeks"); value != "" {
+		weeks, err = strconv.Atoi(value)
+		if err != nil || weeks < 1 || weeks > maxScheduleWeeks {
+			jsonError(w, fmt.Sprintf("Weeks must be between 1 and %d", maxScheduleWeeks), http.StatusBadRequest)
+			return
+		}
+	}
+
+	events, err := s.scheduleEvents(user, time.Now(), weeks*7)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s-schedule.ics"`, user.Username))
+	calendar.Write(w, fmt.Sprintf("Screen time: %s", user.Username), events)
+}
+
+// scheduleEvents builds one all-day event per day describing the policy that
+// applies to the user, plus a timed event for each allowed window
+func (s *Server) scheduleEvents(user *storage.User, from time.Time, days int) ([]calendar.Event, error) {
+	events := make([]calendar.Event, 0, days)
+	midnight := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+
+	for i := 0; i < days; i++ {
+		day := midnight.AddDate(0, 0, i)
+		date := day.Format("2006-01-02")
+
+		policy, err := s.store.GetPolicy(user.ID, date)
+		if err != nil {
+			return nil, err
+		}
+
+		event := calendar.Event{
+			UID:       fmt.Sprintf("%s-%s@screentime-guardian", user.Username, date),
+			Summary:   scheduleSummary(user.Username, policy),
+			StartDate: date,
+			EndDate:   date,
+		}
+		if policy.Override != nil {
+			event.Description = policy.Override.Label
+		}
+		if policy.Profile != nil {
+			event.Description = strings.TrimPrefix(event.Description+"\nProfile: "+policy.Profile.Name, "\n")
+		}
+
+		events = append(events, event)
+
+		if policy.LimitMins == 0 && !policy.Unlimited {
+			continue
+		}
+
+		for _, window := range policy.Windows {
+			events = append(events, calendar.Event{
+				UID:       fmt.Sprintf("%s-%s-%04d@screentime-guardian", user.Username, date, window.Start),
+				Summary:   fmt.Sprintf("%s: screen time allowed", user.Username),
+				StartDate: date,
+				EndDate:   date,
+				Start:     time.Date(day.Year(), day.Month(), day.Day(), 0, window.Start, 0, 0, time.Local),
+				End:       time.Date(day.Year(), day.Month(), day.Day(), 0, window.End, 0, 0, time.Local),
+			})
+		}
+	}
+
+	return events, nil
+}
+
+func scheduleSummary(username string, policy *storage.Policy) string {
+	switch {
+	case policy.Unlimited:
+		return fmt.Sprintf("%s: unlimited screen time", username)
+	case policy.LimitMins == 0:
+		return fmt.Sprintf("%s: no screen time", username)
+	default:
+		return fmt.Sprintf("%s: %d min screen time", username, policy.LimitMins)
+	}
+}
@@ -0,0 +1,92 @@
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const utcFormat = "20060102T150405Z"
+
+// Write renders events as an iCalendar (RFC 5545) feed with the given
+// calendar name. Events with a Start time are written as timed events in
+// UTC, all others as all-day events.
+func Write(w io.Writer, name string, events []Event) error {
+	bw := bufio.NewWriter(w)
+	stamp := time.Now().UTC().Format(utcFormat)
+
+	writeLine(bw, "BEGIN:VCALENDAR")
+	writeLine(bw, "VERSION:2.0")
+	writeLine(bw, "PRODID:-//Screentime Guardian//Schedule//EN")
+	writeLine(bw, "CALSCALE:GREGORIAN")
+	writeLine(bw, "METHOD:PUBLISH")
+	writeLine(bw, "X-WR-CALNAME:"+escape(name))
+
+	for _, event := range events {
+		writeLine(bw, "BEGIN:VEVENT")
+		writeLine(bw, "UID:"+event.UID)
+		writeLine(bw, "DTSTAMP:"+stamp)
+
+		if !event.Start.IsZero() {
+			writeLine(bw, "DTSTART:"+event.Start.UTC().Format(utcFormat))
+			writeLine(bw, "DTEND:"+event.End.UTC().Format(utcFormat))
+		} else {
+			start, err := time.Parse("2006-01-02", event.StartDate)
+			if err != nil {
+				return fmt.Errorf("invalid start date %q: %w", event.StartDate, err)
+			}
+			end := start
+			if event.EndDate != "" {
+				end, err = time.Parse("2006-01-02", event.EndDate)
+				if err != nil {
+					return fmt.Errorf("invalid end date %q: %w", event.EndDate, err)
+				}
+			}
+
+			writeLine(bw, "DTSTART;VALUE=DATE:"+start.Format("20060102"))
+			// DTEND is exclusive for all-day events
+			writeLine(bw, "DTEND;VALUE=DATE:"+end.AddDate(0, 0, 1).Format("20060102"))
+		}
+
+		writeLine(bw, "SUMMARY:"+escape(event.Summary))
+		if event.Description != "" {
+			writeLine(bw, "DESCRIPTION:"+escape(event.Description))
+		}
+		writeLine(bw, "TRANSP:TRANSPARENT")
+		writeLine(bw, "END:VEVENT")
+	}
+
+	writeLine(bw, "END:VCALENDAR")
+	return bw.Flush()
+}
+
+// writeLine writes a content line, folding it at 75 octets without
+// splitting UTF-8 sequences
+func writeLine(w *bufio.Writer, line string) {
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8Start(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines lose one octet to the leading space
+		limit = 74
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+func utf8Start(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
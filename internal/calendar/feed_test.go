@@ -0,0 +1,54 @@
+package calendar
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteRoundTrip(t *testing.T) {
+	long := strings.Repeat("Grandma's visit, with ünïcödé; ", 5)
+	events := []Event{
+		{UID: "a@test", Summary: "alice: 120 min screen time", StartDate: "2024-12-23", EndDate: "2024-12-23"},
+		{UID: "b@test", Summary: long, Description: "Two\nlines", StartDate: "2024-12-24", EndDate: "2024-12-26"},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "Test", events); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("Line exceeds 75 octets: %q", line)
+		}
+	}
+
+	parsed, err := Parse(&buf)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(parsed) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(parsed))
+	}
+
+	if parsed[0] != events[0] {
+		t.Errorf("Expected %+v, got %+v", events[0], parsed[0])
+	}
+	if parsed[1].Summary != long {
+		t.Errorf("Long summary did not survive folding: %q", parsed[1].Summary)
+	}
+	if parsed[1].Description != "Two lines" {
+		t.Errorf("Unexpected description: %q", parsed[1].Description)
+	}
+	if parsed[1].StartDate != "2024-12-24" || parsed[1].EndDate != "2024-12-26" {
+		t.Errorf("Unexpected range: %+v", parsed[1])
+	}
+}
+
+func TestWriteInvalidDate(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "Test", []Event{{UID: "x", StartDate: "24.12.2024"}}); err == nil {
+		t.Error("Expected error for invalid date")
+	}
+}
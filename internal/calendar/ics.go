@@ -0,0 +1,195 @@
+package calendar
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNotCalendar is returned for input without BEGIN:VCALENDAR, e.g. when
+// the wrong file was uploaded
+var ErrNotCalendar = errors.New("not an iCalendar file: BEGIN:VCALENDAR is missing")
+
+// Event is a VEVENT reduced to what the daemon needs: a label and the
+// inclusive range of days it covers. Timed events also carry their exact
+// start and end; both are zero for all-day events.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	StartDate   string
+	EndDate     string
+	Start       time.Time
+	End         time.Time
+}
+
+// Parse reads an iCalendar (RFC 5545) stream and returns its events. Events
+// without a usable DTSTART are skipped. Input that is not a VCALENDAR is
+// rejected with ErrNotCalendar.
+func Parse(r io.Reader) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	var props map[string]property
+	inEvent := false
+	isCalendar := false
+
+	for _, line := range lines {
+		name, prop, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(prop.value, "VCALENDAR"):
+			isCalendar = true
+		case name == "BEGIN" && strings.EqualFold(prop.value, "VEVENT"):
+			inEvent = true
+			props = make(map[string]property)
+		case name == "END" && strings.EqualFold(prop.value, "VEVENT"):
+			inEvent = false
+			if event, ok := buildEvent(props); ok {
+				events = append(events, event)
+			}
+		case inEvent:
+			if _, seen := props[name]; !seen {
+				props[name] = prop
+			}
+		}
+	}
+
+	if !isCalendar {
+		return nil, ErrNotCalendar
+	}
+	return events, nil
+}
+
+type property struct {
+	params map[string]string
+	value  string
+}
+
+// unfold joins continuation lines (those starting with a space or tab) onto
+// the previous line
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read calendar: %w", err)
+	}
+	return lines, nil
+}
+
+// parseLine splits "NAME;PARAM=x:VALUE" into its parts
+func parseLine(line string) (string, property, bool) {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return "", property{}, false
+	}
+
+	head := strings.Split(line[:colon], ";")
+	prop := property{
+		params: make(map[string]string),
+		value:  line[colon+1:],
+	}
+	for _, param := range head[1:] {
+		if k, v, ok := strings.Cut(param, "="); ok {
+			prop.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+
+	return strings.ToUpper(head[0]), prop, true
+}
+
+func buildEvent(props map[string]property) (Event, bool) {
+	start, ok := props["DTSTART"]
+	if !ok {
+		return Event{}, false
+	}
+
+	startTime, allDay, err := parseDateTime(start)
+	if err != nil {
+		return Event{}, false
+	}
+
+	endTime := startTime
+	exactEnd := startTime
+	if end, ok := props["DTEND"]; ok {
+		if t, _, err := parseDateTime(end); err == nil && t.After(startTime) {
+			endTime = t
+			exactEnd = t
+			// DTEND is exclusive: an all-day event ending on the 24th, or a
+			// timed event ending at midnight, covers days up to the 23rd
+			if allDay || endTime.Equal(truncateDay(endTime)) {
+				endTime = endTime.AddDate(0, 0, -1)
+			}
+		}
+	}
+
+	event := Event{
+		UID:         props["UID"].value,
+		Summary:     unescape(props["SUMMARY"].value),
+		Description: unescape(props["DESCRIPTION"].value),
+		StartDate:   startTime.Format("2006-01-02"),
+		EndDate:     endTime.Format("2006-01-02"),
+	}
+	if !allDay {
+		event.Start = startTime
+		event.End = exactEnd
+	}
+
+	return event, true
+}
+
+// parseDateTime handles DATE values, UTC DATE-TIME values and local DATE-TIME
+// values with an optional TZID. Timed values are converted to local time.
+func parseDateTime(prop property) (time.Time, bool, error) {
+	value := prop.value
+
+	if prop.params["VALUE"] == "DATE" || len(value) == 8 {
+		t, err := time.ParseInLocation("20060102", value, time.Local)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		return t.Local(), false, err
+	}
+
+	loc := time.Local
+	if tzid := prop.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	return t.Local(), false, err
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+var unescaper = strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+func unescape(s string) string {
+	return unescaper.Replace(s)
+}
@@ -0,0 +1,210 @@
+package calendar
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+const testCalendar = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//School//Holidays//EN\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:autumn@school\r\n" +
+	"DTSTART;VALUE=DATE:20241028\r\n" +
+	"DTEND;VALUE=DATE:20241102\r\n" +
+	"SUMMARY:Autumn\\, half term\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:christmas@school\r\n" +
+	"DTSTART;VALUE=DATE:20241223\r\n" +
+	"DTEND;VALUE=DATE:20250107\r\n" +
+	"SUMMARY:Christmas holi\r\n" +
+	" days\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:inset@school\r\n" +
+	"DTSTART;VALUE=DATE:20241115\r\n" +
+	"SUMMARY:INSET day\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParse(t *testing.T) {
+	events, err := Parse(strings.NewReader(testCalendar))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	want := []Event{
+		{UID: "autumn@school", Summary: "Autumn, half term", StartDate: "2024-10-28", EndDate: "2024-11-01"},
+		{UID: "christmas@school", Summary: "Christmas holidays", StartDate: "2024-12-23", EndDate: "2025-01-06"},
+		{UID: "inset@school", Summary: "INSET day", StartDate: "2024-11-15", EndDate: "2024-11-15"},
+	}
+
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %d", len(want), len(events))
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("Event %d: expected %+v, got %+v", i, want[i], events[i])
+		}
+	}
+}
+
+func TestParseDateTime(t *testing.T) {
+	local := time.Local
+	time.Local = time.UTC
+	defer func() { time.Local = local }()
+
+	calendar := "BEGIN:VCALENDAR\n" +
+		"BEGIN:VEVENT\n" +
+		"DTSTART:20240301T090000Z\n" +
+		"DTEND:20240303T000000Z\n" +
+		"SUMMARY:Trip\n" +
+		"END:VEVENT\n" +
+		"BEGIN:VEVENT\n" +
+		"DTSTART;TZID=Europe/Berlin:20240310T003000\n" +
+		"DTEND;TZID=Europe/Berlin:20240310T020000\n" +
+		"SUMMARY:Night\n" +
+		"END:VEVENT\n" +
+		"BEGIN:VEVENT\n" +
+		"SUMMARY:No start\n" +
+		"END:VEVENT\n" +
+		"END:VCALENDAR\n"
+
+	events, err := Parse(strings.NewReader(calendar))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+
+	// Ends at midnight, so the 3rd is not included
+	if events[0].StartDate != "2024-03-01" || events[0].EndDate != "2024-03-02" {
+		t.Errorf("Unexpected range for timed event: %+v", events[0])
+	}
+
+	// 00:30 in Berlin is still the previous day in UTC
+	if events[1].StartDate != "2024-03-09" || events[1].EndDate != "2024-03-10" {
+		t.Errorf("Unexpected range for TZID event: %+v", events[1])
+	}
+}
+
+func TestImport(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("testuser", 60)
+
+	// Manual overrides must survive an import
+	store.CreateDateOverride(&storage.DateOverride{
+		StartDate: "2024-12-25",
+		EndDate:   "2024-12-25",
+		Unlimited: true,
+		Label:     "Christmas Day",
+	})
+
+	count, err := Import(store, strings.NewReader(testCalendar), 180, storage.OverrideSourceUpload)
+	if err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 imported ranges, got %d", count)
+	}
+
+	policy, _ := store.GetPolicy(user.ID, "2024-10-30")
+	if policy.LimitMins != 180 || policy.Override == nil || policy.Override.Label != "Autumn, half term" {
+		t.Errorf("Expected holiday limit on 2024-10-30, got %+v", policy)
+	}
+
+	// Re-importing replaces the previous ranges instead of duplicating them
+	Import(store, strings.NewReader(testCalendar), 200, storage.OverrideSourceUpload)
+
+	imported, _ := store.ListDateOverridesBySource(storage.OverrideSourceUpload)
+	if len(imported) != 3 {
+		t.Errorf("Expected 3 imported ranges after re-import, got %d", len(imported))
+	}
+
+	manual, _ := store.ListDateOverridesBySource(storage.OverrideSourceManual)
+	if len(manual) != 1 {
+		t.Errorf("Expected manual override to survive import, got %d", len(manual))
+	}
+
+	policy, _ = store.GetPolicy(user.ID, "2024-12-25")
+	if !policy.Unlimited {
+		t.Error("Expected the manual override to win over the imported holiday")
+	}
+
+	// The wrong file or an empty calendar keeps the previous import
+	for name, upload := range map[string]string{
+		"garbage":        "%PDF-1.4\nnot a calendar\n",
+		"empty":          "",
+		"no events":      "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nEND:VCALENDAR\r\n",
+		"no usable date": "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:Someday\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		if _, err := Import(store, strings.NewReader(upload), 180, storage.OverrideSourceUpload); err == nil {
+			t.Errorf("Expected an error importing %s", name)
+		}
+	}
+	imported, _ = store.ListDateOverridesBySource(storage.OverrideSourceUpload)
+	if len(imported) != 3 {
+		t.Errorf("Expected the 3 imported ranges to survive bad uploads, got %d", len(imported))
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	path := filepath.Join(tmpDir, "holidays.ics")
+	watcher := NewWatcher(store, path, 240, time.Minute)
+
+	if _, err := watcher.Check(); err == nil {
+		t.Error("Expected error for missing calendar file")
+	}
+	if msg, _ := store.GetSetting(SettingLastImportError); msg == "" {
+		t.Error("Expected import error to be recorded")
+	}
+
+	os.WriteFile(path, []byte(testCalendar), 0644)
+
+	imported, err := watcher.Check()
+	if err != nil || !imported {
+		t.Fatalf("Expected first check to import, got %v, %v", imported, err)
+	}
+	if msg, _ := store.GetSetting(SettingLastImportError); msg != "" {
+		t.Errorf("Expected import error to be cleared, got %q", msg)
+	}
+
+	imported, _ = watcher.Check()
+	if imported {
+		t.Error("Expected unchanged file not to be re-imported")
+	}
+
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+
+	imported, _ = watcher.Check()
+	if !imported {
+		t.Error("Expected changed file to be re-imported")
+	}
+
+	overrides, _ := store.ListDateOverridesBySource(storage.OverrideSourceCalendar)
+	if len(overrides) != 3 {
+		t.Errorf("Expected 3 calendar overrides, got %d", len(overrides))
+	}
+}
@@ -0,0 +1,132 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+// Settings keys recording the state of the last calendar import
+const (
+	SettingLastImport      = "calendar_last_import"
+	SettingLastImportError = "calendar_last_import_error"
+)
+
+// ErrNoEvents is returned when a calendar has no events with dates
+var ErrNoEvents = errors.New("calendar has no events with dates")
+
+// Import parses an iCalendar stream and replaces all household overrides from
+// the given source with one override per event. It returns the number of
+// imported ranges. A file that is not a calendar or has no events is an
+// error and leaves the previous import in place, since it is far more
+// likely the wrong file than a school without holidays.
+func Import(store storage.Store, r io.Reader, limitMins int, source string) (int, error) {
+	events, err := Parse(r)
+	if err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, ErrNoEvents
+	}
+
+	overrides := make([]*storage.DateOverride, 0, len(events))
+	for _, event := range events {
+		label := event.Summary
+		if label == "" {
+			label = "Holiday"
+		}
+
+		overrides = append(overrides, &storage.DateOverride{
+			StartDate: event.StartDate,
+			EndDate:   event.EndDate,
+			LimitMins: limitMins,
+			Label:     label,
+		})
+	}
+
+	if err := store.ReplaceDateOverrides(source, overrides); err != nil {
+		return 0, err
+	}
+
+	return len(overrides), nil
+}
+
+// Watcher re-imports a holiday calendar file whenever it changes
+type Watcher struct {
+	store     storage.Store
+	path      string
+	limitMins int
+	interval  time.Duration
+	modTime   time.Time
+}
+
+// NewWatcher creates a watcher for the calendar file at path
+func NewWatcher(store storage.Store, path string, limitMins int, interval time.Duration) *Watcher {
+	return &Watcher{
+		store:     store,
+		path:      path,
+		limitMins: limitMins,
+		interval:  interval,
+	}
+}
+
+// Run imports the file once and then polls it for changes until the context
+// is cancelled
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.Check(); err != nil {
+			log.Printf("Holiday calendar import failed: %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Check imports the file if its modification time has changed since the
+// last import and reports whether an import happened
+func (w *Watcher) Check() (bool, error) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false, w.recordError(fmt.Errorf("failed to stat %s: %w", w.path, err))
+	}
+
+	if info.ModTime().Equal(w.modTime) {
+		return false, nil
+	}
+
+	f, err := os.Open(w.path)
+	if err != nil {
+		return false, w.recordError(fmt.Errorf("failed to open %s: %w", w.path, err))
+	}
+	defer f.Close()
+
+	count, err := Import(w.store, f, w.limitMins, storage.OverrideSourceCalendar)
+	if err != nil {
+		return false, w.recordError(fmt.Errorf("failed to import %s: %w", w.path, err))
+	}
+
+	w.modTime = info.ModTime()
+	log.Printf("Imported %d holiday range(s) from %s", count, w.path)
+
+	w.store.SetSetting(SettingLastImport, time.Now().Format(time.RFC3339))
+	w.store.SetSetting(SettingLastImportError, "")
+	return true, nil
+}
+
+func (w *Watcher) recordError(err error) error {
+	w.store.SetSetting(SettingLastImportError, err.Error())
+	return err
+}
@@ -0,0 +1,82 @@
+// Package clock abstracts the current time so that the scheduler and the
+// storage date functions can run on simulated time. It also parses the
+// HH:MM times of day used throughout the configuration.
+package clock
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Clock tells the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the wall clock
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Fake is a clock that only moves when it is set or advanced
+type Fake struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFake creates a fake clock standing at now
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now returns the fake clock's time
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Set moves the clock to now, which may be in the past
+func (f *Fake) Set(now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
+
+// Advance moves the clock forward by d
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// Parse parses an HH:MM time of day into minutes since midnight. 24:00 is
+// accepted so that a window can run until the end of the day.
+func Parse(value string) (int, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(value), ":")
+	if !ok {
+		return 0, fmt.Errorf("time %q must be HH:MM", value)
+	}
+
+	hours, err := strconv.Atoi(h)
+	if err != nil {
+		return 0, fmt.Errorf("time %q must be HH:MM", value)
+	}
+	mins, err := strconv.Atoi(m)
+	if err != nil {
+		return 0, fmt.Errorf("time %q must be HH:MM", value)
+	}
+
+	total := hours*60 + mins
+	if hours < 0 || mins < 0 || mins > 59 || total > 24*60 {
+		return 0, fmt.Errorf("time %q is out of range", value)
+	}
+	return total, nil
+}
@@ -0,0 +1,58 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2026, 10, 16, 19, 55, 0, 0, time.Local)
+	c := NewFake(start)
+
+	if !c.Now().Equal(start) {
+		t.Errorf("Expected %v, got %v", start, c.Now())
+	}
+
+	c.Advance(3 * time.Minute)
+	if want := start.Add(3 * time.Minute); !c.Now().Equal(want) {
+		t.Errorf("Expected %v after advancing, got %v", want, c.Now())
+	}
+
+	c.Set(start)
+	if !c.Now().Equal(start) {
+		t.Errorf("Expected %v after setting, got %v", start, c.Now())
+	}
+}
+
+func TestSystem(t *testing.T) {
+	before := time.Now()
+	now := System.Now()
+	if now.Before(before) || now.After(time.Now()) {
+		t.Errorf("Expected the wall clock, got %v", now)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"00:00", 0, false},
+		{"07:30", 450, false},
+		{" 9:05 ", 545, false},
+		{"24:00", 1440, false},
+		{"24:01", 0, true},
+		{"12:60", 0, true},
+		{"-1:00", 0, true},
+		{"noon", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Parse(%q) = %d, %v; want %d, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
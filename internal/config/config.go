@@ -1,10 +1,13 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/florian/screentime-guardian/internal/clock"
 )
 
 // Config holds the application configuration
@@ -31,21 +34,116 @@ type Config struct {
 
 	// GracePeriod is extra time after limit before hard lock
 	GracePeriod time.Duration `yaml:"grace_period"`
+
+	// LockRetries is how many times a lock is retried when the session does
+	// not report being locked, before the user is logged out instead
+	LockRetries int `yaml:"lock_retries"`
+
+	// LockVerifyDelay is how long to wait before checking that a lock took
+	// effect. It doubles with every retry.
+	LockVerifyDelay time.Duration `yaml:"lock_verify_delay"`
+
+	// ChildSocket is the local socket through which children request more
+	// time and report chores without the admin password. The daemon
+	// identifies them by their Linux user. Empty disables it.
+	ChildSocket string `yaml:"child_socket"`
+
+	// TimeRequestTimeout is how long a child's request for more time waits
+	// for a parent's answer before it expires
+	TimeRequestTimeout time.Duration `yaml:"time_request_timeout"`
+
+	// HolidayCalendarFile is an optional iCalendar file with school holidays.
+	// It is re-imported whenever it changes.
+	HolidayCalendarFile string `yaml:"holiday_calendar_file"`
+
+	// HolidayLimitMins is the daily limit applied on imported holidays
+	HolidayLimitMins int `yaml:"holiday_limit_mins"`
+
+	// RulesFile is an optional YAML file with declarative rules evaluated on
+	// every check
+	RulesFile string `yaml:"rules_file"`
+
+	// Curfew is the household bedtime (HH:MM). From then until CurfewEnd
+	// the computer is put to sleep or powered off. Empty disables it.
+	Curfew    string `yaml:"curfew"`
+	CurfewEnd string `yaml:"curfew_end"`
+
+	// PowerWhenOutOfTime powers the computer down once no child has any
+	// screen time left today
+	PowerWhenOutOfTime bool `yaml:"power_when_out_of_time"`
+
+	// PowerAction is "suspend" or "poweroff"
+	PowerAction string `yaml:"power_action"`
+
+	// PowerWarning is how long logged-in users are warned before the
+	// computer is powered down
+	PowerWarning time.Duration `yaml:"power_warning"`
+
+	// PowerExemptUsers (usually the parents) prevent any power action while
+	// they are logged in
+	PowerExemptUsers []string `yaml:"power_exempt_users"`
 }
 
+// DefaultChildSocket is where the daemon listens for children by default
+const DefaultChildSocket = "/run/screentime-guardian/child.sock"
+
+// Power actions
+const (
+	PowerSuspend  = "suspend"
+	PowerPowerOff = "poweroff"
+)
+
 // Default returns a configuration with sensible defaults
 func Default() *Config {
 	return &Config{
-		ListenAddr:       ":8080",
-		DatabasePath:     "/var/lib/screentime-guardian/data.db",
-		AdminPassword:    "",          // Must be set on first run
-		EnableTLS:        false,       // Disabled by default
-		TLSCertFile:      "/etc/screentime-guardian/server.crt",
-		TLSKeyFile:       "/etc/screentime-guardian/server.key",
-		WarningIntervals: []int{5, 1}, // Warn at 5 minutes and 1 minute
-		CheckInterval:    30 * time.Second,
-		GracePeriod:      1 * time.Minute,
+		ListenAddr:         ":8080",
+		DatabasePath:       "/var/lib/screentime-guardian/data.db",
+		AdminPassword:      "",    // Must be set on first run
+		EnableTLS:          false, // Disabled by default
+		TLSCertFile:        "/etc/screentime-guardian/server.crt",
+		TLSKeyFile:         "/etc/screentime-guardian/server.key",
+		WarningIntervals:   []int{5, 1}, // Warn at 5 minutes and 1 minute
+		CheckInterval:      30 * time.Second,
+		GracePeriod:        1 * time.Minute,
+		LockRetries:        3,
+		LockVerifyDelay:    2 * time.Second,
+		ChildSocket:        DefaultChildSocket,
+		TimeRequestTimeout: 15 * time.Minute,
+		HolidayLimitMins:   240,
+		CurfewEnd:          "06:00",
+		PowerAction:        PowerSuspend,
+		PowerWarning:       5 * time.Minute,
+	}
+}
+
+// Validate checks settings that cannot be checked by the YAML decoder
+func (c *Config) Validate() error {
+	if c.Curfew != "" {
+		if _, err := clock.Parse(c.Curfew); err != nil {
+			return fmt.Errorf("curfew: %w", err)
+		}
+		if _, err := clock.Parse(c.CurfewEnd); err != nil {
+			return fmt.Errorf("curfew_end: %w", err)
+		}
+	}
+
+	if c.PowerAction != PowerSuspend && c.PowerAction != PowerPowerOff {
+		return fmt.Errorf("power_action must be %q or %q, got %q", PowerSuspend, PowerPowerOff, c.PowerAction)
 	}
+
+	if c.PowerWarning < 0 {
+		return fmt.Errorf("power_warning cannot be negative")
+	}
+
+	if c.LockRetries < 1 {
+		return fmt.Errorf("lock_retries must be at least 1")
+	}
+
+	if c.LockVerifyDelay <= 0 {
+		return fmt.Errorf("lock_verify_delay must be positive")
+	}
+
+	return nil
 }
 
 // Load reads configuration from a YAML file
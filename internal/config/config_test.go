@@ -33,6 +33,14 @@ func TestDefault(t *testing.T) {
 	if cfg.GracePeriod != 1*time.Minute {
 		t.Errorf("Expected GracePeriod 1m, got %v", cfg.GracePeriod)
 	}
+
+	if cfg.TimeRequestTimeout != 15*time.Minute {
+		t.Errorf("Expected TimeRequestTimeout 15m, got %v", cfg.TimeRequestTimeout)
+	}
+
+	if cfg.HolidayLimitMins != 240 {
+		t.Errorf("Expected HolidayLimitMins 240, got %d", cfg.HolidayLimitMins)
+	}
 }
 
 func TestLoadAndSave(t *testing.T) {
@@ -98,3 +106,31 @@ func TestLoadInvalidYAML(t *testing.T) {
 		t.Error("Expected error when loading invalid YAML, got nil")
 	}
 }
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr bool
+	}{
+		{"defaults", func(c *Config) {}, false},
+		{"curfew", func(c *Config) { c.Curfew = "21:30" }, false},
+		{"bad curfew", func(c *Config) { c.Curfew = "9pm" }, true},
+		{"bad curfew end", func(c *Config) { c.Curfew = "21:30"; c.CurfewEnd = "25:00" }, true},
+		{"poweroff", func(c *Config) { c.PowerAction = PowerPowerOff }, false},
+		{"hibernate", func(c *Config) { c.PowerAction = "hibernate" }, true},
+		{"negative warning", func(c *Config) { c.PowerWarning = -time.Minute }, true},
+		{"no lock retries", func(c *Config) { c.LockRetries = 0 }, true},
+		{"no lock verify delay", func(c *Config) { c.LockVerifyDelay = 0 }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(cfg)
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package dbus
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+const (
+	logindName         = "org.freedesktop.login1"
+	logindPath         = dbus.ObjectPath("/org/freedesktop/login1")
+	logindManagerIface = "org.freedesktop.login1.Manager"
+	logindSessionIface = "org.freedesktop.login1.Session"
+)
+
+// fakeLogind is a Go implementation of the parts of org.freedesktop.login1
+// that LogindClient uses, for exporting on a private bus. Like logind it
+// emits SessionNew and SessionRemoved on the manager, Lock and Unlock on a
+// session, and PropertiesChanged when a session's LockedHint changes.
+// Hold mu to read its fields.
+type fakeLogind struct {
+	conn *dbus.Conn
+
+	mu           sync.Mutex
+	sessions     []*fakeSession
+	ignoreLock   bool // the desktop ignores the Lock signal, so LockedHint stays false
+	suspends     int
+	poweredOff   bool
+	shutdownKind string
+	shutdownAt   time.Time
+}
+
+// fakeSession is a session object with its properties
+type fakeSession struct {
+	Session
+	props *prop.Properties
+}
+
+// serveFakeLogind connects to the bus at address, exports a fake logind
+// without sessions and takes its well-known name
+func serveFakeLogind(t *testing.T, address string) *fakeLogind {
+	t.Helper()
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	l := &fakeLogind{conn: conn}
+	if err := conn.Export(&fakeLogindManager{l}, logindPath, logindManagerIface); err != nil {
+		t.Fatalf("Failed to export manager: %v", err)
+	}
+	if _, err := conn.RequestName(logindName, dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatalf("Failed to request name: %v", err)
+	}
+	return l
+}
+
+// sessionPath escapes the session ID like logind does, e.g. "1" becomes
+// /org/freedesktop/login1/session/_31
+func sessionPath(id string) dbus.ObjectPath {
+	escaped := ""
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9' {
+			escaped += string(c)
+		} else {
+			escaped += fmt.Sprintf("_%02x", c)
+		}
+	}
+	return logindPath + "/session/" + dbus.ObjectPath(escaped)
+}
+
+// addSession exports a session and announces it with SessionNew
+func (l *fakeLogind) addSession(t *testing.T, id string, uid uint32, username, sessionType string) Session {
+	t.Helper()
+
+	s := &fakeSession{Session: Session{ID: id, UserID: uid, UserName: username, Seat: "seat0", Path: sessionPath(id)}}
+
+	props, err := prop.Export(l.conn, s.Path, prop.Map{
+		logindSessionIface: {
+			"Id":         {Value: id, Emit: prop.EmitConst},
+			"Name":       {Value: username, Emit: prop.EmitConst},
+			"Type":       {Value: sessionType, Emit: prop.EmitConst},
+			"Active":     {Value: true, Emit: prop.EmitTrue},
+			"LockedHint": {Value: false, Emit: prop.EmitTrue},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to export session properties: %v", err)
+	}
+	s.props = props
+	if err := l.conn.Export(&fakeLogindSession{l, id}, s.Path, logindSessionIface); err != nil {
+		t.Fatalf("Failed to export session: %v", err)
+	}
+
+	l.mu.Lock()
+	l.sessions = append(l.sessions, s)
+	l.mu.Unlock()
+
+	l.conn.Emit(logindPath, logindManagerIface+".SessionNew", id, s.Path)
+	return s.Session
+}
+
+// session returns the session with the given ID. The caller must hold l.mu.
+func (l *fakeLogind) session(id string) (*fakeSession, *dbus.Error) {
+	for _, s := range l.sessions {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return nil, dbus.NewError(logindName+".NoSuchSession", []interface{}{fmt.Sprintf("No session '%s' known", id)})
+}
+
+// lock emits Lock on the session and, unless the desktop ignores it, sets
+// the LockedHint as the desktop's screen locker would. The caller must hold
+// l.mu.
+func (l *fakeLogind) lock(s *fakeSession) {
+	l.conn.Emit(s.Path, logindSessionIface+".Lock")
+	if !l.ignoreLock {
+		s.props.SetMust(logindSessionIface, "LockedHint", true)
+	}
+}
+
+// terminate removes the session and announces it with SessionRemoved. The
+// caller must hold l.mu.
+func (l *fakeLogind) terminate(s *fakeSession) {
+	for i, other := range l.sessions {
+		if other == s {
+			l.sessions = append(l.sessions[:i], l.sessions[i+1:]...)
+			break
+		}
+	}
+	l.conn.Export(nil, s.Path, logindSessionIface)
+	l.conn.Export(nil, s.Path, "org.freedesktop.DBus.Properties")
+	l.conn.Emit(logindPath, logindManagerIface+".SessionRemoved", s.ID, s.Path)
+}
+
+// locked reports the session's LockedHint
+func (l *fakeLogind) locked(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	s, err := l.session(id)
+	if err != nil {
+		return false
+	}
+	return s.props.GetMust(logindSessionIface, "LockedHint").(bool)
+}
+
+// fakeLogindManager is exported as org.freedesktop.login1.Manager
+type fakeLogindManager struct {
+	l *fakeLogind
+}
+
+// listedSession is the (susso) struct of ListSessions
+type listedSession struct {
+	ID   string
+	UID  uint32
+	Name string
+	Seat string
+	Path dbus.ObjectPath
+}
+
+func (m *fakeLogindManager) ListSessions() ([]listedSession, *dbus.Error) {
+	m.l.mu.Lock()
+	defer m.l.mu.Unlock()
+
+	listed := make([]listedSession, 0, len(m.l.sessions))
+	for _, s := range m.l.sessions {
+		listed = append(listed, listedSession{s.ID, s.UserID, s.UserName, s.Seat, s.Path})
+	}
+	return listed, nil
+}
+
+// malformedLogindManager answers ListSessions with the UID as a string, as
+// a broken or incompatible logind might
+type malformedLogindManager struct {
+	*fakeLogindManager
+}
+
+type malformedSession struct {
+	ID   string
+	UID  string
+	Name string
+	Seat string
+	Path dbus.ObjectPath
+}
+
+func (m *malformedLogindManager) ListSessions() ([]malformedSession, *dbus.Error) {
+	m.l.mu.Lock()
+	defer m.l.mu.Unlock()
+
+	listed := make([]malformedSession, 0, len(m.l.sessions))
+	for _, s := range m.l.sessions {
+		listed = append(listed, malformedSession{s.ID, fmt.Sprint(s.UserID), s.UserName, s.Seat, s.Path})
+	}
+	return listed, nil
+}
+
+// listMalformedSessions makes ListSessions return wrongly typed rows
+func (l *fakeLogind) listMalformedSessions(t *testing.T) {
+	t.Helper()
+
+	manager := &malformedLogindManager{&fakeLogindManager{l}}
+	if err := l.conn.Export(manager, logindPath, logindManagerIface); err != nil {
+		t.Fatalf("Failed to export manager: %v", err)
+	}
+}
+
+func (m *fakeLogindManager) GetSession(id string) (dbus.ObjectPath, *dbus.Error) {
+	m.l.mu.Lock()
+	defer m.l.mu.Unlock()
+
+	s, err := m.l.session(id)
+	if err != nil {
+		return "", err
+	}
+	return s.Path, nil
+}
+
+func (m *fakeLogindManager) LockSession(id string) *dbus.Error {
+	m.l.mu.Lock()
+	defer m.l.mu.Unlock()
+
+	s, err := m.l.session(id)
+	if err != nil {
+		return err
+	}
+	m.l.lock(s)
+	return nil
+}
+
+func (m *fakeLogindManager) LockSessions() *dbus.Error {
+	m.l.mu.Lock()
+	defer m.l.mu.Unlock()
+
+	for _, s := range m.l.sessions {
+		m.l.lock(s)
+	}
+	return nil
+}
+
+func (m *fakeLogindManager) TerminateSession(id string) *dbus.Error {
+	m.l.mu.Lock()
+	defer m.l.mu.Unlock()
+
+	s, err := m.l.session(id)
+	if err != nil {
+		return err
+	}
+	m.l.terminate(s)
+	return nil
+}
+
+func (m *fakeLogindManager) Suspend(interactive bool) *dbus.Error {
+	m.l.mu.Lock()
+	defer m.l.mu.Unlock()
+
+	m.l.suspends++
+	return nil
+}
+
+func (m *fakeLogindManager) PowerOff(interactive bool) *dbus.Error {
+	m.l.mu.Lock()
+	defer m.l.mu.Unlock()
+
+	m.l.poweredOff = true
+	return nil
+}
+
+func (m *fakeLogindManager) ScheduleShutdown(kind string, usec uint64) *dbus.Error {
+	m.l.mu.Lock()
+	defer m.l.mu.Unlock()
+
+	m.l.shutdownKind = kind
+	m.l.shutdownAt = time.UnixMicro(int64(usec))
+	return nil
+}
+
+func (m *fakeLogindManager) CancelScheduledShutdown() (bool, *dbus.Error) {
+	m.l.mu.Lock()
+	defer m.l.mu.Unlock()
+
+	cancelled := m.l.shutdownKind != ""
+	m.l.shutdownKind = ""
+	m.l.shutdownAt = time.Time{}
+	return cancelled, nil
+}
+
+// fakeLogindSession is exported as org.freedesktop.login1.Session on each
+// session's path
+type fakeLogindSession struct {
+	l  *fakeLogind
+	id string
+}
+
+func (s *fakeLogindSession) Lock() *dbus.Error {
+	return (&fakeLogindManager{s.l}).LockSession(s.id)
+}
+
+func (s *fakeLogindSession) Unlock() *dbus.Error {
+	s.l.mu.Lock()
+	defer s.l.mu.Unlock()
+
+	session, err := s.l.session(s.id)
+	if err != nil {
+		return err
+	}
+	s.l.conn.Emit(session.Path, logindSessionIface+".Unlock")
+	session.props.SetMust(logindSessionIface, "LockedHint", false)
+	return nil
+}
+
+func (s *fakeLogindSession) Terminate() *dbus.Error {
+	return (&fakeLogindManager{s.l}).TerminateSession(s.id)
+}
+
+// SetLockedHint is called by the desktop's screen locker
+func (s *fakeLogindSession) SetLockedHint(locked bool) *dbus.Error {
+	s.l.mu.Lock()
+	defer s.l.mu.Unlock()
+
+	session, err := s.l.session(s.id)
+	if err != nil {
+		return err
+	}
+	session.props.SetMust(logindSessionIface, "LockedHint", locked)
+	return nil
+}
@@ -1,14 +1,76 @@
 package dbus
 
 import (
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 )
 
-// LogindClient provides access to systemd-logind session management
+// Reconnection backoff after the bus connection is lost
+const (
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = time.Minute
+)
+
+// ErrDisconnected is returned while the connection to the bus is down and
+// the next reconnection attempt is not due yet
+var ErrDisconnected = errors.New("not connected to the system bus")
+
+// SessionManager is the session and power management the scheduler and the
+// web API need from logind. LogindClient implements it, and
+// MockLogindClient stands in for it in tests.
+type SessionManager interface {
+	Health() Health
+
+	ListSessions() ([]Session, error)
+	GetUserSessions(username string) ([]Session, error)
+	IsUserLoggedIn(username string) (bool, error)
+	SessionType(session Session) (string, error)
+	SessionLocked(session Session) (bool, error)
+
+	LockSession(sessionID string) error
+	LockSessions() error
+	LockUserSessions(username string) error
+	LockScreen(session Session) (string, error)
+	TerminateSession(sessionID string) error
+	TerminateUserSessions(username string) error
+
+	Suspend() error
+	PowerOff() error
+	ScheduleShutdown(kind string, at time.Time) error
+	CancelScheduledShutdown() (bool, error)
+}
+
+var (
+	_ SessionManager = (*LogindClient)(nil)
+	_ SessionManager = (*MockLogindClient)(nil)
+)
+
+// LogindClient provides access to systemd-logind session management. It
+// reconnects with exponential backoff when the bus connection is lost, e.g.
+// when dbus-daemon restarts during a package upgrade.
 type LogindClient struct {
-	conn *dbus.Conn
+	address string // empty for the system bus
+
+	mu      sync.Mutex
+	conn    *dbus.Conn
+	backoff time.Duration
+	retryAt time.Time
+	health  Health
+}
+
+// Health describes whether logind can be reached. ListSessions, which the
+// scheduler calls on every check, keeps it up to date.
+type Health struct {
+	Healthy   bool
+	Since     time.Time // when the current state began
+	LastError string
+	RetryAt   time.Time // next reconnection attempt while disconnected
 }
 
 // Session represents a user session from logind
@@ -22,50 +84,179 @@ type Session struct {
 
 // NewLogindClient creates a new connection to systemd-logind
 func NewLogindClient() (*LogindClient, error) {
-	conn, err := dbus.ConnectSystemBus()
+	return NewLogindClientAt("")
+}
+
+// NewLogindClientAt connects to logind on the bus at address, or on the
+// system bus if address is empty
+func NewLogindClientAt(address string) (*LogindClient, error) {
+	c := &LogindClient{address: address}
+
+	conn, err := c.dial()
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to system bus: %w", err)
 	}
+	c.conn = conn
+	c.health = Health{Healthy: true, Since: time.Now()}
+
+	return c, nil
+}
 
-	return &LogindClient{conn: conn}, nil
+func (c *LogindClient) dial() (*dbus.Conn, error) {
+	if c.address == "" {
+		return dbus.ConnectSystemBus()
+	}
+	return dbus.Connect(c.address)
 }
 
 // Close closes the D-Bus connection
 func (c *LogindClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
 	return c.conn.Close()
 }
 
+// Health returns the current state of the connection to logind
+func (c *LogindClient) Health() Health {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.health
+}
+
+// bus returns the bus connection, reconnecting if it was lost and the
+// backoff allows another attempt
+func (c *LogindClient) bus() (*dbus.Conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil && c.conn.Connected() {
+		return c.conn, nil
+	}
+
+	now := time.Now()
+	if now.Before(c.retryAt) {
+		return nil, ErrDisconnected
+	}
+
+	conn, err := c.dial()
+	if err != nil {
+		if c.backoff == 0 {
+			c.backoff = minReconnectBackoff
+		} else if c.backoff < maxReconnectBackoff {
+			c.backoff = min(c.backoff*2, maxReconnectBackoff)
+		}
+		c.retryAt = now.Add(c.backoff)
+		c.setHealth(false, fmt.Errorf("failed to reconnect to system bus: %w", err))
+		return nil, fmt.Errorf("failed to reconnect to system bus: %w", err)
+	}
+
+	if c.conn != nil {
+		c.conn.Close()
+		log.Printf("Reconnected to the system bus")
+	}
+	c.conn = conn
+	c.backoff = 0
+	c.retryAt = time.Time{}
+	return conn, nil
+}
+
+// setHealth records the outcome of a connection attempt or call. The caller
+// must hold c.mu.
+func (c *LogindClient) setHealth(healthy bool, err error) {
+	if healthy != c.health.Healthy {
+		c.health.Since = time.Now()
+	}
+	c.health.Healthy = healthy
+	c.health.RetryAt = c.retryAt
+	if err != nil {
+		c.health.LastError = err.Error()
+	}
+}
+
+// object returns a logind object on the current connection
+func (c *LogindClient) object(path dbus.ObjectPath) (dbus.BusObject, error) {
+	conn, err := c.bus()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Object("org.freedesktop.login1", path), nil
+}
+
+// manager returns logind's manager object on the current connection
+func (c *LogindClient) manager() (dbus.BusObject, error) {
+	return c.object("/org/freedesktop/login1")
+}
+
 // ListSessions returns all active user sessions
 func (c *LogindClient) ListSessions() ([]Session, error) {
-	obj := c.conn.Object("org.freedesktop.login1", "/org/freedesktop/login1")
+	obj, err := c.manager()
+	if err != nil {
+		return nil, err
+	}
 
 	var result [][]interface{}
-	err := obj.Call("org.freedesktop.login1.Manager.ListSessions", 0).Store(&result)
+	err = obj.Call("org.freedesktop.login1.Manager.ListSessions", 0).Store(&result)
+
+	// A reply of the wrong shape fails the whole list rather than hiding
+	// sessions, so the scheduler treats logind as unavailable
+	var sessions []Session
+	if err == nil {
+		sessions, err = parseSessions(result)
+	}
+
+	c.mu.Lock()
+	c.setHealth(err == nil, err)
+	c.mu.Unlock()
+
 	if err != nil {
 		return nil, fmt.Errorf("failed to list sessions: %w", err)
 	}
 
-	sessions := make([]Session, 0, len(result))
-	for _, s := range result {
-		if len(s) < 5 {
-			continue
+	return sessions, nil
+}
+
+// parseSessions converts the (susso) rows of ListSessions
+func parseSessions(rows [][]interface{}) ([]Session, error) {
+	sessions := make([]Session, 0, len(rows))
+	for _, row := range rows {
+		session, ok := parseSession(row)
+		if !ok {
+			return nil, fmt.Errorf("unexpected session from logind: %v", row)
 		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
 
-		sessions = append(sessions, Session{
-			ID:       s[0].(string),
-			UserID:   s[1].(uint32),
-			UserName: s[2].(string),
-			Seat:     s[3].(string),
-			Path:     s[4].(dbus.ObjectPath),
-		})
+// parseSession converts one row, reporting false if it does not have the
+// (susso) shape
+func parseSession(row []interface{}) (Session, bool) {
+	if len(row) < 5 {
+		return Session{}, false
 	}
 
-	return sessions, nil
+	id, ok1 := row[0].(string)
+	uid, ok2 := row[1].(uint32)
+	name, ok3 := row[2].(string)
+	seat, ok4 := row[3].(string)
+	path, ok5 := row[4].(dbus.ObjectPath)
+	if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 {
+		return Session{}, false
+	}
+
+	return Session{ID: id, UserID: uid, UserName: name, Seat: seat, Path: path}, true
 }
 
 // LockSession locks a specific session by ID
 func (c *LogindClient) LockSession(sessionID string) error {
-	obj := c.conn.Object("org.freedesktop.login1", "/org/freedesktop/login1")
+	obj, err := c.manager()
+	if err != nil {
+		return err
+	}
 
 	call := obj.Call("org.freedesktop.login1.Manager.LockSession", 0, sessionID)
 	if call.Err != nil {
@@ -77,7 +268,10 @@ func (c *LogindClient) LockSession(sessionID string) error {
 
 // LockSessions locks all sessions
 func (c *LogindClient) LockSessions() error {
-	obj := c.conn.Object("org.freedesktop.login1", "/org/freedesktop/login1")
+	obj, err := c.manager()
+	if err != nil {
+		return err
+	}
 
 	call := obj.Call("org.freedesktop.login1.Manager.LockSessions", 0)
 	if call.Err != nil {
@@ -87,9 +281,24 @@ func (c *LogindClient) LockSessions() error {
 	return nil
 }
 
+// LockScreen locks a session through the desktop's own screen locker on
+// the user's session bus, for desktops that ignore logind's Lock signal. It
+// returns the service that locked the screen. Only the session's user may
+// connect to that bus, so anyone else goes through LockScreenAs.
+func (c *LogindClient) LockScreen(session Session) (string, error) {
+	address := SessionBusAddress(session.UserID)
+	if uint32(os.Geteuid()) == session.UserID {
+		return LockScreen(address)
+	}
+	return LockScreenAs(session.UserName, address)
+}
+
 // TerminateSession forcefully terminates a session (logs user out)
 func (c *LogindClient) TerminateSession(sessionID string) error {
-	obj := c.conn.Object("org.freedesktop.login1", "/org/freedesktop/login1")
+	obj, err := c.manager()
+	if err != nil {
+		return err
+	}
 
 	call := obj.Call("org.freedesktop.login1.Manager.TerminateSession", 0, sessionID)
 	if call.Err != nil {
@@ -167,3 +376,109 @@ func (c *LogindClient) GetUserSessions(username string) ([]Session, error) {
 
 	return userSessions, nil
 }
+
+// SessionType returns the type of a session, e.g. "x11", "wayland" or "tty"
+func (c *LogindClient) SessionType(session Session) (string, error) {
+	obj, err := c.object(session.Path)
+	if err != nil {
+		return "", err
+	}
+
+	variant, err := obj.GetProperty("org.freedesktop.login1.Session.Type")
+	if err != nil {
+		return "", fmt.Errorf("failed to get type of session %s: %w", session.ID, err)
+	}
+
+	sessionType, ok := variant.Value().(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected type for session %s: %v", session.ID, variant)
+	}
+
+	return sessionType, nil
+}
+
+// SessionLocked reports the session's LockedHint, which the desktop's screen
+// locker sets once the screen is actually locked. Sessions without a
+// screen locker, such as text consoles, never report locked.
+func (c *LogindClient) SessionLocked(session Session) (bool, error) {
+	obj, err := c.object(session.Path)
+	if err != nil {
+		return false, err
+	}
+
+	variant, err := obj.GetProperty("org.freedesktop.login1.Session.LockedHint")
+	if err != nil {
+		return false, fmt.Errorf("failed to get lock state of session %s: %w", session.ID, err)
+	}
+
+	locked, ok := variant.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("unexpected lock state for session %s: %v", session.ID, variant)
+	}
+
+	return locked, nil
+}
+
+// Suspend suspends the machine. Interactive polkit authorization is not
+// requested; the daemon runs as root.
+func (c *LogindClient) Suspend() error {
+	obj, err := c.manager()
+	if err != nil {
+		return err
+	}
+
+	call := obj.Call("org.freedesktop.login1.Manager.Suspend", 0, false)
+	if call.Err != nil {
+		return fmt.Errorf("failed to suspend: %w", call.Err)
+	}
+
+	return nil
+}
+
+// PowerOff shuts the machine down immediately
+func (c *LogindClient) PowerOff() error {
+	obj, err := c.manager()
+	if err != nil {
+		return err
+	}
+
+	call := obj.Call("org.freedesktop.login1.Manager.PowerOff", 0, false)
+	if call.Err != nil {
+		return fmt.Errorf("failed to power off: %w", call.Err)
+	}
+
+	return nil
+}
+
+// ScheduleShutdown asks logind to shut down at the given time. kind is
+// "poweroff", "reboot" or "halt". logind itself broadcasts wall messages to
+// terminal users as the time approaches.
+func (c *LogindClient) ScheduleShutdown(kind string, at time.Time) error {
+	obj, err := c.manager()
+	if err != nil {
+		return err
+	}
+
+	call := obj.Call("org.freedesktop.login1.Manager.ScheduleShutdown", 0, kind, uint64(at.UnixMicro()))
+	if call.Err != nil {
+		return fmt.Errorf("failed to schedule %s: %w", kind, call.Err)
+	}
+
+	return nil
+}
+
+// CancelScheduledShutdown cancels a shutdown scheduled with ScheduleShutdown
+// and reports whether one was pending
+func (c *LogindClient) CancelScheduledShutdown() (bool, error) {
+	obj, err := c.manager()
+	if err != nil {
+		return false, err
+	}
+
+	var cancelled bool
+	if err := obj.Call("org.freedesktop.login1.Manager.CancelScheduledShutdown", 0).Store(&cancelled); err != nil {
+		return false, fmt.Errorf("failed to cancel scheduled shutdown: %w", err)
+	}
+
+	return cancelled, nil
+}
@@ -0,0 +1,281 @@
+package dbus
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestLogindReconnect(t *testing.T) {
+	address := "unix:path=" + filepath.Join(t.TempDir(), "bus")
+	_, stop := startBus(t, address)
+	serveFakeLogind(t, address).addSession(t, "1", 1000, "alice", "x11")
+
+	client, err := NewLogindClientAt(address)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Close()
+
+	if sessions, err := client.ListSessions(); err != nil || len(sessions) != 1 {
+		t.Fatalf("Expected one session, got %v, %v", sessions, err)
+	}
+	if !client.Health().Healthy {
+		t.Error("Expected healthy connection")
+	}
+
+	// The bus goes away, e.g. during an upgrade
+	stop()
+	for i := 0; i < 50 && client.conn.Connected(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := client.ListSessions(); err == nil {
+		t.Fatal("Expected ListSessions to fail without a bus")
+	}
+	health := client.Health()
+	if health.Healthy || health.LastError == "" || health.RetryAt.IsZero() {
+		t.Errorf("Expected unhealthy state with a retry time, got %+v", health)
+	}
+
+	// Further calls wait for the backoff instead of redialling
+	if _, err := client.ListSessions(); !errors.Is(err, ErrDisconnected) {
+		t.Errorf("Expected ErrDisconnected during backoff, got %v", err)
+	}
+
+	// The bus is back; the next attempt after the backoff reconnects
+	startBus(t, address)
+	serveFakeLogind(t, address).addSession(t, "1", 1000, "alice", "x11")
+
+	client.mu.Lock()
+	client.retryAt = time.Time{}
+	client.mu.Unlock()
+
+	if sessions, err := client.ListSessions(); err != nil || len(sessions) != 1 {
+		t.Fatalf("Expected reconnection, got %v, %v", sessions, err)
+	}
+	if !client.Health().Healthy {
+		t.Error("Expected healthy connection after reconnecting")
+	}
+}
+
+// startFakeLogind runs a private bus with a fake logind and connects a
+// client to it
+func startFakeLogind(t *testing.T) (*fakeLogind, *LogindClient, string) {
+	t.Helper()
+
+	address := startSessionBus(t)
+	logind := serveFakeLogind(t, address)
+
+	client, err := NewLogindClientAt(address)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return logind, client, address
+}
+
+// watchSignals subscribes to logind's signals on a separate connection
+func watchSignals(t *testing.T, address string) <-chan *dbus.Signal {
+	t.Helper()
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if err := conn.AddMatchSignal(dbus.WithMatchSender(logindName)); err != nil {
+		t.Fatalf("Failed to add match: %v", err)
+	}
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	return signals
+}
+
+// expectSignal waits for the named signal on path, skipping others
+func expectSignal(t *testing.T, signals <-chan *dbus.Signal, name string, path dbus.ObjectPath) *dbus.Signal {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case sig := <-signals:
+			if sig.Name == name && sig.Path == path {
+				return sig
+			}
+		case <-timeout:
+			t.Fatalf("Expected signal %s on %s", name, path)
+			return nil
+		}
+	}
+}
+
+func TestLogindSessions(t *testing.T) {
+	logind, client, _ := startFakeLogind(t)
+
+	if sessions, err := client.ListSessions(); err != nil || len(sessions) != 0 {
+		t.Fatalf("Expected no sessions, got %v, %v", sessions, err)
+	}
+
+	alice := logind.addSession(t, "2", 1000, "alice", "wayland")
+	logind.addSession(t, "c1", 1001, "ben", "tty")
+
+	sessions, err := client.ListSessions()
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0] != alice {
+		t.Fatalf("Expected alice's session first, got %+v", sessions)
+	}
+	if sessions[0].Path != "/org/freedesktop/login1/session/_32" {
+		t.Errorf("Expected an escaped session path, got %s", sessions[0].Path)
+	}
+
+	userSessions, err := client.GetUserSessions("ben")
+	if err != nil || len(userSessions) != 1 || userSessions[0].ID != "c1" || userSessions[0].UserID != 1001 {
+		t.Errorf("Expected ben's console session, got %+v, %v", userSessions, err)
+	}
+
+	if loggedIn, err := client.IsUserLoggedIn("alice"); err != nil || !loggedIn {
+		t.Errorf("Expected alice to be logged in, got %v, %v", loggedIn, err)
+	}
+	if loggedIn, err := client.IsUserLoggedIn("carol"); err != nil || loggedIn {
+		t.Errorf("Expected carol not to be logged in, got %v, %v", loggedIn, err)
+	}
+
+	if sessionType, err := client.SessionType(alice); err != nil || sessionType != "wayland" {
+		t.Errorf("Expected wayland, got %q, %v", sessionType, err)
+	}
+	if sessionType, err := client.SessionType(userSessions[0]); err != nil || sessionType != "tty" {
+		t.Errorf("Expected tty, got %q, %v", sessionType, err)
+	}
+	if locked, err := client.SessionLocked(alice); err != nil || locked {
+		t.Errorf("Expected alice's session unlocked, got %v, %v", locked, err)
+	}
+}
+
+func TestLogindLock(t *testing.T) {
+	logind, client, address := startFakeLogind(t)
+	signals := watchSignals(t, address)
+
+	alice := logind.addSession(t, "1", 1000, "alice", "x11")
+	ben := logind.addSession(t, "2", 1001, "ben", "x11")
+
+	if err := client.LockSession(alice.ID); err != nil {
+		t.Fatalf("LockSession failed: %v", err)
+	}
+	expectSignal(t, signals, logindSessionIface+".Lock", alice.Path)
+
+	// The desktop answers the Lock signal by setting LockedHint
+	changed := expectSignal(t, signals, "org.freedesktop.DBus.Properties.PropertiesChanged", alice.Path)
+	if hints := changed.Body[1].(map[string]dbus.Variant); hints["LockedHint"].Value() != true {
+		t.Errorf("Expected LockedHint in PropertiesChanged, got %v", changed.Body)
+	}
+	if locked, err := client.SessionLocked(alice); err != nil || !locked {
+		t.Errorf("Expected alice's session locked, got %v, %v", locked, err)
+	}
+	if logind.locked(ben.ID) {
+		t.Error("Expected ben's session to stay unlocked")
+	}
+
+	// A desktop that ignores the signal leaves the session unlocked
+	logind.mu.Lock()
+	logind.ignoreLock = true
+	logind.mu.Unlock()
+
+	if err := client.LockUserSessions("ben"); err != nil {
+		t.Fatalf("LockUserSessions failed: %v", err)
+	}
+	expectSignal(t, signals, logindSessionIface+".Lock", ben.Path)
+	if locked, err := client.SessionLocked(ben); err != nil || locked {
+		t.Errorf("Expected ben's session to stay unlocked, got %v, %v", locked, err)
+	}
+
+	if err := client.LockSession("99"); err == nil {
+		t.Error("Expected an error locking an unknown session")
+	}
+}
+
+func TestLogindTerminate(t *testing.T) {
+	logind, client, address := startFakeLogind(t)
+	signals := watchSignals(t, address)
+
+	alice := logind.addSession(t, "1", 1000, "alice", "x11")
+	expectSignal(t, signals, logindManagerIface+".SessionNew", logindPath)
+	logind.addSession(t, "2", 1001, "ben", "x11")
+	logind.addSession(t, "3", 1001, "ben", "tty")
+
+	if err := client.TerminateSession(alice.ID); err != nil {
+		t.Fatalf("TerminateSession failed: %v", err)
+	}
+	removed := expectSignal(t, signals, logindManagerIface+".SessionRemoved", logindPath)
+	if removed.Body[0] != alice.ID || removed.Body[1] != alice.Path {
+		t.Errorf("Expected SessionRemoved for alice's session, got %v", removed.Body)
+	}
+	if _, err := client.SessionType(alice); err == nil {
+		t.Error("Expected an error reading a terminated session")
+	}
+
+	if err := client.TerminateUserSessions("ben"); err != nil {
+		t.Fatalf("TerminateUserSessions failed: %v", err)
+	}
+	if sessions, err := client.ListSessions(); err != nil || len(sessions) != 0 {
+		t.Errorf("Expected no sessions left, got %+v, %v", sessions, err)
+	}
+
+	if err := client.TerminateSession(alice.ID); err == nil {
+		t.Error("Expected an error terminating an unknown session")
+	}
+}
+
+func TestLogindPower(t *testing.T) {
+	logind, client, _ := startFakeLogind(t)
+
+	if err := client.Suspend(); err != nil {
+		t.Fatalf("Suspend failed: %v", err)
+	}
+	if err := client.PowerOff(); err != nil {
+		t.Fatalf("PowerOff failed: %v", err)
+	}
+
+	at := time.Now().Add(10 * time.Minute).Truncate(time.Microsecond)
+	if err := client.ScheduleShutdown("poweroff", at); err != nil {
+		t.Fatalf("ScheduleShutdown failed: %v", err)
+	}
+
+	logind.mu.Lock()
+	if logind.suspends != 1 || !logind.poweredOff || logind.shutdownKind != "poweroff" || !logind.shutdownAt.Equal(at) {
+		t.Errorf("Expected suspend, power off and a shutdown at %v, got %+v", at, logind)
+	}
+	logind.mu.Unlock()
+
+	if cancelled, err := client.CancelScheduledShutdown(); err != nil || !cancelled {
+		t.Errorf("Expected the shutdown to be cancelled, got %v, %v", cancelled, err)
+	}
+	if cancelled, err := client.CancelScheduledShutdown(); err != nil || cancelled {
+		t.Errorf("Expected nothing left to cancel, got %v, %v", cancelled, err)
+	}
+}
+
+func TestLogindMalformedSessions(t *testing.T) {
+	logind, client, _ := startFakeLogind(t)
+	logind.addSession(t, "1", 1000, "alice", "x11")
+	logind.listMalformedSessions(t)
+
+	// A wrongly typed row is an error, not a panic in the check loop
+	sessions, err := client.ListSessions()
+	if err == nil {
+		t.Fatalf("Expected an error for malformed sessions, got %+v", sessions)
+	}
+	if health := client.Health(); health.Healthy || health.LastError == "" {
+		t.Errorf("Expected logind to be reported unhealthy, got %+v", health)
+	}
+	if _, err := client.IsUserLoggedIn("alice"); err == nil {
+		t.Error("Expected IsUserLoggedIn to fail on malformed sessions")
+	}
+}
@@ -1,10 +1,27 @@
 package dbus
 
-// MockLogindClient is a test implementation of LogindClient
+import (
+	"sync"
+	"time"
+)
+
+// MockLogindClient is a test implementation of SessionManager. Hold Mu to
+// read or change its fields while it is shared with other goroutines.
 type MockLogindClient struct {
+	Mu sync.Mutex
+
 	Sessions           []Session
 	LockedSessions     []string
 	TerminatedSessions []string
+	SessionTypes       map[string]string // session ID -> type
+	LockedHints        map[string]bool   // session ID -> locked, set by LockSession
+	IgnoreLock         bool              // LockSession succeeds but leaves the session unlocked
+	ScreenLocks        []string          // session IDs locked through LockScreen
+	NoScreenSaver      bool              // LockScreen finds no screen locker
+	Suspends           int
+	PoweredOff         bool
+	ShutdownKind       string // set by ScheduleShutdown, cleared on cancel
+	ShutdownAt         time.Time
 	ShouldError        bool
 }
 
@@ -14,6 +31,7 @@ func NewMockLogindClient() *MockLogindClient {
 		Sessions:           make([]Session, 0),
 		LockedSessions:     make([]string, 0),
 		TerminatedSessions: make([]string, 0),
+		LockedHints:        make(map[string]bool),
 	}
 }
 
@@ -22,25 +40,56 @@ func (m *MockLogindClient) Close() error {
 	return nil
 }
 
+// Health reports the mock as unhealthy while ShouldError is set
+func (m *MockLogindClient) Health() Health {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	if m.ShouldError {
+		return Health{LastError: "mock list sessions error"}
+	}
+	return Health{Healthy: true}
+}
+
 // ListSessions returns the mock sessions
 func (m *MockLogindClient) ListSessions() ([]Session, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	return m.listSessions()
+}
+
+func (m *MockLogindClient) listSessions() ([]Session, error) {
 	if m.ShouldError {
 		return nil, &MockError{Message: "mock list sessions error"}
 	}
-	return m.Sessions, nil
+	return append([]Session(nil), m.Sessions...), nil
 }
 
 // LockSession adds the session ID to the locked list
 func (m *MockLogindClient) LockSession(sessionID string) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	return m.lockSession(sessionID)
+}
+
+func (m *MockLogindClient) lockSession(sessionID string) error {
 	if m.ShouldError {
 		return &MockError{Message: "mock lock error"}
 	}
 	m.LockedSessions = append(m.LockedSessions, sessionID)
+	if !m.IgnoreLock {
+		m.LockedHints[sessionID] = true
+	}
 	return nil
 }
 
 // LockSessions locks all sessions
 func (m *MockLogindClient) LockSessions() error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
 	if m.ShouldError {
 		return &MockError{Message: "mock lock all error"}
 	}
@@ -50,8 +99,31 @@ func (m *MockLogindClient) LockSessions() error {
 	return nil
 }
 
+// LockScreen records the session as locked through a screen locker
+func (m *MockLogindClient) LockScreen(session Session) (string, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	if m.ShouldError {
+		return "", &MockError{Message: "mock lock screen error"}
+	}
+	if m.NoScreenSaver {
+		return "", ErrNoScreenSaver
+	}
+	m.ScreenLocks = append(m.ScreenLocks, session.ID)
+	m.LockedHints[session.ID] = true
+	return "org.freedesktop.ScreenSaver", nil
+}
+
 // TerminateSession adds the session ID to the terminated list
 func (m *MockLogindClient) TerminateSession(sessionID string) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	return m.terminateSession(sessionID)
+}
+
+func (m *MockLogindClient) terminateSession(sessionID string) error {
 	if m.ShouldError {
 		return &MockError{Message: "mock terminate error"}
 	}
@@ -61,16 +133,17 @@ func (m *MockLogindClient) TerminateSession(sessionID string) error {
 
 // LockUserSessions locks all sessions for a specific user
 func (m *MockLogindClient) LockUserSessions(username string) error {
-	sessions, err := m.ListSessions()
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	sessions, err := m.userSessions(username)
 	if err != nil {
 		return err
 	}
 
 	for _, session := range sessions {
-		if session.UserName == username {
-			if err := m.LockSession(session.ID); err != nil {
-				return err
-			}
+		if err := m.lockSession(session.ID); err != nil {
+			return err
 		}
 	}
 
@@ -79,16 +152,17 @@ func (m *MockLogindClient) LockUserSessions(username string) error {
 
 // TerminateUserSessions terminates all sessions for a specific user
 func (m *MockLogindClient) TerminateUserSessions(username string) error {
-	sessions, err := m.ListSessions()
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	sessions, err := m.userSessions(username)
 	if err != nil {
 		return err
 	}
 
 	for _, session := range sessions {
-		if session.UserName == username {
-			if err := m.TerminateSession(session.ID); err != nil {
-				return err
-			}
+		if err := m.terminateSession(session.ID); err != nil {
+			return err
 		}
 	}
 
@@ -97,23 +171,48 @@ func (m *MockLogindClient) TerminateUserSessions(username string) error {
 
 // IsUserLoggedIn checks if a user has any active sessions
 func (m *MockLogindClient) IsUserLoggedIn(username string) (bool, error) {
-	sessions, err := m.ListSessions()
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	sessions, err := m.userSessions(username)
 	if err != nil {
 		return false, err
 	}
+	return len(sessions) > 0, nil
+}
 
-	for _, session := range sessions {
-		if session.UserName == username {
-			return true, nil
-		}
+// SessionType returns the configured type for the session
+func (m *MockLogindClient) SessionType(session Session) (string, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	if m.ShouldError {
+		return "", &MockError{Message: "mock session type error"}
 	}
+	return m.SessionTypes[session.ID], nil
+}
+
+// SessionLocked returns the session's recorded lock state
+func (m *MockLogindClient) SessionLocked(session Session) (bool, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
 
-	return false, nil
+	if m.ShouldError {
+		return false, &MockError{Message: "mock locked hint error"}
+	}
+	return m.LockedHints[session.ID], nil
 }
 
 // GetUserSessions returns all sessions for a specific user
 func (m *MockLogindClient) GetUserSessions(username string) ([]Session, error) {
-	sessions, err := m.ListSessions()
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	return m.userSessions(username)
+}
+
+func (m *MockLogindClient) userSessions(username string) ([]Session, error) {
+	sessions, err := m.listSessions()
 	if err != nil {
 		return nil, err
 	}
@@ -128,6 +227,57 @@ func (m *MockLogindClient) GetUserSessions(username string) ([]Session, error) {
 	return userSessions, nil
 }
 
+// Suspend counts the suspend request
+func (m *MockLogindClient) Suspend() error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	if m.ShouldError {
+		return &MockError{Message: "mock suspend error"}
+	}
+	m.Suspends++
+	return nil
+}
+
+// PowerOff records the power off request
+func (m *MockLogindClient) PowerOff() error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	if m.ShouldError {
+		return &MockError{Message: "mock power off error"}
+	}
+	m.PoweredOff = true
+	return nil
+}
+
+// ScheduleShutdown records the scheduled shutdown
+func (m *MockLogindClient) ScheduleShutdown(kind string, at time.Time) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	if m.ShouldError {
+		return &MockError{Message: "mock schedule shutdown error"}
+	}
+	m.ShutdownKind = kind
+	m.ShutdownAt = at
+	return nil
+}
+
+// CancelScheduledShutdown clears the scheduled shutdown
+func (m *MockLogindClient) CancelScheduledShutdown() (bool, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	if m.ShouldError {
+		return false, &MockError{Message: "mock cancel shutdown error"}
+	}
+	pending := m.ShutdownKind != ""
+	m.ShutdownKind = ""
+	m.ShutdownAt = time.Time{}
+	return pending, nil
+}
+
 // MockNotifier is a test implementation of desktop notifications
 type MockNotifier struct {
 	Notifications []MockNotification
@@ -0,0 +1,189 @@
+package dbus
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// startSessionBus runs a private dbus-daemon and returns its address
+func startSessionBus(t *testing.T) string {
+	t.Helper()
+	address, _ := startBus(t, "")
+	return address
+}
+
+// startBus runs a private dbus-daemon listening on address, or on a
+// temporary address if empty, and returns the address and a function that
+// stops the daemon
+func startBus(t *testing.T, address string) (string, func()) {
+	t.Helper()
+	return startBusAs(t, address, "")
+}
+
+// startBusAs is startBus with the daemon running as username, or as the
+// test's own user if empty
+func startBusAs(t *testing.T, address, username string) (string, func()) {
+	t.Helper()
+
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+
+	args := []string{"--session", "--nofork", "--print-address=1"}
+	if address != "" {
+		args = append(args, "--address="+address)
+	}
+
+	cmd := exec.Command(daemon, args...)
+	if username != "" {
+		cmd = exec.Command("runuser", append([]string{"-u", username, "--", "dbus-daemon"}, args...)...)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("Failed to get dbus-daemon output: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start dbus-daemon: %v", err)
+	}
+
+	stopped := false
+	stop := func() {
+		if !stopped {
+			stopped = true
+			cmd.Process.Signal(syscall.SIGTERM)
+			cmd.Wait()
+		}
+	}
+	t.Cleanup(stop)
+
+	printed, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read bus address: %v", err)
+	}
+	if address == "" {
+		address = strings.TrimSpace(printed)
+	}
+	return address, stop
+}
+
+type fakeScreenSaver struct {
+	locked atomic.Bool
+}
+
+func (f *fakeScreenSaver) Lock() *dbus.Error {
+	f.locked.Store(true)
+	return nil
+}
+
+// fakeCinnamonScreenSaver takes the away message Cinnamon's Lock expects
+type fakeCinnamonScreenSaver struct {
+	fakeScreenSaver
+}
+
+func (f *fakeCinnamonScreenSaver) Lock(message string) *dbus.Error {
+	f.locked.Store(true)
+	return nil
+}
+
+func TestLockScreen(t *testing.T) {
+	address := startSessionBus(t)
+
+	if _, err := LockScreen(address); !errors.Is(err, ErrNoScreenSaver) {
+		t.Fatalf("Expected ErrNoScreenSaver on an empty bus, got %v", err)
+	}
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	saver := &fakeScreenSaver{}
+	conn.Export(saver, "/org/gnome/ScreenSaver", "org.gnome.ScreenSaver")
+	if _, err := conn.RequestName("org.gnome.ScreenSaver", dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatalf("Failed to request name: %v", err)
+	}
+
+	service, err := LockScreen(address)
+	if err != nil {
+		t.Fatalf("LockScreen failed: %v", err)
+	}
+	if service != "org.gnome.ScreenSaver" || !saver.locked.Load() {
+		t.Errorf("Expected GNOME screen saver to lock, got %q", service)
+	}
+}
+
+// requireRunuser skips tests that switch users unless they run as root
+func requireRunuser(t *testing.T) {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("switching users needs root")
+	}
+	for _, tool := range []string{"runuser", "dbus-send"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s not installed", tool)
+		}
+	}
+}
+
+func TestLockScreenAs(t *testing.T) {
+	requireRunuser(t)
+	address := startSessionBus(t)
+
+	conn, err := dbus.Connect(address)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	saver := &fakeCinnamonScreenSaver{}
+	conn.Export(saver, "/org/cinnamon/ScreenSaver", "org.cinnamon.ScreenSaver")
+	if _, err := conn.RequestName("org.cinnamon.ScreenSaver", dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatalf("Failed to request name: %v", err)
+	}
+
+	service, err := LockScreenAs("root", address)
+	if err != nil {
+		t.Fatalf("LockScreenAs failed: %v", err)
+	}
+	if service != "org.cinnamon.ScreenSaver" || !saver.locked.Load() {
+		t.Errorf("Expected Cinnamon screen saver to lock, got %q", service)
+	}
+}
+
+func TestLockScreenOnAnotherUsersBus(t *testing.T) {
+	requireRunuser(t)
+	if _, err := user.Lookup("nobody"); err != nil {
+		t.Skip("no nobody user")
+	}
+
+	// The bus belongs to nobody, like a child's session bus belongs to the
+	// child while the daemon runs as root
+	dir, err := os.MkdirTemp("", "bus")
+	if err != nil {
+		t.Fatalf("Failed to create bus directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	os.Chmod(dir, 0777)
+	address, _ := startBusAs(t, "unix:path="+filepath.Join(dir, "bus"), "nobody")
+
+	if _, err := LockScreen(address); err == nil || errors.Is(err, ErrNoScreenSaver) {
+		t.Fatalf("Expected root to be refused by nobody's bus, got %v", err)
+	}
+
+	// As the bus's user, the bus is reached and found to have no locker
+	if _, err := LockScreenAs("nobody", address); !errors.Is(err, ErrNoScreenSaver) {
+		t.Errorf("Expected ErrNoScreenSaver through nobody, got %v", err)
+	}
+}
@@ -2,13 +2,20 @@ package notifier
 
 import (
 	"context"
+	"sync"
 )
 
-// MockNotifier is a test implementation of Notifier
+// MockNotifier is a test implementation of Notifier. Hold Mu to read its
+// calls while it is shared with other goroutines.
 type MockNotifier struct {
+	Mu sync.Mutex
+
 	WarningCalls    []WarningCall
 	LockCalls       []string
 	ExtensionCalls  []ExtensionCall
+	DecisionCalls   []DecisionCall
+	MessageCalls    []MessageCall
+	AlertCalls      []AlertCall
 	ShouldFailAfter int
 	callCount       int
 }
@@ -23,15 +30,38 @@ type ExtensionCall struct {
 	Minutes  int
 }
 
+type DecisionCall struct {
+	Username string
+	Status   string
+	Minutes  int
+}
+
+type MessageCall struct {
+	Username string
+	Title    string
+	Body     string
+}
+
+type AlertCall struct {
+	Username string
+	Message  string
+}
+
 func NewMockNotifier() *MockNotifier {
 	return &MockNotifier{
 		WarningCalls:   make([]WarningCall, 0),
 		LockCalls:      make([]string, 0),
 		ExtensionCalls: make([]ExtensionCall, 0),
+		DecisionCalls:  make([]DecisionCall, 0),
+		MessageCalls:   make([]MessageCall, 0),
+		AlertCalls:     make([]AlertCall, 0),
 	}
 }
 
 func (m *MockNotifier) SendWarning(ctx context.Context, username string, minutesLeft int) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
 	m.callCount++
 	if m.ShouldFailAfter > 0 && m.callCount > m.ShouldFailAfter {
 		return &MockError{Message: "mock warning error"}
@@ -41,6 +71,9 @@ func (m *MockNotifier) SendWarning(ctx context.Context, username string, minutes
 }
 
 func (m *MockNotifier) SendLockNotice(ctx context.Context, username string) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
 	m.callCount++
 	if m.ShouldFailAfter > 0 && m.callCount > m.ShouldFailAfter {
 		return &MockError{Message: "mock lock error"}
@@ -50,6 +83,9 @@ func (m *MockNotifier) SendLockNotice(ctx context.Context, username string) erro
 }
 
 func (m *MockNotifier) SendTimeExtended(ctx context.Context, username string, minutes int) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
 	m.callCount++
 	if m.ShouldFailAfter > 0 && m.callCount > m.ShouldFailAfter {
 		return &MockError{Message: "mock extension error"}
@@ -58,6 +94,42 @@ func (m *MockNotifier) SendTimeExtended(ctx context.Context, username string, mi
 	return nil
 }
 
+func (m *MockNotifier) SendRequestDecision(ctx context.Context, username string, status string, minutes int) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.callCount++
+	if m.ShouldFailAfter > 0 && m.callCount > m.ShouldFailAfter {
+		return &MockError{Message: "mock decision error"}
+	}
+	m.DecisionCalls = append(m.DecisionCalls, DecisionCall{username, status, minutes})
+	return nil
+}
+
+func (m *MockNotifier) SendMessage(ctx context.Context, username, title, body string) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.callCount++
+	if m.ShouldFailAfter > 0 && m.callCount > m.ShouldFailAfter {
+		return &MockError{Message: "mock message error"}
+	}
+	m.MessageCalls = append(m.MessageCalls, MessageCall{username, title, body})
+	return nil
+}
+
+func (m *MockNotifier) SendParentAlert(ctx context.Context, username, message string) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.callCount++
+	if m.ShouldFailAfter > 0 && m.callCount > m.ShouldFailAfter {
+		return &MockError{Message: "mock alert error"}
+	}
+	m.AlertCalls = append(m.AlertCalls, AlertCall{username, message})
+	return nil
+}
+
 type MockError struct {
 	Message string
 }
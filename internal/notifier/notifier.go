@@ -15,6 +15,9 @@ type Notifier interface {
 	SendWarning(ctx context.Context, username string, minutesLeft int) error
 	SendLockNotice(ctx context.Context, username string) error
 	SendTimeExtended(ctx context.Context, username string, minutes int) error
+	SendRequestDecision(ctx context.Context, username string, status string, minutes int) error
+	SendMessage(ctx context.Context, username, title, body string) error
+	SendParentAlert(ctx context.Context, username, message string) error
 }
 
 // Chain combines multiple notifiers, sending to all of them
@@ -68,6 +71,43 @@ func (c *Chain) SendTimeExtended(ctx context.Context, username string, minutes i
 	return lastErr
 }
 
+// SendRequestDecision sends the outcome of a time request through all notifiers
+func (c *Chain) SendRequestDecision(ctx context.Context, username string, status string, minutes int) error {
+	var lastErr error
+	for _, n := range c.notifiers {
+		if err := n.SendRequestDecision(ctx, username, status, minutes); err != nil {
+			log.Printf("Request decision notification failed: %v", err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// SendMessage sends a free-form message through all notifiers
+func (c *Chain) SendMessage(ctx context.Context, username, title, body string) error {
+	var lastErr error
+	for _, n := range c.notifiers {
+		if err := n.SendMessage(ctx, username, title, body); err != nil {
+			log.Printf("Message notification failed: %v", err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// SendParentAlert alerts the parents through all notifiers. username is the
+// child the alert is about, or empty for alerts about the whole household.
+func (c *Chain) SendParentAlert(ctx context.Context, username, message string) error {
+	var lastErr error
+	for _, n := range c.notifiers {
+		if err := n.SendParentAlert(ctx, username, message); err != nil {
+			log.Printf("Parent alert failed: %v", err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
 // DBusNotifier sends desktop notifications via D-Bus
 type DBusNotifier struct {
 	notifier *dbus.Notifier
@@ -99,6 +139,37 @@ func (d *DBusNotifier) SendTimeExtended(ctx context.Context, username string, mi
 		"normal")
 }
 
+// SendRequestDecision sends a desktop notification with the answer to a time request
+func (d *DBusNotifier) SendRequestDecision(ctx context.Context, username string, status string, minutes int) error {
+	summary, body := requestDecisionMessage(status, minutes)
+	return sendNotifyAsUser(username, summary, body, "normal")
+}
+
+// SendMessage sends a free-form desktop notification
+func (d *DBusNotifier) SendMessage(ctx context.Context, username, title, body string) error {
+	return sendNotifyAsUser(username, title, body, "normal")
+}
+
+// SendParentAlert does nothing: a desktop notification would reach the
+// child, not the parents. The LogNotifier in the chain writes the alert to
+// the journal.
+func (d *DBusNotifier) SendParentAlert(ctx context.Context, username, message string) error {
+	return nil
+}
+
+func requestDecisionMessage(status string, minutes int) (string, string) {
+	switch status {
+	case "approved":
+		return "Request Approved", fmt.Sprintf("Your request was approved: %d extra minutes.", minutes)
+	case "denied":
+		return "Request Denied", "Your request for more screen time was denied."
+	case "expired":
+		return "Request Expired", "Nobody answered your request for more screen time in time."
+	default:
+		return "Request Update", fmt.Sprintf("Your request for more screen time is %s.", status)
+	}
+}
+
 func getUrgency(minutesLeft int) string {
 	if minutesLeft <= 1 {
 		return "critical"
@@ -148,3 +219,25 @@ func (l *LogNotifier) SendTimeExtended(ctx context.Context, username string, min
 	log.Printf("[NOTIFY] User %s: Time extended by %d minutes", username, minutes)
 	return nil
 }
+
+// SendRequestDecision logs the outcome of a time request
+func (l *LogNotifier) SendRequestDecision(ctx context.Context, username string, status string, minutes int) error {
+	log.Printf("[NOTIFY] User %s: Time request %s (%d minutes)", username, status, minutes)
+	return nil
+}
+
+// SendMessage logs a free-form message
+func (l *LogNotifier) SendMessage(ctx context.Context, username, title, body string) error {
+	log.Printf("[NOTIFY] User %s: %s: %s", username, title, body)
+	return nil
+}
+
+// SendParentAlert logs an alert for the parents
+func (l *LogNotifier) SendParentAlert(ctx context.Context, username, message string) error {
+	if username == "" {
+		log.Printf("[ALERT] %s", message)
+		return nil
+	}
+	log.Printf("[ALERT] User %s: %s", username, message)
+	return nil
+}
@@ -42,6 +42,67 @@ func TestLogNotifier(t *testing.T) {
 	if err != nil {
 		t.Errorf("Expected no error from LogNotifier, got %v", err)
 	}
+
+	err = notifier.SendRequestDecision(ctx, "testuser", "approved", 15)
+	if err != nil {
+		t.Errorf("Expected no error from LogNotifier, got %v", err)
+	}
+
+	err = notifier.SendMessage(ctx, "testuser", "Screen Time", "Bedtime")
+	if err != nil {
+		t.Errorf("Expected no error from LogNotifier, got %v", err)
+	}
+}
+
+func TestChainSendMessage(t *testing.T) {
+	mock := NewMockNotifier()
+	chain := NewChain(mock)
+
+	if err := chain.SendMessage(context.Background(), "testuser", "Screen Time", "Bedtime"); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	if len(mock.MessageCalls) != 1 || mock.MessageCalls[0].Body != "Bedtime" {
+		t.Errorf("Expected one message call, got %v", mock.MessageCalls)
+	}
+}
+
+func TestChainSendParentAlert(t *testing.T) {
+	mock := NewMockNotifier()
+	chain := NewChain(mock, NewLogNotifier())
+
+	if err := chain.SendParentAlert(context.Background(), "testuser", "Lock failed"); err != nil {
+		t.Fatalf("SendParentAlert failed: %v", err)
+	}
+
+	if len(mock.AlertCalls) != 1 || mock.AlertCalls[0].Message != "Lock failed" {
+		t.Errorf("Expected one alert call, got %v", mock.AlertCalls)
+	}
+}
+
+func TestChainSendRequestDecision(t *testing.T) {
+	mock := NewMockNotifier()
+	chain := NewChain(mock)
+
+	if err := chain.SendRequestDecision(context.Background(), "testuser", "denied", 0); err != nil {
+		t.Fatalf("SendRequestDecision failed: %v", err)
+	}
+
+	if len(mock.DecisionCalls) != 1 || mock.DecisionCalls[0].Status != "denied" {
+		t.Errorf("Expected one denied decision call, got %v", mock.DecisionCalls)
+	}
+}
+
+func TestRequestDecisionMessage(t *testing.T) {
+	summary, body := requestDecisionMessage("approved", 20)
+	if summary != "Request Approved" || body != "Your request was approved: 20 extra minutes." {
+		t.Errorf("Unexpected approved message: %q / %q", summary, body)
+	}
+
+	summary, _ = requestDecisionMessage("expired", 0)
+	if summary != "Request Expired" {
+		t.Errorf("Unexpected expired summary: %q", summary)
+	}
 }
 
 func TestGetUrgency(t *testing.T) {
@@ -0,0 +1,184 @@
+package rules
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Facts is a snapshot of a user's state that rules are evaluated against
+type Facts struct {
+	Username      string
+	Now           time.Time
+	UsedMins      int
+	RemainingMins int
+	SessionTypes  []string
+	Apps          []string // names of the user's running processes
+	OverrideLabel string   // label of today's date override, if any
+	HasOverride   bool
+}
+
+// Engine evaluates a ruleset and remembers which once-per-day actions have
+// already fired
+type Engine struct {
+	rules []*Rule
+
+	mu    sync.Mutex
+	fired map[string]string // rule name + user -> date
+}
+
+// NewEngine creates an engine for a validated ruleset
+func NewEngine(rs *Ruleset) *Engine {
+	return &Engine{
+		rules: rs.Rules,
+		fired: make(map[string]string),
+	}
+}
+
+// Rules returns the loaded rules
+func (e *Engine) Rules() []*Rule {
+	return e.rules
+}
+
+// Match is a rule that applies to a user on this tick
+type Match struct {
+	Rule *Rule
+	// First is true the first time the rule matches for the user on a day
+	First bool
+}
+
+// Evaluate returns the rules whose actions should be applied for these
+// facts, in file order. Once-per-day actions are returned only the first
+// time they match on a given day.
+func (e *Engine) Evaluate(f Facts) []Match {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	date := f.Now.Format("2006-01-02")
+
+	var matches []Match
+	for _, rule := range e.rules {
+		if !rule.Matches(f) {
+			continue
+		}
+
+		key := rule.Name + "\x00" + f.Username
+		first := e.fired[key] != date
+		e.fired[key] = date
+
+		if rule.Then.Once() && !first {
+			continue
+		}
+
+		matches = append(matches, Match{Rule: rule, First: first})
+	}
+
+	return matches
+}
+
+// LoginAllowed reports whether a deny_login rule refuses a login for these
+// facts, and if so which one
+func (e *Engine) LoginAllowed(f Facts) (bool, *Rule) {
+	for _, rule := range e.rules {
+		if rule.Then.Action == ActionDenyLogin && rule.Matches(f) {
+			return false, rule
+		}
+	}
+	return true, nil
+}
+
+// NextBoundary returns the first time after now at which a time or weekday
+// condition of the user's rules can change, or zero if none has one. Rules
+// are only evaluated when the user's loop runs, so it wakes up then.
+func (e *Engine) NextBoundary(username string, now time.Time) time.Time {
+	var next time.Time
+	for _, rule := range e.rules {
+		if len(rule.Users) > 0 && !contains(rule.Users, username) {
+			continue
+		}
+
+		c := &rule.When
+		if len(c.weekdays) == 0 && c.After == "" && c.Before == "" {
+			continue
+		}
+
+		// Midnight is a boundary too: the weekday changes, and a window
+		// ending at 24:00 ends then
+		for _, mins := range []int{c.after, c.before, 24 * 60} {
+			at := time.Date(now.Year(), now.Month(), now.Day(), 0, mins, 0, 0, now.Location())
+			if !at.After(now) {
+				at = time.Date(now.Year(), now.Month(), now.Day()+1, 0, mins, 0, 0, now.Location())
+			}
+			if next.IsZero() || at.Before(next) {
+				next = at
+			}
+		}
+	}
+
+	return next
+}
+
+// Matches reports whether the rule applies to the facts
+func (r *Rule) Matches(f Facts) bool {
+	if len(r.Users) > 0 && !contains(r.Users, f.Username) {
+		return false
+	}
+
+	c := &r.When
+
+	if len(c.weekdays) > 0 && !c.weekdays[int(f.Now.Weekday())] {
+		return false
+	}
+
+	mins := f.Now.Hour()*60 + f.Now.Minute()
+	if mins < c.after || mins >= c.before {
+		return false
+	}
+
+	if c.UsedAtLeast != nil && f.UsedMins < *c.UsedAtLeast {
+		return false
+	}
+	if c.UsedBelow != nil && f.UsedMins >= *c.UsedBelow {
+		return false
+	}
+	if c.RemainingBelow != nil && f.RemainingMins >= *c.RemainingBelow {
+		return false
+	}
+	if c.RemainingAtLeast != nil && f.RemainingMins < *c.RemainingAtLeast {
+		return false
+	}
+
+	if len(c.SessionTypes) > 0 && !containsAny(c.SessionTypes, f.SessionTypes) {
+		return false
+	}
+	if len(c.AppsRunning) > 0 && !containsAny(c.AppsRunning, f.Apps) {
+		return false
+	}
+
+	if c.Override != nil && *c.Override != f.HasOverride {
+		return false
+	}
+	if c.OverrideLabel != "" && !strings.EqualFold(c.OverrideLabel, f.OverrideLabel) {
+		return false
+	}
+
+	return true
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(list, values []string) bool {
+	for _, value := range values {
+		if contains(list, value) {
+			return true
+		}
+	}
+	return false
+}
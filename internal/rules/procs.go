@@ -0,0 +1,63 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// RunningApps returns the distinct process names (as in /proc/<pid>/comm)
+// of all processes owned by uid
+func RunningApps(uid uint32) ([]string, error) {
+	return runningApps("/proc", uid)
+}
+
+func runningApps(procDir string, uid uint32) ([]string, error) {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil, err
+	}
+
+	want := strconv.FormatUint(uint64(uid), 10)
+	seen := make(map[string]bool)
+	var apps []string
+
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+
+		// Processes may exit while we look at them; skip those
+		status, err := os.ReadFile(filepath.Join(procDir, entry.Name(), "status"))
+		if err != nil || processUID(string(status)) != want {
+			continue
+		}
+
+		comm, err := os.ReadFile(filepath.Join(procDir, entry.Name(), "comm"))
+		if err != nil {
+			continue
+		}
+
+		name := strings.TrimSpace(string(comm))
+		if name != "" && !seen[name] {
+			seen[name] = true
+			apps = append(apps, name)
+		}
+	}
+
+	return apps, nil
+}
+
+// processUID returns the real UID from the contents of /proc/<pid>/status
+func processUID(status string) string {
+	for _, line := range strings.Split(status, "\n") {
+		if rest, ok := strings.CutPrefix(line, "Uid:"); ok {
+			fields := strings.Fields(rest)
+			if len(fields) > 0 {
+				return fields[0]
+			}
+		}
+	}
+	return ""
+}
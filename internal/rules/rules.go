@@ -0,0 +1,198 @@
+// Package rules implements a small declarative rule engine. Rules are loaded
+// from YAML, validated up front and evaluated against a snapshot of a user's
+// state on every scheduler tick.
+package rules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/florian/screentime-guardian/internal/clock"
+)
+
+// Actions a rule can take when its conditions match
+const (
+	ActionLock      = "lock"       // lock the user's sessions
+	ActionWarn      = "warn"       // show a message, once per day
+	ActionTerminate = "terminate"  // end the user's sessions
+	ActionGrant     = "grant"      // grant extra minutes, once per day
+	ActionDenyLogin = "deny_login" // refuse logins and end existing sessions
+)
+
+// Ruleset is the top level of a rules file
+type Ruleset struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// Rule pairs a set of conditions with an action. All conditions must hold
+// for the rule to match.
+type Rule struct {
+	Name  string    `yaml:"name"`
+	Users []string  `yaml:"users"` // empty applies to every user
+	When  Condition `yaml:"when"`
+	Then  Action    `yaml:"then"`
+}
+
+// Condition lists what must be true for a rule to match. Unset fields are
+// ignored.
+type Condition struct {
+	Weekdays         []string `yaml:"weekdays"` // mon, tue, ...
+	After            string   `yaml:"after"`    // HH:MM, inclusive
+	Before           string   `yaml:"before"`   // HH:MM, exclusive
+	UsedAtLeast      *int     `yaml:"used_at_least"`
+	UsedBelow        *int     `yaml:"used_below"`
+	RemainingBelow   *int     `yaml:"remaining_below"`
+	RemainingAtLeast *int     `yaml:"remaining_at_least"`
+	SessionTypes     []string `yaml:"session_types"` // x11, wayland, tty, ...
+	AppsRunning      []string `yaml:"apps_running"`  // process names, any of
+	Override         *bool    `yaml:"override"`      // a date override is in effect
+	OverrideLabel    string   `yaml:"override_label"`
+
+	// Set by validate: the weekdays as time.Weekday values and the time
+	// window in minutes since midnight, the whole day when unset
+	weekdays map[int]bool
+	after    int
+	before   int
+}
+
+// Action is what happens when a rule matches
+type Action struct {
+	Action  string `yaml:"action"`
+	Minutes int    `yaml:"minutes"` // for grant
+	Message string `yaml:"message"` // for warn, optional for the others
+}
+
+// Once reports whether the action fires only once per user and day rather
+// than on every tick while the rule matches
+func (a Action) Once() bool {
+	return a.Action == ActionGrant || a.Action == ActionWarn
+}
+
+// Load reads and validates a rules file
+func Load(path string) (*Ruleset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rs, nil
+}
+
+// Parse decodes and validates rules from YAML. Unknown keys are rejected so
+// that typos do not silently disable a condition.
+func Parse(r io.Reader) (*Ruleset, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+
+	rs := &Ruleset{}
+	if err := dec.Decode(rs); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse rules: %w", err)
+	}
+
+	if err := rs.Validate(); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// Validate checks every rule and prepares its conditions for evaluation
+func (rs *Ruleset) Validate() error {
+	seen := make(map[string]bool)
+
+	for i, rule := range rs.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("rule %d: name is required", i+1)
+		}
+		if seen[rule.Name] {
+			return fmt.Errorf("rule %q: duplicate name", rule.Name)
+		}
+		seen[rule.Name] = true
+
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Rule) validate() error {
+	c := &r.When
+
+	c.weekdays = make(map[int]bool)
+	for _, day := range c.Weekdays {
+		d, ok := weekdayNames[strings.ToLower(day)]
+		if !ok {
+			return fmt.Errorf("unknown weekday %q", day)
+		}
+		c.weekdays[d] = true
+	}
+
+	c.after, c.before = 0, 24*60
+	if c.After != "" {
+		mins, err := clock.Parse(c.After)
+		if err != nil {
+			return fmt.Errorf("after: %w", err)
+		}
+		c.after = mins
+	}
+	if c.Before != "" {
+		mins, err := clock.Parse(c.Before)
+		if err != nil {
+			return fmt.Errorf("before: %w", err)
+		}
+		c.before = mins
+	}
+	if c.after >= c.before {
+		return fmt.Errorf("after (%s) must be earlier than before (%s)", c.After, c.Before)
+	}
+
+	for name, value := range map[string]*int{
+		"used_at_least":      c.UsedAtLeast,
+		"used_below":         c.UsedBelow,
+		"remaining_below":    c.RemainingBelow,
+		"remaining_at_least": c.RemainingAtLeast,
+	} {
+		if value != nil && *value < 0 {
+			return fmt.Errorf("%s cannot be negative", name)
+		}
+	}
+
+	switch r.Then.Action {
+	case ActionLock, ActionTerminate, ActionDenyLogin:
+	case ActionWarn:
+		if r.Then.Message == "" {
+			return errors.New("warn needs a message")
+		}
+	case ActionGrant:
+		if r.Then.Minutes < 1 || r.Then.Minutes > 1440 {
+			return errors.New("grant needs minutes between 1 and 1440")
+		}
+	case "":
+		return errors.New("then.action is required")
+	default:
+		return fmt.Errorf("unknown action %q", r.Then.Action)
+	}
+
+	return nil
+}
+
+var weekdayNames = map[string]int{
+	"sun": 0, "sunday": 0,
+	"mon": 1, "monday": 1,
+	"tue": 2, "tuesday": 2,
+	"wed": 3, "wednesday": 3,
+	"thu": 4, "thursday": 4,
+	"fri": 5, "friday": 5,
+	"sat": 6, "saturday": 6,
+}
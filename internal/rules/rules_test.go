@@ -0,0 +1,246 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testRules = `
+rules:
+  - name: friday-bonus
+    when:
+      weekdays: [fri]
+    then:
+      action: grant
+      minutes: 30
+
+  - name: bedtime
+    users: [alice]
+    when:
+      after: "20:00"
+    then:
+      action: lock
+      message: Bedtime
+
+  - name: low-time-warning
+    when:
+      remaining_below: 10
+      remaining_at_least: 1
+    then:
+      action: warn
+      message: Save your game
+
+  - name: no-games-in-the-morning
+    when:
+      before: "12:00"
+      apps_running: [steam]
+      override: false
+    then:
+      action: terminate
+
+  - name: no-console
+    when:
+      session_types: [tty]
+    then:
+      action: deny_login
+`
+
+// at returns a time on Friday 2024-12-20 (or a later day)
+func at(day, hour, min int) time.Time {
+	return time.Date(2024, 12, 20+day, hour, min, 0, 0, time.Local)
+}
+
+func names(matches []Match) string {
+	var list []string
+	for _, m := range matches {
+		list = append(list, m.Rule.Name)
+	}
+	return strings.Join(list, ",")
+}
+
+func TestParseValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"valid", testRules, ""},
+		{"empty", "", ""},
+		{"missing name", "rules:\n  - then: {action: lock}\n", "name is required"},
+		{"duplicate", "rules:\n  - {name: a, then: {action: lock}}\n  - {name: a, then: {action: lock}}\n", "duplicate"},
+		{"unknown action", "rules:\n  - {name: a, then: {action: shutdown}}\n", "unknown action"},
+		{"missing action", "rules:\n  - {name: a}\n", "then.action is required"},
+		{"grant without minutes", "rules:\n  - {name: a, then: {action: grant}}\n", "minutes"},
+		{"warn without message", "rules:\n  - {name: a, then: {action: warn}}\n", "message"},
+		{"bad weekday", "rules:\n  - {name: a, when: {weekdays: [funday]}, then: {action: lock}}\n", "weekday"},
+		{"bad time", "rules:\n  - {name: a, when: {after: '8pm'}, then: {action: lock}}\n", "HH:MM"},
+		{"reversed times", "rules:\n  - {name: a, when: {after: '20:00', before: '08:00'}, then: {action: lock}}\n", "earlier"},
+		{"negative", "rules:\n  - {name: a, when: {used_below: -1}, then: {action: lock}}\n", "negative"},
+		{"unknown key", "rules:\n  - {name: a, when: {weekday: [mon]}, then: {action: lock}}\n", "weekday"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.yaml))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestExampleRulesFile(t *testing.T) {
+	rs, err := Load(filepath.Join("..", "..", "configs", "rules.yaml.example"))
+	if err != nil {
+		t.Fatalf("Example rules do not validate: %v", err)
+	}
+	if len(rs.Rules) == 0 {
+		t.Error("Expected example rules")
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	rs, err := Parse(strings.NewReader(testRules))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	engine := NewEngine(rs)
+
+	tests := []struct {
+		name  string
+		facts Facts
+		want  string
+	}{
+		{"friday grant", Facts{Username: "bob", Now: at(0, 10, 0), RemainingMins: 60}, "friday-bonus"},
+		{"grant only once a day", Facts{Username: "bob", Now: at(0, 11, 0), RemainingMins: 60}, ""},
+		{"grant for other user", Facts{Username: "alice", Now: at(0, 11, 0), RemainingMins: 60}, "friday-bonus"},
+		{"not on saturday", Facts{Username: "bob", Now: at(1, 10, 0), RemainingMins: 60}, ""},
+		{"bedtime for alice", Facts{Username: "alice", Now: at(1, 20, 0), RemainingMins: 60}, "bedtime"},
+		{"bedtime repeats", Facts{Username: "alice", Now: at(1, 20, 1), RemainingMins: 60}, "bedtime"},
+		{"no bedtime for bob", Facts{Username: "bob", Now: at(1, 20, 0), RemainingMins: 60}, ""},
+		{"low time", Facts{Username: "bob", Now: at(1, 15, 0), RemainingMins: 5}, "low-time-warning"},
+		{"no warning at zero", Facts{Username: "carol", Now: at(1, 15, 0), RemainingMins: 0}, ""},
+		{"steam in the morning", Facts{Username: "bob", Now: at(1, 9, 0), RemainingMins: 60, Apps: []string{"bash", "Steam"}}, "no-games-in-the-morning"},
+		{"steam on holiday", Facts{Username: "bob", Now: at(1, 9, 0), RemainingMins: 60, Apps: []string{"steam"}, HasOverride: true}, ""},
+		{"steam in the afternoon", Facts{Username: "bob", Now: at(1, 13, 0), RemainingMins: 60, Apps: []string{"steam"}}, ""},
+		{"console session", Facts{Username: "bob", Now: at(1, 13, 0), RemainingMins: 60, SessionTypes: []string{"tty"}}, "no-console"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := names(engine.Evaluate(tt.facts)); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	// Once-per-day actions fire again on the next Friday
+	if got := names(engine.Evaluate(Facts{Username: "bob", Now: at(7, 10, 0), RemainingMins: 60})); got != "friday-bonus" {
+		t.Errorf("Expected grant on the next Friday, got %q", got)
+	}
+}
+
+func TestMatchFirst(t *testing.T) {
+	rs, _ := Parse(strings.NewReader(testRules))
+	engine := NewEngine(rs)
+
+	facts := Facts{Username: "alice", Now: at(1, 20, 0), RemainingMins: 60}
+	first := engine.Evaluate(facts)
+	facts.Now = at(1, 20, 5)
+	second := engine.Evaluate(facts)
+
+	if len(first) != 1 || !first[0].First {
+		t.Errorf("Expected first bedtime match to be marked first, got %+v", first)
+	}
+	if len(second) != 1 || second[0].First {
+		t.Errorf("Expected repeated bedtime match not to be marked first, got %+v", second)
+	}
+}
+
+func TestNextBoundary(t *testing.T) {
+	rs, _ := Parse(strings.NewReader(testRules))
+	engine := NewEngine(rs)
+
+	tests := []struct {
+		username string
+		now      time.Time
+		want     time.Time
+	}{
+		{"alice", at(0, 10, 0), at(0, 12, 0)}, // no-games ends
+		{"alice", at(0, 12, 0), at(0, 20, 0)}, // bedtime starts
+		{"alice", at(0, 20, 0), at(1, 0, 0)},  // friday-bonus ends
+		{"bob", at(0, 12, 30), at(1, 0, 0)},   // bedtime is alice's only
+		{"alice", at(1, 23, 59), at(2, 0, 0)}, // the next day
+	}
+
+	for _, tt := range tests {
+		if got := engine.NextBoundary(tt.username, tt.now); !got.Equal(tt.want) {
+			t.Errorf("NextBoundary(%s, %v) = %v, want %v", tt.username, tt.now, got, tt.want)
+		}
+	}
+
+	// Rules on usage alone never need a wake-up of their own
+	usage, _ := Parse(strings.NewReader(`
+rules:
+  - name: low-time-warning
+    when:
+      remaining_below: 10
+    then:
+      action: warn
+      message: Save your game
+`))
+	if got := NewEngine(usage).NextBoundary("alice", at(0, 10, 0)); !got.IsZero() {
+		t.Errorf("Expected no boundary without time conditions, got %v", got)
+	}
+}
+
+func TestLoginAllowed(t *testing.T) {
+	rs, _ := Parse(strings.NewReader(testRules))
+	engine := NewEngine(rs)
+
+	if ok, _ := engine.LoginAllowed(Facts{Username: "bob", Now: at(1, 13, 0), SessionTypes: []string{"x11"}}); !ok {
+		t.Error("Expected graphical login to be allowed")
+	}
+
+	ok, rule := engine.LoginAllowed(Facts{Username: "bob", Now: at(1, 13, 0), SessionTypes: []string{"tty"}})
+	if ok || rule == nil || rule.Name != "no-console" {
+		t.Errorf("Expected console login to be denied by no-console, got %v, %v", ok, rule)
+	}
+}
+
+func TestRunningApps(t *testing.T) {
+	procDir := t.TempDir()
+
+	procs := []struct {
+		pid, uid, comm string
+	}{
+		{"100", "1000", "bash"},
+		{"101", "1000", "steam"},
+		{"102", "1000", "bash"},
+		{"200", "1001", "firefox"},
+	}
+	for _, p := range procs {
+		dir := filepath.Join(procDir, p.pid)
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, "status"), []byte("Name:\t"+p.comm+"\nUid:\t"+p.uid+"\t"+p.uid+"\t"+p.uid+"\t"+p.uid+"\n"), 0644)
+		os.WriteFile(filepath.Join(dir, "comm"), []byte(p.comm+"\n"), 0644)
+	}
+	os.MkdirAll(filepath.Join(procDir, "self"), 0755)
+
+	apps, err := runningApps(procDir, 1000)
+	if err != nil {
+		t.Fatalf("runningApps failed: %v", err)
+	}
+	if strings.Join(apps, ",") != "bash,steam" {
+		t.Errorf("Expected bash,steam, got %v", apps)
+	}
+}
@@ -0,0 +1,143 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/dbus"
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+// minUserUID is the first UID of regular accounts; lower ones belong to
+// system users such as the display manager's greeter
+const minUserUID = 1000
+
+// checkDegraded applies each user's degraded mode while the users or the
+// sessions cannot be read, going by the last ones known. storeOK reports
+// whether the database can be used.
+func (s *Scheduler) checkDegraded(ctx context.Context, now time.Time, users []*storage.User, sessions []dbus.Session, storeOK bool, elapsed time.Duration, accountedAt time.Time) {
+	if !storeOK && !s.usersListed {
+		s.lockUnlisted(sessions)
+		return
+	}
+
+	userSessions := make(map[string][]dbus.Session)
+	for _, session := range sessions {
+		userSessions[session.UserName] = append(userSessions[session.UserName], session)
+	}
+
+	enabled := make(map[string]bool)
+	for _, user := range users {
+		if !user.Enabled {
+			continue
+		}
+		enabled[user.Username] = true
+		sessions := userSessions[user.Username]
+
+		switch user.DegradedMode {
+		case storage.DegradedClosed:
+			s.updateUserLoop(ctx, user, sessions, false, accountedAt)
+			s.lockDegraded(user.Username, sessions)
+		case storage.DegradedCount:
+			s.countDegraded(ctx, user, sessions, now, storeOK, elapsed, accountedAt)
+		default:
+			// Fail open: nothing is counted and the loop stops enforcing
+			s.updateUserLoop(ctx, user, sessions, false, accountedAt)
+		}
+	}
+
+	s.stopUserLoops(enabled)
+}
+
+// lockDegraded locks a fail-closed user's sessions on every check, so that
+// unlocking doesn't help. Where logind cannot lock a session, the desktop's
+// screen locker on the user's own session bus may still be reachable.
+func (s *Scheduler) lockDegraded(username string, sessions []dbus.Session) {
+	if len(sessions) == 0 {
+		return
+	}
+
+	first := !s.degradedLocked[username]
+	s.degradedLocked[username] = true
+	if first {
+		log.Printf("Locking sessions of %s while enforcement is degraded", username)
+	}
+
+	for _, session := range sessions {
+		if err := s.logind.LockSession(session.ID); err == nil {
+			continue
+		}
+		if _, err := s.logind.LockScreen(session); err != nil && first {
+			log.Printf("Failed to lock session %s of %s: %v", session.ID, username, err)
+		}
+	}
+}
+
+// lockUnlisted locks every session of a regular account while the users
+// have not been read since startup. Nobody's degraded mode is known then,
+// so nobody can be assumed to be allowed in.
+func (s *Scheduler) lockUnlisted(sessions []dbus.Session) {
+	userSessions := make(map[string][]dbus.Session)
+	for _, session := range sessions {
+		if session.UserID < minUserUID {
+			continue
+		}
+		userSessions[session.UserName] = append(userSessions[session.UserName], session)
+	}
+
+	for username, sessions := range userSessions {
+		s.lockDegraded(username, sessions)
+	}
+}
+
+// countDegraded keeps counting a user's time while they were logged in at
+// the last check that could list the sessions. Usage counted while the
+// database is unavailable is held until it can be saved; pauses cannot be
+// read meanwhile, so paused time counts as well.
+func (s *Scheduler) countDegraded(ctx context.Context, user *storage.User, sessions []dbus.Session, now time.Time, storeOK bool, elapsed time.Duration, accountedAt time.Time) {
+	loggedIn := len(sessions) > 0
+	seconds := int(elapsed.Seconds())
+
+	if !storeOK {
+		if loggedIn && seconds > 0 {
+			s.heldUsage[user.ID] += seconds
+		}
+		// Enforcing needs the stored usage, so it resumes with the database
+		s.updateUserLoop(ctx, user, sessions, false, accountedAt)
+		return
+	}
+
+	pause, err := s.store.GetActivePause(user.ID, now)
+	if err != nil {
+		log.Printf("Failed to get pause for %s: %v", user.Username, err)
+	}
+
+	counted := loggedIn && pause == nil
+	if counted && seconds > 0 {
+		if err := s.store.AddUsageTime(user.ID, seconds); err != nil {
+			log.Printf("Failed to add usage time for %s: %v", user.Username, err)
+		}
+	}
+	s.updateUserLoop(ctx, user, sessions, counted, accountedAt)
+}
+
+// saveHeldUsage saves the usage counted while the database was unavailable
+func (s *Scheduler) saveHeldUsage() {
+	for userID, seconds := range s.heldUsage {
+		if err := s.store.AddUsageTime(userID, seconds); err != nil {
+			log.Printf("Failed to save held usage time: %v", err)
+			continue
+		}
+		delete(s.heldUsage, userID)
+	}
+}
+
+// endDegraded resets the degraded state once users and sessions can be
+// read again. Locked sessions stay locked until the child unlocks them.
+func (s *Scheduler) endDegraded() {
+	if len(s.degradedLocked) > 0 {
+		log.Printf("Enforcement restored, no longer locking fail-closed users")
+		s.degradedLocked = make(map[string]bool)
+	}
+}
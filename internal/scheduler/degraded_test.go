@@ -0,0 +1,123 @@
+package scheduler
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/config"
+	"github.com/florian/screentime-guardian/internal/dbus"
+	"github.com/florian/screentime-guardian/internal/notifier"
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+func TestCheckDegraded(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	open, _ := store.CreateUser("alice", 60)
+	closed, _ := store.CreateUser("bob", 60)
+	count, _ := store.CreateUser("carol", 60)
+	store.SetUserDegradedMode(closed.ID, storage.DegradedClosed)
+	store.SetUserDegradedMode(count.ID, storage.DegradedCount)
+	users, _ := store.ListUsers()
+
+	sessions := []dbus.Session{
+		{ID: "1", UserID: 1001, UserName: "alice"},
+		{ID: "2", UserID: 1002, UserName: "bob"},
+		{ID: "3", UserID: 1003, UserName: "carol"},
+	}
+
+	logind := dbus.NewMockLogindClient()
+	s := New(store, logind, notifier.NewChain(notifier.NewMockNotifier()), config.Default())
+	s.usersListed = true // users is what an earlier check read
+	defer s.stopUserLoops(nil)
+	ctx := context.Background()
+	now := time.Now()
+
+	// logind is down: the fail-closed user is locked, the counting user's
+	// time keeps counting and the fail-open user is left alone
+	s.checkDegraded(ctx, now, users, sessions, true, 10*time.Second, now)
+
+	if len(logind.LockedSessions) != 1 || logind.LockedSessions[0] != "2" {
+		t.Errorf("Expected only bob's session to be locked, got %v", logind.LockedSessions)
+	}
+	if used, _ := store.GetTodayUsageSeconds(count.ID); used != 10 {
+		t.Errorf("Expected 10s counted for carol, got %d", used)
+	}
+	if used, _ := store.GetTodayUsageSeconds(open.ID); used != 0 {
+		t.Errorf("Expected nothing counted for alice, got %d", used)
+	}
+
+	// The database is down too: usage is held until it can be saved
+	s.checkDegraded(ctx, now, users, sessions, false, 10*time.Second, now)
+	if s.heldUsage[count.ID] != 10 {
+		t.Errorf("Expected 10s held for carol, got %d", s.heldUsage[count.ID])
+	}
+	if used, _ := store.GetTodayUsageSeconds(count.ID); used != 10 {
+		t.Errorf("Expected held usage not to be saved yet, got %d", used)
+	}
+
+	s.saveHeldUsage()
+	if used, _ := store.GetTodayUsageSeconds(count.ID); used != 20 {
+		t.Errorf("Expected held usage to be saved, got %d", used)
+	}
+	if len(s.heldUsage) != 0 {
+		t.Errorf("Expected no usage held after saving, got %v", s.heldUsage)
+	}
+
+	s.endDegraded()
+	if len(s.degradedLocked) != 0 {
+		t.Errorf("Expected the degraded state to be reset, got %v", s.degradedLocked)
+	}
+}
+
+func TestCheckDegradedFromStartup(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	store.CreateUser("alice", 60)
+
+	logind := dbus.NewMockLogindClient()
+	logind.Sessions = []dbus.Session{
+		{ID: "1", UserID: 1001, UserName: "alice"},
+		{ID: "2", UserID: 1002, UserName: "dad"},
+		{ID: "c1", UserID: 120, UserName: "gdm"},
+	}
+
+	broken := &failingStore{Store: store, failing: map[string]bool{"ListUsers": true}}
+	s := New(broken, logind, notifier.NewChain(notifier.NewMockNotifier()), config.Default())
+	defer s.stopUserLoops(nil)
+	ctx := context.Background()
+
+	// The users were never read, so nobody's mode is known and every
+	// regular account is locked; the greeter is left alone
+	s.check(ctx)
+
+	locked := append([]string(nil), logind.LockedSessions...)
+	sort.Strings(locked)
+	if len(locked) != 2 || locked[0] != "1" || locked[1] != "2" {
+		t.Errorf("Expected the sessions of alice and dad to be locked, got %v", locked)
+	}
+
+	// Once the users are read, alice's fail-open mode applies from then on
+	broken.failing["ListUsers"] = false
+	s.check(ctx)
+	broken.failing["ListUsers"] = true
+	logind.LockedSessions = nil
+	s.check(ctx)
+
+	if len(logind.LockedSessions) != 0 {
+		t.Errorf("Expected no sessions locked for a fail-open user, got %v", logind.LockedSessions)
+	}
+}
@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/dbus"
+)
+
+// degradedAlertAfter is how long logind must be unreachable before the
+// parents are alerted, so that a quick restart goes unnoticed
+const degradedAlertAfter = time.Minute
+
+// checkHealth alerts the parents once while logind cannot be reached, since
+// only each user's degraded mode applies meanwhile, and again once it
+// recovers
+func (s *Scheduler) checkHealth(ctx context.Context, now time.Time, health dbus.Health) {
+	s.mu.Lock()
+	alerted := s.degradedAlerted
+	s.mu.Unlock()
+
+	var message string
+	switch {
+	case !health.Healthy && !alerted && now.Sub(health.Since) >= degradedAlertAfter:
+		message = fmt.Sprintf("Screen time is not fully enforced: logind cannot be reached since %s (%s).",
+			health.Since.Format("15:04"), health.LastError)
+		alerted = true
+	case health.Healthy && alerted:
+		message = "logind can be reached again. Screen time is enforced again."
+		alerted = false
+	default:
+		return
+	}
+
+	s.mu.Lock()
+	s.degradedAlerted = alerted
+	s.mu.Unlock()
+
+	log.Print(message)
+	if err := s.notifier.SendParentAlert(ctx, "", message); err != nil {
+		log.Printf("Failed to alert parents: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/config"
+	"github.com/florian/screentime-guardian/internal/dbus"
+	"github.com/florian/screentime-guardian/internal/notifier"
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+func TestCheckHealth(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	mock := notifier.NewMockNotifier()
+	s := New(store, nil, notifier.NewChain(mock), config.Default())
+	ctx := context.Background()
+	now := time.Now()
+
+	down := dbus.Health{Since: now.Add(-10 * time.Second), LastError: "connection refused"}
+
+	// A short outage goes unnoticed
+	s.checkHealth(ctx, now, down)
+	if len(mock.AlertCalls) != 0 {
+		t.Fatalf("Expected no alert for a short outage, got %+v", mock.AlertCalls)
+	}
+
+	// A longer one alerts the parents once
+	s.checkHealth(ctx, now.Add(time.Minute), down)
+	s.checkHealth(ctx, now.Add(2*time.Minute), down)
+	if len(mock.AlertCalls) != 1 {
+		t.Fatalf("Expected one alert while degraded, got %+v", mock.AlertCalls)
+	}
+
+	// Recovery is reported too
+	s.checkHealth(ctx, now.Add(3*time.Minute), dbus.Health{Healthy: true, Since: now.Add(3 * time.Minute)})
+	s.checkHealth(ctx, now.Add(4*time.Minute), dbus.Health{Healthy: true, Since: now.Add(3 * time.Minute)})
+	if len(mock.AlertCalls) != 2 {
+		t.Errorf("Expected a recovery alert, got %+v", mock.AlertCalls)
+	}
+}
@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/dbus"
+)
+
+// lockUser locks the user's sessions and confirms each lock through the
+// session's LockedHint, retrying with backoff since some desktops ignore
+// logind's lock request. Retries also go through the desktop's own screen
+// locker. Sessions still unlocked after the last retry are logged out, and
+// the failure is recorded for the dashboard and sent to the parents.
+// Sessions that are already locked are left alone, so this is cheap to
+// repeat on every wake-up.
+func (s *Scheduler) lockUser(ctx context.Context, u *userLoop, username string) {
+	sessions, err := s.logind.GetUserSessions(username)
+	if err != nil {
+		log.Printf("Failed to list sessions of %s: %v", username, err)
+		return
+	}
+
+	pending := unlockedSessions(s.logind, sessions)
+	delay := s.config.LockVerifyDelay
+	for attempt := 1; attempt <= s.config.LockRetries && len(pending) > 0; attempt++ {
+		for _, session := range pending {
+			if err := s.logind.LockSession(session.ID); err != nil {
+				log.Printf("Failed to lock session %s of %s (attempt %d): %v", session.ID, username, attempt, err)
+			}
+			if attempt == 1 {
+				continue
+			}
+
+			// Nothing may be listening for logind's Lock signal
+			if service, err := s.logind.LockScreen(session); err != nil {
+				log.Printf("Screen locker fallback for session %s of %s failed: %v", session.ID, username, err)
+			} else {
+				log.Printf("Locked session %s of %s through %s", session.ID, username, service)
+			}
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-u.stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+		delay *= 2
+
+		pending = unlockedSessions(s.logind, pending)
+	}
+
+	if len(pending) == 0 {
+		return
+	}
+
+	log.Printf("Lock of %s not confirmed after %d attempt(s), logging out instead", username, s.config.LockRetries)
+	failed := false
+	for _, session := range pending {
+		if err := s.logind.TerminateSession(session.ID); err != nil {
+			log.Printf("Failed to terminate session %s of %s: %v", session.ID, username, err)
+			failed = true
+		}
+	}
+
+	if u.alerted {
+		return
+	}
+	u.alerted = true
+
+	message := fmt.Sprintf("The screen of %s could not be locked, so they were logged out instead.", username)
+	if failed {
+		message = fmt.Sprintf("The screen of %s could not be locked and logging out failed too. They may still be using the computer.", username)
+	}
+
+	u.mu.Lock()
+	userID := u.user.ID
+	u.mu.Unlock()
+	if _, err := s.store.RecordLockFailure(userID, message); err != nil {
+		log.Printf("Failed to record lock failure of %s: %v", username, err)
+	}
+	if err := s.notifier.SendParentAlert(ctx, username, message); err != nil {
+		log.Printf("Failed to alert parents about %s: %v", username, err)
+	}
+}
+
+// unlockedSessions returns the sessions that do not report being locked.
+// A session whose state cannot be read counts as unlocked.
+func unlockedSessions(logind dbus.SessionManager, sessions []dbus.Session) []dbus.Session {
+	var unlocked []dbus.Session
+	for _, session := range sessions {
+		locked, err := logind.SessionLocked(session)
+		if err != nil {
+			log.Printf("Failed to check lock of session %s: %v", session.ID, err)
+		}
+		if !locked {
+			unlocked = append(unlocked, session)
+		}
+	}
+	return unlocked
+}
@@ -0,0 +1,147 @@
+package scheduler
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/config"
+	"github.com/florian/screentime-guardian/internal/dbus"
+	"github.com/florian/screentime-guardian/internal/notifier"
+	"github.com/florian/screentime-guardian/internal/rules"
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+func TestLockUser(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.LockVerifyDelay = time.Millisecond
+
+	mock := notifier.NewMockNotifier()
+	logind := dbus.NewMockLogindClient()
+	s := New(store, logind, notifier.NewChain(mock), cfg)
+	ctx := context.Background()
+
+	user, _ := store.CreateUser("kid", 60)
+	loop := newUserLoop(user)
+
+	logind.Sessions = []dbus.Session{
+		{ID: "1", UserName: "kid"},
+		{ID: "2", UserName: "mum"},
+	}
+
+	// The desktop honours the lock: one attempt, nobody is logged out
+	s.lockUser(ctx, loop, "kid")
+	if len(logind.LockedSessions) != 1 || len(logind.TerminatedSessions) != 0 {
+		t.Errorf("Expected a single confirmed lock, got locked %v, terminated %v",
+			logind.LockedSessions, logind.TerminatedSessions)
+	}
+
+	// Re-applying the lock leaves a locked session alone
+	s.lockUser(ctx, loop, "kid")
+	if len(logind.LockedSessions) != 1 {
+		t.Errorf("Expected no new lock for a locked session, got %v", logind.LockedSessions)
+	}
+
+	// The desktop ignores logind's lock but has its own screen locker
+	logind.LockedHints = map[string]bool{}
+	logind.LockedSessions = nil
+	logind.IgnoreLock = true
+
+	s.lockUser(ctx, loop, "kid")
+	if len(logind.ScreenLocks) != 1 || len(logind.TerminatedSessions) != 0 {
+		t.Errorf("Expected the screen locker fallback, got screen locks %v, terminated %v",
+			logind.ScreenLocks, logind.TerminatedSessions)
+	}
+
+	// No lock works at all: retry, then log out and alert the parents
+	logind.LockedHints = map[string]bool{}
+	logind.LockedSessions = nil
+	logind.NoScreenSaver = true
+
+	s.lockUser(ctx, loop, "kid")
+	if len(logind.LockedSessions) != cfg.LockRetries {
+		t.Errorf("Expected %d lock attempts, got %d", cfg.LockRetries, len(logind.LockedSessions))
+	}
+	if len(logind.TerminatedSessions) != 1 || logind.TerminatedSessions[0] != "1" {
+		t.Errorf("Expected kid's session to be terminated, got %v", logind.TerminatedSessions)
+	}
+	if len(mock.AlertCalls) != 1 || mock.AlertCalls[0].Username != "kid" {
+		t.Errorf("Expected one parent alert, got %+v", mock.AlertCalls)
+	}
+	if failures, _ := store.ListLockFailures(); len(failures) != 1 || failures[0].Username != "kid" {
+		t.Errorf("Expected the failure to be recorded for the dashboard, got %+v", failures)
+	}
+
+	// The parents are alerted once per expiry
+	s.lockUser(ctx, loop, "kid")
+	if len(mock.AlertCalls) != 1 {
+		t.Errorf("Expected no repeated alert, got %d", len(mock.AlertCalls))
+	}
+	if failures, _ := store.ListLockFailures(); len(failures) != 1 {
+		t.Errorf("Expected no repeated lock failure, got %d", len(failures))
+	}
+}
+
+func TestRuleLock(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.LockVerifyDelay = time.Millisecond
+
+	logind := dbus.NewMockLogindClient()
+	s := New(store, logind, notifier.NewChain(notifier.NewMockNotifier()), cfg)
+	ctx := context.Background()
+
+	ruleset, err := rules.Parse(strings.NewReader(`
+rules:
+  - name: always-locked
+    when:
+      after: "00:00"
+    then:
+      action: lock
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	s.SetRules(rules.NewEngine(ruleset))
+
+	user, _ := store.CreateUser("kid", 60)
+	logind.Sessions = []dbus.Session{{ID: "1", UserName: "kid"}}
+	loop := newUserLoop(user)
+	loop.counted = true
+	loop.sessions = logind.Sessions
+
+	// The rule locks once and leaves the locked session alone afterwards
+	now := time.Now()
+	s.enforce(ctx, loop, now)
+	s.enforce(ctx, loop, now.Add(time.Minute))
+	if len(logind.LockedSessions) != 1 {
+		t.Errorf("Expected a single lock, got %v", logind.LockedSessions)
+	}
+
+	// A lock the desktop ignores is retried and escalated like any other
+	logind.LockedHints = map[string]bool{}
+	logind.LockedSessions = nil
+	logind.IgnoreLock = true
+	logind.NoScreenSaver = true
+
+	s.enforce(ctx, loop, now.Add(2*time.Minute))
+	if len(logind.LockedSessions) != cfg.LockRetries || len(logind.TerminatedSessions) != 1 {
+		t.Errorf("Expected %d lock attempts and a logout, got locked %v, terminated %v",
+			cfg.LockRetries, logind.LockedSessions, logind.TerminatedSessions)
+	}
+}
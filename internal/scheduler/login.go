@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/rules"
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+// CheckLogin decides whether a user may log in now. It backs the PAM hook,
+// which runs before the session exists, so the user is refused up front
+// instead of being locked on the next check. A refused login comes with a
+// reason to show at the login screen. Users the daemon does not manage are
+// always allowed. If the check fails once the user is known, a user who
+// fails closed is refused; otherwise the error is returned.
+func (s *Scheduler) CheckLogin(username, sessionType string, now time.Time) (bool, string, error) {
+	user, err := s.store.GetUserByUsername(username)
+	if err != nil {
+		return false, "", err
+	}
+	if user == nil || !user.Enabled {
+		return true, "", nil
+	}
+
+	allowed, reason, err := s.checkUserLogin(user, sessionType, now)
+	if err != nil && user.DegradedMode == storage.DegradedClosed {
+		// The user's record was readable, so their degraded mode applies
+		log.Printf("Refusing login of %s, who fails closed: %v", username, err)
+		return false, "Screen time cannot be checked right now. Ask a parent for help.", nil
+	}
+	return allowed, reason, err
+}
+
+func (s *Scheduler) checkUserLogin(user *storage.User, sessionType string, now time.Time) (bool, string, error) {
+	// A paused counter means a parent wants the child online
+	if pause, err := s.store.GetActivePause(user.ID, now); err != nil {
+		return false, "", err
+	} else if pause != nil {
+		return true, "", nil
+	}
+
+	policy, err := s.store.GetTodayPolicy(user.ID)
+	if err != nil {
+		return false, "", err
+	}
+
+	remaining, err := s.store.GetRemainingSeconds(user.ID)
+	if err != nil {
+		return false, "", err
+	}
+	if untilClose, ok := policy.SecondsUntilClose(now); ok && untilClose < remaining {
+		remaining = untilClose
+	}
+
+	if s.rules != nil {
+		usedSecs, err := s.store.GetTodayUsageSeconds(user.ID)
+		if err != nil {
+			return false, "", err
+		}
+
+		facts := rules.Facts{
+			Username:      user.Username,
+			Now:           now,
+			UsedMins:      usedSecs / 60,
+			RemainingMins: storage.MinutesRoundedUp(remaining),
+			HasOverride:   policy.Override != nil,
+		}
+		if policy.Override != nil {
+			facts.OverrideLabel = policy.Override.Label
+		}
+		if sessionType != "" {
+			facts.SessionTypes = []string{sessionType}
+		}
+
+		if ok, rule := s.rules.LoginAllowed(facts); !ok {
+			if rule.Then.Message != "" {
+				return false, rule.Then.Message, nil
+			}
+			return false, "Logging in is not allowed right now.", nil
+		}
+	}
+
+	if s.inCurfew(now) {
+		return false, fmt.Sprintf("It is bedtime. The computer can be used again from %s.", s.config.CurfewEnd), nil
+	}
+
+	// Warn mode never keeps a child off the computer
+	if policy.EnforcementMode == storage.EnforcementWarn {
+		return true, "", nil
+	}
+
+	if budget, err := s.store.GetHouseholdBudget(); err != nil {
+		return false, "", err
+	} else if budget != nil && budget.RemainingSeconds <= 0 {
+		return false, "The family's screen time for today is used up.", nil
+	}
+
+	if !policy.Allowed(now) {
+		windows := make([]string, len(policy.Windows))
+		for i, w := range policy.Windows {
+			windows[i] = w.String()
+		}
+		return false, fmt.Sprintf("Screen time is only allowed %s today.", strings.Join(windows, ", ")), nil
+	}
+
+	if remaining <= 0 {
+		return false, "You have used all of your screen time for today.", nil
+	}
+
+	return true, "", nil
+}
+
+// PAMSessionType guesses the logind session type of a login from the
+// PAM_SERVICE and PAM_TTY that pam_exec passes, or "" if it cannot tell
+func PAMSessionType(service, tty string) string {
+	if strings.HasPrefix(tty, ":") {
+		return "x11"
+	}
+	if service == "login" || service == "sshd" {
+		return "tty"
+	}
+	return ""
+}
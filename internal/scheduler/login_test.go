@@ -0,0 +1,171 @@
+package scheduler
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/config"
+	"github.com/florian/screentime-guardian/internal/notifier"
+	"github.com/florian/screentime-guardian/internal/rules"
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+func TestCheckLogin(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	// The PAM hook reads the database the daemon writes
+	readOnly, err := storage.OpenReadOnly(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open storage read-only: %v", err)
+	}
+	defer readOnly.Close()
+
+	cfg := config.Default()
+	cfg.Curfew = "21:00"
+	s := New(readOnly, nil, notifier.NewChain(), cfg)
+
+	ruleset, err := rules.Parse(strings.NewReader(`
+rules:
+  - name: no-console
+    users: [kid]
+    when:
+      session_types: [tty]
+    then:
+      action: deny_login
+      message: No console logins
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	s.SetRules(rules.NewEngine(ruleset))
+
+	today := time.Now()
+	at := func(hour, min int) time.Time {
+		return time.Date(today.Year(), today.Month(), today.Day(), hour, min, 0, 0, time.Local)
+	}
+
+	kid, _ := store.CreateUser("kid", 60)
+	windows, _ := storage.ParseWindows("15:00-20:00")
+	afternoons, _ := store.CreateProfile(&storage.Profile{
+		Name: "Afternoons", WeekdayLimitMins: 60, WeekendLimitMins: 60, Windows: windows,
+	})
+	store.SetUserProfile(kid.ID, afternoons.ID, "", "parent")
+
+	teen, _ := store.CreateUser("teen", 30)
+	trusted, _ := store.CreateProfile(&storage.Profile{
+		Name: "Trusted", WeekdayLimitMins: 30, WeekendLimitMins: 30, EnforcementMode: storage.EnforcementWarn,
+	})
+	store.SetUserProfile(teen.ID, trusted.ID, "", "parent")
+	store.AddUsageTime(teen.ID, 45*60)
+
+	tests := []struct {
+		name        string
+		username    string
+		sessionType string
+		now         time.Time
+		want        string // expected reason, "" if allowed
+	}{
+		{"unmanaged user", "mum", "x11", at(23, 0), ""},
+		{"inside window", "kid", "x11", at(16, 0), ""},
+		{"outside window", "kid", "x11", at(12, 0), "only allowed 15:00-20:00"},
+		{"console rule", "kid", "tty", at(16, 0), "No console logins"},
+		{"curfew", "kid", "x11", at(22, 0), "bedtime"},
+		{"warn mode out of time", "teen", "x11", at(16, 0), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, reason, err := s.CheckLogin(tt.username, tt.sessionType, tt.now)
+			if err != nil {
+				t.Fatalf("CheckLogin failed: %v", err)
+			}
+			if allowed != (tt.want == "") || !strings.Contains(reason, tt.want) {
+				t.Errorf("Expected reason %q, got allowed=%v %q", tt.want, allowed, reason)
+			}
+		})
+	}
+
+	// Out of time
+	store.AddUsageTime(kid.ID, 60*60)
+	if allowed, reason, _ := s.CheckLogin("kid", "x11", at(16, 0)); allowed || !strings.Contains(reason, "used all") {
+		t.Errorf("Expected login refused when out of time, got %v %q", allowed, reason)
+	}
+
+	// A pause lets the child back on
+	store.PauseUser(kid.ID, time.Hour, "video call", "parent")
+	if allowed, _, _ := s.CheckLogin("kid", "x11", time.Now().Add(time.Minute)); !allowed {
+		t.Error("Expected login allowed while paused")
+	}
+}
+
+func TestPAMSessionType(t *testing.T) {
+	tests := []struct {
+		service, tty, want string
+	}{
+		{"lightdm", ":0", "x11"},
+		{"login", "/dev/tty2", "tty"},
+		{"sshd", "ssh", "tty"},
+		{"gdm-password", "/dev/tty1", ""},
+	}
+
+	for _, tt := range tests {
+		if got := PAMSessionType(tt.service, tt.tty); got != tt.want {
+			t.Errorf("PAMSessionType(%q, %q) = %q, want %q", tt.service, tt.tty, got, tt.want)
+		}
+	}
+}
+
+// failingStore makes the named storage calls fail, like a broken database
+type failingStore struct {
+	storage.Store
+	failing map[string]bool
+}
+
+func (f *failingStore) GetActivePause(userID int64, t time.Time) (*storage.Pause, error) {
+	if f.failing["GetActivePause"] {
+		return nil, errors.New("database is locked")
+	}
+	return f.Store.GetActivePause(userID, t)
+}
+
+func (f *failingStore) ListUsers() ([]*storage.User, error) {
+	if f.failing["ListUsers"] {
+		return nil, errors.New("database is locked")
+	}
+	return f.Store.ListUsers()
+}
+
+func TestCheckLoginDegraded(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	open, _ := store.CreateUser("alice", 60)
+	closed, _ := store.CreateUser("bob", 60)
+	store.SetUserDegradedMode(closed.ID, storage.DegradedClosed)
+
+	broken := &failingStore{Store: store, failing: map[string]bool{"GetActivePause": true}}
+	s := New(broken, nil, notifier.NewChain(), config.Default())
+
+	// The fail-open user gets the error, which the PAM hook turns into an
+	// allowed login
+	if _, _, err := s.CheckLogin(open.Username, "x11", time.Now()); err == nil {
+		t.Error("Expected an error for the fail-open user")
+	}
+
+	allowed, reason, err := s.CheckLogin(closed.Username, "x11", time.Now())
+	if err != nil || allowed || !strings.Contains(reason, "cannot be checked") {
+		t.Errorf("Expected the fail-closed user to be refused, got %v %q %v", allowed, reason, err)
+	}
+}
@@ -0,0 +1,210 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/clock"
+	"github.com/florian/screentime-guardian/internal/config"
+	"github.com/florian/screentime-guardian/internal/dbus"
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+// Reasons for powering the computer down
+const (
+	powerReasonCurfew    = "curfew"
+	powerReasonOutOfTime = "out of time"
+)
+
+// checkPower puts the computer to sleep or powers it off during the
+// household curfew or once no child has time left. Logged-in users are
+// warned first, and nothing happens while an exempt user is logged in.
+func (s *Scheduler) checkPower(ctx context.Context, now time.Time, users []*storage.User, sessions []dbus.Session) {
+	loggedIn := make(map[string]bool)
+	var usernames []string
+	for _, session := range sessions {
+		if !loggedIn[session.UserName] {
+			usernames = append(usernames, session.UserName)
+		}
+		loggedIn[session.UserName] = true
+	}
+
+	reason := s.powerReason(now, users, loggedIn)
+	if reason == "" {
+		if !s.powerPending.IsZero() {
+			s.cancelPower(ctx, usernames)
+		}
+		return
+	}
+
+	warning := s.config.PowerWarning
+	poweroff := s.config.PowerAction == config.PowerPowerOff
+
+	if s.powerPending.IsZero() {
+		s.powerPending = now
+		at := now.Add(warning)
+		log.Printf("Powering down (%s) at %s: %s", s.config.PowerAction, at.Format("15:04:05"), reason)
+
+		title, body := powerNotice(reason, s.config.PowerAction, at)
+		for _, username := range usernames {
+			if err := s.notifier.SendMessage(ctx, username, title, body); err != nil {
+				log.Printf("Failed to send power notice to %s: %v", username, err)
+			}
+		}
+
+		// logind keeps its own countdown for a power off and warns terminal
+		// users as well
+		if poweroff && warning > 0 {
+			if err := s.logind.ScheduleShutdown("poweroff", at); err != nil {
+				log.Printf("Failed to schedule power off, will power off directly: %v", err)
+			} else {
+				s.shutdownScheduled = true
+			}
+		}
+	}
+
+	if now.Before(s.powerPending.Add(warning)) || s.shutdownScheduled {
+		return
+	}
+
+	var err error
+	if poweroff {
+		err = s.logind.PowerOff()
+	} else {
+		err = s.logind.Suspend()
+	}
+	if err != nil {
+		log.Printf("Failed to %s: %v", s.config.PowerAction, err)
+	}
+
+	// Warn again if the computer is woken up while the reason still holds
+	s.powerPending = time.Time{}
+}
+
+func (s *Scheduler) cancelPower(ctx context.Context, usernames []string) {
+	log.Printf("Power down cancelled")
+	s.powerPending = time.Time{}
+
+	if s.shutdownScheduled {
+		s.shutdownScheduled = false
+		if _, err := s.logind.CancelScheduledShutdown(); err != nil {
+			log.Printf("Failed to cancel scheduled shutdown: %v", err)
+		}
+	}
+
+	for _, username := range usernames {
+		if err := s.notifier.SendMessage(ctx, username, "Power Down Cancelled", "The computer will stay on."); err != nil {
+			log.Printf("Failed to send power notice to %s: %v", username, err)
+		}
+	}
+}
+
+// powerReason returns why the computer should be powered down now, or ""
+func (s *Scheduler) powerReason(now time.Time, users []*storage.User, loggedIn map[string]bool) string {
+	for _, username := range s.config.PowerExemptUsers {
+		if loggedIn[username] {
+			return ""
+		}
+	}
+
+	if s.inCurfew(now) {
+		return powerReasonCurfew
+	}
+
+	if s.config.PowerWhenOutOfTime && s.householdOutOfTime(now, users) {
+		return powerReasonOutOfTime
+	}
+
+	return ""
+}
+
+// inCurfew reports whether now falls between the curfew and its end, which
+// may be on the next morning
+func (s *Scheduler) inCurfew(now time.Time) bool {
+	if s.config.Curfew == "" {
+		return false
+	}
+
+	start, err := clock.Parse(s.config.Curfew)
+	if err != nil {
+		return false
+	}
+	end, err := clock.Parse(s.config.CurfewEnd)
+	if err != nil {
+		return false
+	}
+
+	mins := now.Hour()*60 + now.Minute()
+	if start < end {
+		return mins >= start && mins < end
+	}
+	if start > end {
+		return mins >= start || mins < end
+	}
+	return false
+}
+
+// householdOutOfTime reports whether there is at least one child and none
+// of them can use the computer right now, either on their own limits or
+// because the household budget is used up
+func (s *Scheduler) householdOutOfTime(now time.Time, users []*storage.User) bool {
+	budget, err := s.store.GetHouseholdBudget()
+	if err != nil {
+		return false
+	}
+	budgetUsed := budget != nil && budget.RemainingSeconds <= 0
+
+	children := 0
+	for _, user := range users {
+		if !user.Enabled {
+			continue
+		}
+		children++
+
+		if pause, err := s.store.GetActivePause(user.ID, now); err != nil || pause != nil {
+			return false
+		}
+		if budgetUsed {
+			continue
+		}
+
+		policy, err := s.store.GetTodayPolicy(user.ID)
+		if err != nil {
+			return false
+		}
+		if policy.Unlimited && len(policy.Windows) == 0 {
+			return false
+		}
+
+		remaining, err := s.store.GetRemainingSeconds(user.ID)
+		if err != nil {
+			return false
+		}
+		if untilClose, ok := policy.SecondsUntilClose(now); ok && untilClose < remaining {
+			remaining = untilClose
+		}
+		if remaining > 0 {
+			return false
+		}
+	}
+
+	return children > 0
+}
+
+func powerNotice(reason, action string, at time.Time) (string, string) {
+	title := "Computer Going to Sleep"
+	verb := "go to sleep"
+	if action == config.PowerPowerOff {
+		title = "Computer Shutting Down"
+		verb = "shut down"
+	}
+
+	why := "It's bedtime."
+	if reason == powerReasonOutOfTime {
+		why = "Everyone's screen time is used up."
+	}
+
+	return title, fmt.Sprintf("%s The computer will %s at %s. Please save your work.", why, verb, at.Format("15:04"))
+}
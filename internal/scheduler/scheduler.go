@@ -2,55 +2,99 @@ package scheduler
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"sync"
 	"time"
 
+	"github.com/florian/screentime-guardian/internal/clock"
 	"github.com/florian/screentime-guardian/internal/config"
 	"github.com/florian/screentime-guardian/internal/dbus"
 	"github.com/florian/screentime-guardian/internal/notifier"
+	"github.com/florian/screentime-guardian/internal/rules"
 	"github.com/florian/screentime-guardian/internal/storage"
 )
 
 // Scheduler manages time tracking and enforcement for all users
 type Scheduler struct {
-	store    *storage.Storage
-	logind   *dbus.LogindClient
+	store    storage.Store
+	logind   dbus.SessionManager
 	notifier *notifier.Chain
 	config   *config.Config
+	rules    *rules.Engine
+	clock    clock.Clock
+
+	// manual is set by a Simulator, which runs the user loops itself
+	manual bool
 
 	warningsSent   map[string]map[int]bool
+	budgetWarnings map[int]bool // household budget warnings sent today
 	mu             sync.Mutex
 	activeSessions map[string]time.Time
+	userLoops      map[string]*userLoop // enforcement per logged-in user
 	lastCheck      time.Time
 
+	// Pending curfew or out-of-time power down, see checkPower
+	powerPending      time.Time
+	shutdownScheduled bool
+
+	// degradedAlerted is set once the parents were told logind is down
+	degradedAlerted bool
+
+	// The users and sessions of the last check that could read them, and
+	// usage counted while the database was unavailable; see checkDegraded.
+	// usersListed is set once the users could be read at all.
+	knownUsers     []*storage.User
+	usersListed    bool
+	knownSessions  []dbus.Session
+	heldUsage      map[int64]int
+	degradedLocked map[string]bool
+
 	stop chan struct{}
 	done chan struct{}
 }
 
 // New creates a new scheduler
-func New(store *storage.Storage, logind *dbus.LogindClient, notifier *notifier.Chain, cfg *config.Config) *Scheduler {
+func New(store storage.Store, logind dbus.SessionManager, notifier *notifier.Chain, cfg *config.Config) *Scheduler {
 	return &Scheduler{
 		store:          store,
 		logind:         logind,
 		notifier:       notifier,
 		config:         cfg,
+		clock:          clock.System,
 		warningsSent:   make(map[string]map[int]bool),
+		budgetWarnings: make(map[int]bool),
 		activeSessions: make(map[string]time.Time),
+		userLoops:      make(map[string]*userLoop),
+		heldUsage:      make(map[int64]int),
+		degradedLocked: make(map[string]bool),
 		stop:           make(chan struct{}),
 		done:           make(chan struct{}),
 	}
 }
 
-// Run starts the scheduler loop
+// SetRules enables the rule engine. It must be called before Run.
+func (s *Scheduler) SetRules(engine *rules.Engine) {
+	s.rules = engine
+}
+
+// Run starts the accounting loop. Enforcement runs in a separate loop per
+// logged-in user, started and stopped by the accounting loop.
 func (s *Scheduler) Run(ctx context.Context) {
 	defer close(s.done)
+	defer s.stopUserLoops(nil)
 
 	ticker := time.NewTicker(s.config.CheckInterval)
 	defer ticker.Stop()
 
 	log.Printf("Scheduler started with check interval %v", s.config.CheckInterval)
 
+	if n, err := s.store.FailInterruptedActions(); err != nil {
+		log.Printf("Failed to check for interrupted actions: %v", err)
+	} else if n > 0 {
+		log.Printf("%d scheduled action(s) were interrupted by a restart and will not be retried", n)
+	}
+
 	s.check(ctx)
 
 	for {
@@ -73,41 +117,92 @@ func (s *Scheduler) Stop() {
 	<-s.done
 }
 
+// check does the accounting: it records usage, runs housekeeping and hands
+// every logged-in user to their enforcement loop. The loops lock at the
+// exact deadline themselves; this only reconciles them with the sessions
+// and the database.
 func (s *Scheduler) check(ctx context.Context) {
-	now := time.Now()
+	now := s.clock.Now()
 	elapsed := now.Sub(s.lastCheck)
-	s.lastCheck = now
+
+	// A gap much longer than the check interval means this is the first
+	// check or the computer was asleep; neither is screen time. Neither is
+	// the clock going backwards.
+	if elapsed < 0 || elapsed > 3*s.config.CheckInterval {
+		elapsed = 0
+		s.lastCheck = now
+	} else {
+		// Usage is stored in whole seconds; the fraction carries over to the
+		// next check so the enforcement deadlines don't drift
+		elapsed = elapsed.Truncate(time.Second)
+		s.lastCheck = s.lastCheck.Add(elapsed)
+	}
+	accountedAt := s.lastCheck
 
 	if now.Hour() == 0 && now.Minute() < 1 {
 		s.mu.Lock()
 		s.warningsSent = make(map[string]map[int]bool)
+		s.budgetWarnings = make(map[int]bool)
 		s.mu.Unlock()
 	}
 
-	users, err := s.store.ListUsers()
-	if err != nil {
-		log.Printf("Failed to list users: %v", err)
-		return
+	s.expireTimeRequests(ctx, now)
+	s.revertProfiles(now)
+	s.expirePauses(ctx, now)
+	s.runScheduledActions(ctx, now)
+
+	// Without the user list or the sessions, each user's degraded mode
+	// decides what happens, going by the last ones known
+	users, usersErr := s.store.ListUsers()
+	if usersErr != nil {
+		log.Printf("Failed to list users: %v", usersErr)
+		users = s.knownUsers
+	} else {
+		s.knownUsers = users
+		s.usersListed = true
+		s.saveHeldUsage()
 	}
 
-	sessions, err := s.logind.ListSessions()
-	if err != nil {
-		log.Printf("Failed to list sessions: %v", err)
+	sessions, sessionsErr := s.logind.ListSessions()
+	s.checkHealth(ctx, now, s.logind.Health())
+	if sessionsErr != nil {
+		log.Printf("Failed to list sessions: %v", sessionsErr)
+		sessions = s.knownSessions
+	} else {
+		s.knownSessions = sessions
+	}
+
+	if usersErr != nil || sessionsErr != nil {
+		s.checkDegraded(ctx, now, users, sessions, usersErr == nil, elapsed, accountedAt)
 		return
 	}
+	s.endDegraded()
 
 	loggedIn := make(map[string]bool)
+	userSessions := make(map[string][]dbus.Session)
 	for _, session := range sessions {
 		loggedIn[session.UserName] = true
+		userSessions[session.UserName] = append(userSessions[session.UserName], session)
 	}
 
+	// Users whose time is counting right now
+	var counting []string
+	enabled := make(map[string]bool)
+
 	for _, user := range users {
 		if !user.Enabled {
 			continue
 		}
+		enabled[user.Username] = true
 
 		isLoggedIn := loggedIn[user.Username]
 
+		// A paused counter neither accrues usage nor warns or locks
+		pause, err := s.store.GetActivePause(user.ID, now)
+		if err != nil {
+			log.Printf("Failed to get pause for %s: %v", user.Username, err)
+		}
+
 		if isLoggedIn && elapsed > 0 {
 			s.mu.Lock()
 			if _, ok := s.activeSessions[user.Username]; !ok {
@@ -116,9 +211,11 @@ func (s *Scheduler) check(ctx context.Context) {
 			}
 			s.mu.Unlock()
 
-			seconds := int(elapsed.Seconds())
-			if err := s.store.AddUsageTime(user.ID, seconds); err != nil {
-				log.Printf("Failed to add usage time for %s: %v", user.Username, err)
+			if pause == nil {
+				seconds := int(elapsed.Seconds())
+				if err := s.store.AddUsageTime(user.ID, seconds); err != nil {
+					log.Printf("Failed to add usage time for %s: %v", user.Username, err)
+				}
 			}
 		} else if !isLoggedIn {
 			s.mu.Lock()
@@ -129,63 +226,319 @@ func (s *Scheduler) check(ctx context.Context) {
 			s.mu.Unlock()
 		}
 
-		if !isLoggedIn {
-			continue
+		counted := isLoggedIn && pause == nil
+		if counted {
+			counting = append(counting, user.Username)
 		}
+		s.updateUserLoop(ctx, user, userSessions[user.Username], counted, accountedAt)
+	}
 
-		remaining, err := s.store.GetRemainingMinutes(user.ID)
+	s.stopUserLoops(enabled)
+	s.checkBudgetWarnings(ctx, counting)
+	s.checkPower(ctx, now, users, sessions)
+}
+
+// checkBudgetWarnings warns everyone using the computer as the household
+// budget runs low, once per interval and day
+func (s *Scheduler) checkBudgetWarnings(ctx context.Context, usernames []string) {
+	if len(usernames) == 0 {
+		return
+	}
+
+	budget, err := s.store.GetHouseholdBudget()
+	if err != nil || budget == nil {
+		return
+	}
+
+	// Crossing several intervals at once still sends a single warning. It
+	// is sent after releasing s.mu, like the per-user warnings.
+	s.mu.Lock()
+	due := false
+	for _, interval := range s.config.WarningIntervals {
+		if budget.RemainingMins <= interval && !s.budgetWarnings[interval] {
+			s.budgetWarnings[interval] = true
+			due = true
+		}
+	}
+	s.mu.Unlock()
+	if !due {
+		return
+	}
+
+	log.Printf("Household budget: %d minute(s) left", budget.RemainingMins)
+	body := fmt.Sprintf("The family computer has %d minute(s) of screen time left today, shared by everyone.", budget.RemainingMins)
+	for _, username := range usernames {
+		if err := s.notifier.SendMessage(ctx, username, "Family Time Running Out", body); err != nil {
+			log.Printf("Failed to send budget warning to %s: %v", username, err)
+		}
+	}
+}
+
+// applyRules evaluates the rule engine for a logged-in user and reports
+// whether a rule locked or ended their sessions. Locks go through lockUser,
+// like locks for time running out.
+func (s *Scheduler) applyRules(ctx context.Context, u *userLoop, user *storage.User, sessions []dbus.Session, policy *storage.Policy, now time.Time) bool {
+	facts := s.ruleFacts(user, sessions, policy, now)
+	enforced := false
+
+	for _, match := range s.rules.Evaluate(facts) {
+		rule := match.Rule
+		message := rule.Then.Message
+
+		if match.First {
+			log.Printf("Rule %q matched for %s: %s", rule.Name, user.Username, rule.Then.Action)
+			if message != "" {
+				if err := s.notifier.SendMessage(ctx, user.Username, "Screen Time", message); err != nil {
+					log.Printf("Failed to send rule message to %s: %v", user.Username, err)
+				}
+			}
+		}
+
+		switch rule.Then.Action {
+		case rules.ActionGrant:
+			s.grantRuleTime(ctx, user, rule)
+
+		case rules.ActionLock:
+			s.lockUser(ctx, u, user.Username)
+			enforced = true
+
+		case rules.ActionTerminate, rules.ActionDenyLogin:
+			if err := s.logind.TerminateUserSessions(user.Username); err != nil {
+				log.Printf("Failed to terminate sessions for %s: %v", user.Username, err)
+			}
+			enforced = true
+		}
+	}
+
+	return enforced
+}
+
+// ruleFacts gathers what the rule engine needs to know about a user
+func (s *Scheduler) ruleFacts(user *storage.User, sessions []dbus.Session, policy *storage.Policy, now time.Time) rules.Facts {
+	usedSecs, _ := s.store.GetTodayUsageSeconds(user.ID)
+	remaining, _ := s.store.GetRemainingMinutes(user.ID)
+	if untilClose, ok := policy.MinutesUntilClose(now); ok && untilClose < remaining {
+		remaining = untilClose
+	}
+
+	facts := rules.Facts{
+		Username:      user.Username,
+		Now:           now,
+		UsedMins:      usedSecs / 60,
+		RemainingMins: remaining,
+		HasOverride:   policy.Override != nil,
+	}
+	if policy.Override != nil {
+		facts.OverrideLabel = policy.Override.Label
+	}
+
+	for _, session := range sessions {
+		if sessionType, err := s.logind.SessionType(session); err == nil {
+			facts.SessionTypes = append(facts.SessionTypes, sessionType)
+		}
+	}
+
+	if len(sessions) > 0 {
+		apps, err := rules.RunningApps(sessions[0].UserID)
 		if err != nil {
-			log.Printf("Failed to get remaining time for %s: %v", user.Username, err)
-			continue
+			log.Printf("Failed to list running apps for %s: %v", user.Username, err)
+		}
+		facts.Apps = apps
+	}
+
+	return facts
+}
+
+// grantRuleTime grants a rule's extra minutes unless the rule already did so
+// today, e.g. before a restart
+func (s *Scheduler) grantRuleTime(ctx context.Context, user *storage.User, rule *rules.Rule) {
+	grantedBy := "rule:" + rule.Name
+
+	extensions, err := s.store.ListTodayExtensions(user.ID)
+	if err != nil {
+		log.Printf("Failed to list extensions for %s: %v", user.Username, err)
+		return
+	}
+	for _, ext := range extensions {
+		if ext.GrantedBy == grantedBy {
+			return
 		}
+	}
+
+	if _, err := s.store.GrantTimeExtension(user.ID, rule.Then.Minutes, grantedBy, rule.Name, nil); err != nil {
+		log.Printf("Failed to grant rule time to %s: %v", user.Username, err)
+		return
+	}
+
+	s.ResetWarnings(user.Username)
+	if err := s.notifier.SendTimeExtended(ctx, user.Username, rule.Then.Minutes); err != nil {
+		log.Printf("Failed to send extension notice to %s: %v", user.Username, err)
+	}
+}
 
-		if remaining <= 0 {
-			s.handleTimeExpired(ctx, user.Username)
+// runScheduledActions executes queued actions whose time has come. Each is
+// claimed before it runs so it is executed at most once, even across
+// restarts. Actions from an earlier day that were missed while the daemon
+// was down are not run late.
+func (s *Scheduler) runScheduledActions(ctx context.Context, now time.Time) {
+	due, err := s.store.DueScheduledActions(now)
+	if err != nil {
+		log.Printf("Failed to list scheduled actions: %v", err)
+		return
+	}
+
+	today := now.Format("2006-01-02")
+	for _, a := range due {
+		claimed, err := s.store.ClaimScheduledAction(a.ID)
+		if err != nil {
+			log.Printf("Failed to claim scheduled action %d: %v", a.ID, err)
+			continue
+		}
+		if !claimed {
 			continue
 		}
 
-		s.checkWarnings(ctx, user.Username, remaining)
+		var runErr error
+		if a.RunAt.Local().Format("2006-01-02") != today {
+			runErr = fmt.Errorf("missed on %s while the daemon was not running", a.RunAt.Local().Format("2006-01-02 15:04"))
+		} else {
+			log.Printf("Running scheduled %s for %s", a.Action, a.Username)
+			runErr = s.runScheduledAction(ctx, a)
+		}
+
+		if runErr != nil {
+			log.Printf("Scheduled %s for %s failed: %v", a.Action, a.Username, runErr)
+		}
+		if err := s.store.FinishScheduledAction(a.ID, runErr); err != nil {
+			log.Printf("Failed to record scheduled action %d: %v", a.ID, err)
+		}
 	}
 }
 
-func (s *Scheduler) handleTimeExpired(ctx context.Context, username string) {
-	log.Printf("Time expired for user %s, locking session", username)
+func (s *Scheduler) runScheduledAction(ctx context.Context, a *storage.ScheduledAction) error {
+	if a.Message != "" && a.Action != storage.ScheduledMessage {
+		if err := s.notifier.SendMessage(ctx, a.Username, "Screen Time", a.Message); err != nil {
+			log.Printf("Failed to send scheduled message to %s: %v", a.Username, err)
+		}
+	}
+
+	switch a.Action {
+	case storage.ScheduledLock:
+		return s.logind.LockUserSessions(a.Username)
 
-	if err := s.notifier.SendLockNotice(ctx, username); err != nil {
-		log.Printf("Failed to send lock notice to %s: %v", username, err)
+	case storage.ScheduledTerminate:
+		return s.logind.TerminateUserSessions(a.Username)
+
+	case storage.ScheduledExtend:
+		if _, err := s.store.GrantTimeExtension(a.UserID, a.Minutes, a.CreatedBy, "scheduled", nil); err != nil {
+			return err
+		}
+		s.ResetWarnings(a.Username)
+		if err := s.notifier.SendTimeExtended(ctx, a.Username, a.Minutes); err != nil {
+			log.Printf("Failed to send extension notice to %s: %v", a.Username, err)
+		}
+		return nil
+
+	case storage.ScheduledPause:
+		_, err := s.store.PauseUser(a.UserID, time.Duration(a.Minutes)*time.Minute, "scheduled", a.CreatedBy)
+		return err
+
+	case storage.ScheduledMessage:
+		return s.notifier.SendMessage(ctx, a.Username, "Screen Time", a.Message)
 	}
 
-	time.Sleep(3 * time.Second)
+	return fmt.Errorf("unknown action %q", a.Action)
+}
+
+func (s *Scheduler) expirePauses(ctx context.Context, now time.Time) {
+	expired, err := s.store.ExpirePauses(now)
+	if err != nil {
+		log.Printf("Failed to expire pauses: %v", err)
+	}
 
-	if err := s.logind.LockUserSessions(username); err != nil {
-		log.Printf("Failed to lock sessions for %s: %v", username, err)
+	for _, p := range expired {
+		log.Printf("Pause for %s ended, usage counter resumed", p.Username)
+		if err := s.notifier.SendMessage(ctx, p.Username, "Screen Time Resumed", "Your pause has ended. Screen time is counting again."); err != nil {
+			log.Printf("Failed to send resume notice to %s: %v", p.Username, err)
+		}
 	}
 }
 
-func (s *Scheduler) checkWarnings(ctx context.Context, username string, remaining int) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *Scheduler) revertProfiles(now time.Time) {
+	reverted, err := s.store.RevertExpiredProfiles(now.Format("2006-01-02"))
+	if err != nil {
+		log.Printf("Failed to revert profiles: %v", err)
+	}
+
+	for _, a := range reverted {
+		to := a.RevertProfileName
+		if to == "" {
+			to = "default limit"
+		}
+		log.Printf("User %d profile %q ended, reverted to %s", a.UserID, a.ProfileName, to)
+	}
+}
+
+func (s *Scheduler) expireTimeRequests(ctx context.Context, now time.Time) {
+	expired, err := s.store.ExpireTimeRequests(now)
+	if err != nil {
+		log.Printf("Failed to expire time requests: %v", err)
+	}
+
+	for _, req := range expired {
+		log.Printf("Time request %d from %s expired unanswered", req.ID, req.Username)
+		if err := s.notifier.SendRequestDecision(ctx, req.Username, req.Status, 0); err != nil {
+			log.Printf("Failed to send request expiry to %s: %v", req.Username, err)
+		}
+	}
+}
+
+// checkWarnings sends the warnings due at the remaining minutes and returns
+// the next warning interval still to come, or 0 if none is left. The
+// warnings are marked as sent under s.mu but sent after releasing it, so a
+// slow notification holds up neither accounting nor other users' loops.
+func (s *Scheduler) checkWarnings(ctx context.Context, username string, remaining int, intervals []int) int {
+	if len(intervals) == 0 {
+		intervals = s.config.WarningIntervals
+	}
 
+	s.mu.Lock()
 	if s.warningsSent[username] == nil {
 		s.warningsSent[username] = make(map[int]bool)
 	}
 
-	for _, interval := range s.config.WarningIntervals {
+	var due []int
+	next := 0
+	for _, interval := range intervals {
 		if remaining <= interval && !s.warningsSent[username][interval] {
-			log.Printf("Sending %d minute warning to %s", interval, username)
-			if err := s.notifier.SendWarning(ctx, username, remaining); err != nil {
-				log.Printf("Failed to send warning to %s: %v", username, err)
-			}
 			s.warningsSent[username][interval] = true
+			due = append(due, interval)
+		}
+		if interval < remaining && interval > next && !s.warningsSent[username][interval] {
+			next = interval
 		}
 	}
+	s.mu.Unlock()
+
+	for _, interval := range due {
+		log.Printf("Sending %d minute warning to %s", interval, username)
+		if err := s.notifier.SendWarning(ctx, username, remaining); err != nil {
+			log.Printf("Failed to send warning to %s: %v", username, err)
+		}
+	}
+
+	return next
 }
 
-// ResetWarnings clears warning state for a user
+// ResetWarnings clears warning state for a user, e.g. after more time was
+// granted, and has their enforcement loop re-evaluate right away
 func (s *Scheduler) ResetWarnings(username string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	delete(s.warningsSent, username)
+	if loop, ok := s.userLoops[username]; ok {
+		loop.poke()
+	}
 }
 
 // ForceCheck triggers an immediate check cycle
@@ -197,10 +550,16 @@ func (s *Scheduler) ForceCheck(ctx context.Context) {
 type UserStatus struct {
 	Username       string
 	IsLoggedIn     bool
+	RemainingSecs  int
 	RemainingMins  int
 	UsedMins       int
 	DailyLimitMins int
 	ExtensionMins  int
+	DeductionMins  int
+	Unlimited      bool
+	OverrideLabel  string
+	ProfileName    string
+	Paused         bool
 	Enabled        bool
 }
 
@@ -223,19 +582,39 @@ func (s *Scheduler) GetAllStatus(ctx context.Context) ([]UserStatus, error) {
 
 	var statuses []UserStatus
 	for _, user := range users {
-		remaining, _ := s.store.GetRemainingMinutes(user.ID)
+		remaining, _ := s.store.GetRemainingSeconds(user.ID)
 		usedSecs, _ := s.store.GetTodayUsageSeconds(user.ID)
 		extensions, _ := s.store.GetTodayExtensions(user.ID)
+		deductions, _ := s.store.GetTodayDeductions(user.ID)
 
-		statuses = append(statuses, UserStatus{
+		status := UserStatus{
 			Username:       user.Username,
 			IsLoggedIn:     loggedIn[user.Username],
-			RemainingMins:  remaining,
+			RemainingSecs:  remaining,
+			RemainingMins:  storage.MinutesRoundedUp(remaining),
 			UsedMins:       usedSecs / 60,
 			DailyLimitMins: user.DailyLimitMins,
 			ExtensionMins:  extensions,
+			DeductionMins:  deductions,
 			Enabled:        user.Enabled,
-		})
+		}
+
+		if pause, err := s.store.GetActivePause(user.ID, s.clock.Now()); err == nil && pause != nil {
+			status.Paused = true
+		}
+
+		if policy, err := s.store.GetTodayPolicy(user.ID); err == nil {
+			status.DailyLimitMins = policy.LimitMins
+			status.Unlimited = policy.Unlimited
+			if policy.Override != nil {
+				status.OverrideLabel = policy.Override.Label
+			}
+			if policy.Profile != nil {
+				status.ProfileName = policy.Profile.Name
+			}
+		}
+
+		statuses = append(statuses, status)
 	}
 
 	return statuses, nil
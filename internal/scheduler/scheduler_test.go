@@ -1,9 +1,14 @@
 package scheduler
 
 import (
+	"context"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/florian/screentime-guardian/internal/config"
+	"github.com/florian/screentime-guardian/internal/dbus"
+	"github.com/florian/screentime-guardian/internal/notifier"
 	"github.com/florian/screentime-guardian/internal/storage"
 )
 
@@ -74,3 +79,280 @@ func TestWarningsTracking(t *testing.T) {
 		t.Errorf("Expected 0 minutes remaining (time exceeded), got %d", remaining)
 	}
 }
+
+func TestRunScheduledActions(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	mock := notifier.NewMockNotifier()
+	s := New(store, nil, notifier.NewChain(mock), config.Default())
+
+	user, _ := store.CreateUser("testuser", 60)
+	now := time.Now()
+
+	extend, _ := store.ScheduleAction(user.ID, storage.ScheduledExtend, now.Add(-time.Minute), 30, "", "parent")
+	message, _ := store.ScheduleAction(user.ID, storage.ScheduledMessage, now.Add(-time.Minute), 0, "Dinner is ready", "parent")
+	missed, _ := store.ScheduleAction(user.ID, storage.ScheduledMessage, now.AddDate(0, 0, -1), 0, "Yesterday", "parent")
+
+	s.runScheduledActions(context.Background(), now)
+	// Running again, as after a restart, must not repeat anything
+	s.runScheduledActions(context.Background(), now)
+
+	if remaining, _ := store.GetRemainingMinutes(user.ID); remaining != 90 {
+		t.Errorf("Expected 90 minutes after a single scheduled grant, got %d", remaining)
+	}
+	if len(mock.MessageCalls) != 1 || mock.MessageCalls[0].Body != "Dinner is ready" {
+		t.Errorf("Expected exactly one message, got %+v", mock.MessageCalls)
+	}
+
+	for _, id := range []int64{extend.ID, message.ID} {
+		if a, _ := store.GetScheduledAction(id); a.Status != storage.ScheduledDone {
+			t.Errorf("Expected action %d to be done, got %s", id, a.Status)
+		}
+	}
+	if a, _ := store.GetScheduledAction(missed.ID); a.Status != storage.ScheduledFailed || a.Error == "" {
+		t.Errorf("Expected yesterday's action to be marked missed, got %+v", a)
+	}
+}
+
+func TestPowerReason(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.Curfew = "21:30"
+	cfg.CurfewEnd = "06:00"
+	cfg.PowerExemptUsers = []string{"mum"}
+	s := New(store, nil, notifier.NewChain(), cfg)
+
+	at := func(hour, min int) time.Time {
+		return time.Date(2024, 12, 20, hour, min, 0, 0, time.Local)
+	}
+
+	user, _ := store.CreateUser("kid", 60)
+	users, _ := store.ListUsers()
+	nobody := map[string]bool{}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		loggedIn map[string]bool
+		want     string
+	}{
+		{"afternoon", at(15, 0), nobody, ""},
+		{"curfew starts", at(21, 30), nobody, powerReasonCurfew},
+		{"after midnight", at(2, 0), map[string]bool{"kid": true}, powerReasonCurfew},
+		{"curfew ends", at(6, 0), nobody, ""},
+		{"parent logged in", at(23, 0), map[string]bool{"kid": true, "mum": true}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.powerReason(tt.now, users, tt.loggedIn); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	// Out of time only counts once it is enabled and every child is done
+	now := time.Now()
+	store.AddUsageTime(user.ID, 3600)
+	cfg.Curfew = ""
+	if got := s.powerReason(now, users, nobody); got != "" {
+		t.Errorf("Expected no power down with power_when_out_of_time off, got %q", got)
+	}
+
+	cfg.PowerWhenOutOfTime = true
+	if got := s.powerReason(now, users, nobody); got != powerReasonOutOfTime {
+		t.Errorf("Expected %q, got %q", powerReasonOutOfTime, got)
+	}
+
+	store.PauseUser(user.ID, time.Hour, "", "parent")
+	if got := s.powerReason(now, users, nobody); got != "" {
+		t.Errorf("Expected no power down while a child is paused, got %q", got)
+	}
+}
+
+func TestBudgetWarnings(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	mock := notifier.NewMockNotifier()
+	s := New(store, nil, notifier.NewChain(mock), config.Default())
+
+	alice, _ := store.CreateUser("alice", 180)
+	store.SetHouseholdBudgetMins(60)
+	store.AddUsageTime(alice.ID, 50*60)
+
+	s.checkBudgetWarnings(context.Background(), []string{"alice", "bob"})
+	if len(mock.MessageCalls) != 0 {
+		t.Fatalf("Expected no warning with 10 minutes left, got %+v", mock.MessageCalls)
+	}
+
+	// Crossing both the 5 and 1 minute intervals sends one warning each
+	store.AddUsageTime(alice.ID, 9*60)
+	s.checkBudgetWarnings(context.Background(), []string{"alice", "bob"})
+	s.checkBudgetWarnings(context.Background(), []string{"alice", "bob"})
+	if len(mock.MessageCalls) != 2 {
+		t.Errorf("Expected one warning to each user, got %+v", mock.MessageCalls)
+	}
+
+	store.AddUsageTime(alice.ID, 60)
+	users, _ := store.ListUsers()
+	if !s.householdOutOfTime(time.Now(), users) {
+		t.Error("Expected a used-up budget to count as out of time")
+	}
+}
+
+func TestHungBudgetWarningBlocksNobodyElse(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	blocking := newBlockingNotifier("alice")
+	s := New(store, nil, notifier.NewChain(blocking), config.Default())
+	ctx := context.Background()
+
+	alice, _ := store.CreateUser("alice", 180)
+	store.SetHouseholdBudgetMins(60)
+	store.AddUsageTime(alice.ID, 57*60)
+
+	go s.checkBudgetWarnings(ctx, []string{"alice", "bob"})
+	select {
+	case <-blocking.blocked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the budget warning to be sent to alice")
+	}
+
+	// The hung message to alice does not hold the scheduler's lock
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.ResetWarnings("alice")
+		s.countingUsers()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the scheduler to carry on while the budget warning hangs")
+	}
+
+	close(blocking.release)
+	waitFor(t, "the budget warning to bob", func() bool {
+		blocking.Mu.Lock()
+		defer blocking.Mu.Unlock()
+		return len(blocking.MessageCalls) == 2
+	})
+}
+
+// waitFor polls cond until it holds, for state changed by the user loops
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCheckSessionLifecycle(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	cfg := config.Default()
+	cfg.GracePeriod = 0
+	cfg.LockVerifyDelay = time.Millisecond
+
+	logind := dbus.NewMockLogindClient()
+	mock := notifier.NewMockNotifier()
+	s := New(store, logind, notifier.NewChain(mock), cfg)
+	defer s.stopUserLoops(nil)
+	ctx := context.Background()
+
+	user, _ := store.CreateUser("kid", 10)
+	store.AddUsageTime(user.ID, 6*60)
+
+	// Nobody is logged in: nothing is counted and nobody is warned
+	s.check(ctx)
+	if used, _ := store.GetTodayUsageSeconds(user.ID); used != 6*60 {
+		t.Errorf("Expected no usage while logged out, got %ds", used)
+	}
+
+	// Logging in counts the time since the last check and warns about the
+	// four minutes left
+	logind.Mu.Lock()
+	logind.Sessions = []dbus.Session{{ID: "1", UserID: 1000, UserName: "kid"}}
+	logind.Mu.Unlock()
+
+	s.lastCheck = time.Now().Add(-10 * time.Second)
+	s.check(ctx)
+	if used, _ := store.GetTodayUsageSeconds(user.ID); used != 6*60+10 {
+		t.Errorf("Expected 10s counted after logging in, got %ds", used-6*60)
+	}
+
+	waitFor(t, "the warning", func() bool {
+		mock.Mu.Lock()
+		defer mock.Mu.Unlock()
+		return len(mock.WarningCalls) > 0
+	})
+	mock.Mu.Lock()
+	if call := mock.WarningCalls[0]; call.Username != "kid" || call.MinutesLeft > 5 {
+		t.Errorf("Expected kid to be warned about at most 5 minutes, got %+v", call)
+	}
+	mock.Mu.Unlock()
+
+	// Running out of time locks the session after the lock notice
+	store.AddUsageTime(user.ID, 4*60)
+	s.lastCheck = time.Now().Add(-10 * time.Second)
+	s.check(ctx)
+
+	waitFor(t, "the lock", func() bool {
+		logind.Mu.Lock()
+		defer logind.Mu.Unlock()
+		return logind.LockedHints["1"]
+	})
+	mock.Mu.Lock()
+	if len(mock.LockCalls) != 1 || mock.LockCalls[0] != "kid" {
+		t.Errorf("Expected one lock notice for kid, got %v", mock.LockCalls)
+	}
+	mock.Mu.Unlock()
+	logind.Mu.Lock()
+	if len(logind.TerminatedSessions) != 0 {
+		t.Errorf("Expected a confirmed lock without logging out, got %v", logind.TerminatedSessions)
+	}
+	logind.Mu.Unlock()
+
+	// Logging out ends the session
+	logind.Mu.Lock()
+	logind.Sessions = nil
+	logind.Mu.Unlock()
+
+	s.check(ctx)
+	s.mu.Lock()
+	_, active := s.activeSessions["kid"]
+	s.mu.Unlock()
+	if active {
+		t.Error("Expected kid's session to have ended")
+	}
+}
@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/clock"
+)
+
+// Simulator runs a scheduler on a fake clock in the calling goroutine. It
+// runs the accounting check every check interval and each user's
+// enforcement whenever it falls due, as the daemon would, without waiting
+// for the time to pass.
+type Simulator struct {
+	s         *Scheduler
+	clock     *clock.Fake
+	nextCheck time.Time
+	due       map[*userLoop]time.Time // next enforcement of each user loop
+}
+
+// NewSimulator takes over s, which must not be running, and drives it from
+// the fake clock c. The first check runs at the clock's current time.
+func NewSimulator(s *Scheduler, c *clock.Fake) *Simulator {
+	s.clock = c
+	s.manual = true
+	return &Simulator{
+		s:         s,
+		clock:     c,
+		nextCheck: c.Now(),
+		due:       make(map[*userLoop]time.Time),
+	}
+}
+
+// RunUntil advances the clock to until, running every check and
+// enforcement that falls due on the way, including those due at until
+func (sim *Simulator) RunUntil(ctx context.Context, until time.Time) {
+	for {
+		at := sim.nextCheck
+		for _, due := range sim.due {
+			if !due.IsZero() && due.Before(at) {
+				at = due
+			}
+		}
+
+		if at.After(until) {
+			sim.clock.Set(until)
+			return
+		}
+		sim.clock.Set(at)
+
+		// A check wakes every loop, like the daemon's accounting tick
+		if !at.Before(sim.nextCheck) {
+			sim.s.check(ctx)
+			sim.nextCheck = at.Add(sim.s.config.CheckInterval)
+			sim.enforce(ctx, at, true)
+		} else {
+			sim.enforce(ctx, at, false)
+		}
+	}
+}
+
+// enforce runs the loops due at now, or all of them, in username order
+func (sim *Simulator) enforce(ctx context.Context, now time.Time, all bool) {
+	sim.s.mu.Lock()
+	usernames := make([]string, 0, len(sim.s.userLoops))
+	for username := range sim.s.userLoops {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+	loops := make([]*userLoop, 0, len(usernames))
+	for _, username := range usernames {
+		loops = append(loops, sim.s.userLoops[username])
+	}
+	sim.s.mu.Unlock()
+
+	running := make(map[*userLoop]time.Time)
+	for _, loop := range loops {
+		due, ok := sim.due[loop]
+		if all || (ok && !due.IsZero() && !due.After(now)) {
+			due = sim.s.enforce(ctx, loop, now)
+			// The daemon's timer fires right away for a past deadline;
+			// wait a second instead of running again at the same instant
+			if !due.IsZero() && !due.After(now) {
+				due = now.Add(time.Second)
+			}
+		}
+		running[loop] = due
+	}
+
+	// Loops stopped by the check are forgotten
+	sim.due = running
+}
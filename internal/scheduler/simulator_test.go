@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/clock"
+	"github.com/florian/screentime-guardian/internal/config"
+	"github.com/florian/screentime-guardian/internal/dbus"
+	"github.com/florian/screentime-guardian/internal/notifier"
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+func TestSimulator(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	// Friday evening, three minutes left
+	friday := func(hour, min, sec int) time.Time {
+		return time.Date(2026, 10, 16, hour, min, sec, 0, time.Local)
+	}
+	c := clock.NewFake(friday(19, 50, 0))
+	store.SetClock(c)
+
+	user, _ := store.CreateUser("kid", 60)
+	store.AddUsageTime(user.ID, 57*60)
+
+	cfg := config.Default()
+	cfg.LockVerifyDelay = time.Millisecond
+
+	logind := dbus.NewMockLogindClient()
+	mock := notifier.NewMockNotifier()
+	s := New(store, logind, notifier.NewChain(mock), cfg)
+	sim := NewSimulator(s, c)
+	ctx := context.Background()
+
+	sim.RunUntil(ctx, friday(19, 55, 0))
+	logind.Sessions = []dbus.Session{{ID: "1", UserName: "kid"}}
+
+	// The login is noticed at the next check, which counts the 30 seconds
+	// since the previous one, leaving 2:30 and a warning
+	sim.RunUntil(ctx, friday(19, 55, 30))
+	if len(mock.WarningCalls) == 0 || mock.WarningCalls[0].MinutesLeft != 3 {
+		t.Errorf("Expected a warning about 3 minutes, got %+v", mock.WarningCalls)
+	}
+
+	// The lock notice comes at the exact second the time runs out
+	sim.RunUntil(ctx, friday(19, 57, 59))
+	if len(mock.LockCalls) != 0 {
+		t.Errorf("Expected no lock notice before 19:58, got %v", mock.LockCalls)
+	}
+	sim.RunUntil(ctx, friday(19, 58, 0))
+	if len(mock.LockCalls) != 1 {
+		t.Errorf("Expected the lock notice at 19:58, got %v", mock.LockCalls)
+	}
+
+	// The lock follows after the grace period
+	sim.RunUntil(ctx, friday(19, 58, 59))
+	if len(logind.LockedSessions) != 0 {
+		t.Errorf("Expected no lock during the grace period, got %v", logind.LockedSessions)
+	}
+	sim.RunUntil(ctx, friday(19, 59, 0))
+	if len(logind.LockedSessions) != 1 {
+		t.Errorf("Expected the session to be locked at 19:59, got %v", logind.LockedSessions)
+	}
+
+	if !c.Now().Equal(friday(19, 59, 0)) {
+		t.Errorf("Expected the clock at 19:59, got %v", c.Now())
+	}
+	if used, _ := store.GetTodayUsageSeconds(user.ID); used != 57*60+4*60 {
+		t.Errorf("Expected 61 minutes used by 19:59, got %ds", used)
+	}
+}
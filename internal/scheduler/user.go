@@ -0,0 +1,306 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/dbus"
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+// userLoop enforces the limits of one user in its own goroutine, so that
+// locking one child never delays warnings or accounting for anyone else.
+// It sleeps until the user's next event (a warning, the end of the
+// budget, window or grace period) or until the accounting loop wakes it.
+type userLoop struct {
+	mu       sync.Mutex
+	user     *storage.User
+	sessions []dbus.Session
+	counted  bool // logged in and not paused
+
+	// accountedAt is when the stored usage was last brought up to date.
+	// While counted, the user has used the time since on top of it.
+	accountedAt time.Time
+
+	// graceUntil is when the lock follows the lock notice. It is zero while
+	// the user has time left and survives logging out and back in.
+	graceUntil time.Time
+	enforced   bool
+	alerted    bool // parents were told the lock failed
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newUserLoop(user *storage.User) *userLoop {
+	return &userLoop{
+		user: user,
+		wake: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+// poke wakes the loop without blocking; a pending wake-up is enough
+func (u *userLoop) poke() {
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+}
+
+// updateUserLoop hands a user's latest state to their loop, starting one
+// the first time they log in, and wakes it
+func (s *Scheduler) updateUserLoop(ctx context.Context, user *storage.User, sessions []dbus.Session, counted bool, accountedAt time.Time) {
+	s.mu.Lock()
+	loop, ok := s.userLoops[user.Username]
+	if !ok {
+		if !counted {
+			s.mu.Unlock()
+			return
+		}
+		loop = newUserLoop(user)
+		s.userLoops[user.Username] = loop
+		if s.manual {
+			close(loop.done)
+		} else {
+			go s.runUserLoop(ctx, loop)
+		}
+	}
+	s.mu.Unlock()
+
+	loop.mu.Lock()
+	loop.user = user
+	loop.sessions = sessions
+	loop.counted = counted
+	loop.accountedAt = accountedAt
+	loop.mu.Unlock()
+
+	loop.poke()
+}
+
+// stopUserLoops stops the loops of all users not in keep, or every loop if
+// keep is nil, and waits for them to finish
+func (s *Scheduler) stopUserLoops(keep map[string]bool) {
+	s.mu.Lock()
+	var stopping []*userLoop
+	for username, loop := range s.userLoops {
+		if !keep[username] {
+			delete(s.userLoops, username)
+			stopping = append(stopping, loop)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, loop := range stopping {
+		close(loop.stop)
+		<-loop.done
+	}
+}
+
+func (s *Scheduler) runUserLoop(ctx context.Context, u *userLoop) {
+	defer close(u.done)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-u.wake:
+		case <-u.stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+
+		now := s.clock.Now()
+		next := s.wakeForRules(u, now, s.enforce(ctx, u, now))
+
+		timer.Stop()
+		if !next.IsZero() {
+			timer.Reset(next.Sub(now))
+		}
+	}
+}
+
+// enforce applies rules, warnings and the lock for the user's current state
+// and returns when it needs to run next, to the second. A zero time means
+// only when woken.
+func (s *Scheduler) enforce(ctx context.Context, u *userLoop, now time.Time) time.Time {
+	u.mu.Lock()
+	user, sessions, counted, accountedAt := u.user, u.sessions, u.counted, u.accountedAt
+	u.mu.Unlock()
+
+	if !counted {
+		return time.Time{}
+	}
+
+	// Time used since the last accounting tick is not stored yet
+	var unaccounted time.Duration
+	if !accountedAt.IsZero() && now.After(accountedAt) {
+		unaccounted = now.Sub(accountedAt)
+	}
+
+	policy, err := s.store.GetTodayPolicy(user.ID)
+	if err != nil {
+		log.Printf("Failed to get policy for %s: %v", user.Username, err)
+		return time.Time{}
+	}
+
+	if s.rules != nil && s.applyRules(ctx, u, user, sessions, policy, now) {
+		return time.Time{}
+	}
+
+	// The household budget is shared, so it applies even on unlimited days.
+	// Everyone counting has used it since the last tick.
+	var budgetEnd time.Time
+	if budget, err := s.store.GetHouseholdBudget(); err != nil {
+		log.Printf("Failed to get household budget: %v", err)
+	} else if budget != nil {
+		users := time.Duration(s.countingUsers())
+		left := time.Duration(budget.RemainingSeconds)*time.Second - users*unaccounted
+		if left <= 0 {
+			return s.expire(ctx, u, user.Username, policy.EnforcementMode, "household budget used up", now)
+		}
+		budgetEnd = now.Add(left / users)
+	}
+
+	if policy.Unlimited && len(policy.Windows) == 0 {
+		u.clearExpiry()
+		return budgetEnd
+	}
+
+	remainingSecs, err := s.store.GetRemainingSeconds(user.ID)
+	if err != nil {
+		log.Printf("Failed to get remaining time for %s: %v", user.Username, err)
+		return time.Time{}
+	}
+	remaining := time.Duration(remainingSecs)*time.Second - unaccounted
+
+	// Allowed windows cut the remaining time short at the end of the window
+	if untilClose, ok := policy.SecondsUntilClose(now); ok {
+		if d := time.Duration(untilClose)*time.Second - time.Duration(now.Nanosecond()); d < remaining {
+			remaining = d
+		}
+	}
+
+	if remaining <= 0 {
+		return s.expire(ctx, u, user.Username, policy.EnforcementMode, "time expired", now)
+	}
+
+	u.clearExpiry()
+	mins := int((remaining + time.Minute - 1) / time.Minute)
+	next := s.checkWarnings(ctx, user.Username, mins, policy.WarningIntervals)
+	at := now.Add(remaining - time.Duration(next)*time.Minute)
+	if !budgetEnd.IsZero() && budgetEnd.Before(at) {
+		return budgetEnd
+	}
+	return at
+}
+
+// wakeForRules brings next forward to the next time a rule's time or
+// weekday condition can change, so that a rule starting at 20:00 takes
+// effect at 20:00 rather than on the next accounting tick
+func (s *Scheduler) wakeForRules(u *userLoop, now, next time.Time) time.Time {
+	if s.rules == nil {
+		return next
+	}
+
+	u.mu.Lock()
+	username, counted := u.user.Username, u.counted
+	u.mu.Unlock()
+	if !counted {
+		return next
+	}
+
+	boundary := s.rules.NextBoundary(username, now)
+	if !boundary.IsZero() && (next.IsZero() || boundary.Before(next)) {
+		return boundary
+	}
+	return next
+}
+
+// countingUsers returns how many users' time is counting, at least one
+func (s *Scheduler) countingUsers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, loop := range s.userLoops {
+		loop.mu.Lock()
+		if loop.counted {
+			n++
+		}
+		loop.mu.Unlock()
+	}
+	if n == 0 {
+		n = 1
+	}
+	return n
+}
+
+// clearExpiry resets the expiry state once a user has time again
+func (u *userLoop) clearExpiry() {
+	u.graceUntil = time.Time{}
+	u.enforced = false
+	u.alerted = false
+}
+
+// expire notifies the user that their time is up and, once the grace
+// period has passed, locks or logs them out. It returns when the lock is
+// due; after that the lock is re-applied whenever the loop is woken.
+func (s *Scheduler) expire(ctx context.Context, u *userLoop, username, mode, reason string, now time.Time) time.Time {
+	if mode == storage.EnforcementWarn {
+		// Notify once instead of enforcing; interval 0 marks the notice as sent
+		s.mu.Lock()
+		if s.warningsSent[username] == nil {
+			s.warningsSent[username] = make(map[int]bool)
+		}
+		sent := s.warningsSent[username][0]
+		s.warningsSent[username][0] = true
+		s.mu.Unlock()
+
+		if !sent {
+			log.Printf("User %s: %s, warning only", username, reason)
+			if err := s.notifier.SendLockNotice(ctx, username); err != nil {
+				log.Printf("Failed to send lock notice to %s: %v", username, err)
+			}
+		}
+		return time.Time{}
+	}
+
+	if u.graceUntil.IsZero() {
+		u.graceUntil = now.Add(s.config.GracePeriod)
+		log.Printf("User %s: %s, enforcing at %s", username, reason, u.graceUntil.Format("15:04:05"))
+		if err := s.notifier.SendLockNotice(ctx, username); err != nil {
+			log.Printf("Failed to send lock notice to %s: %v", username, err)
+		}
+	}
+
+	if now.Before(u.graceUntil) {
+		return u.graceUntil
+	}
+
+	if !u.enforced {
+		u.enforced = true
+		if mode == storage.EnforcementLogout {
+			log.Printf("Logging out user %s", username)
+		} else {
+			log.Printf("Locking session of user %s", username)
+		}
+	}
+
+	if mode == storage.EnforcementLogout {
+		if err := s.logind.TerminateUserSessions(username); err != nil {
+			log.Printf("Failed to terminate sessions for %s: %v", username, err)
+		}
+		return time.Time{}
+	}
+
+	s.lockUser(ctx, u, username)
+	return time.Time{}
+}
@@ -0,0 +1,300 @@
+package scheduler
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/config"
+	"github.com/florian/screentime-guardian/internal/notifier"
+	"github.com/florian/screentime-guardian/internal/rules"
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+func TestEnforceEvents(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	mock := notifier.NewMockNotifier()
+	s := New(store, nil, notifier.NewChain(mock), config.Default())
+	ctx := context.Background()
+
+	user, _ := store.CreateUser("testuser", 60)
+	loop := newUserLoop(user)
+	loop.counted = true
+	now := time.Now()
+
+	// 20 minutes left: no warning yet, wake up when the 5 minute one is due
+	store.AddUsageTime(user.ID, 40*60)
+	if next := s.enforce(ctx, loop, now); !next.Equal(now.Add(15 * time.Minute)) {
+		t.Errorf("Expected next event in 15 minutes, got %v", next.Sub(now))
+	}
+	if len(mock.WarningCalls) != 0 {
+		t.Errorf("Expected no warnings yet, got %+v", mock.WarningCalls)
+	}
+
+	// 3 minutes left: warn, then wake up for the 1 minute warning
+	store.AddUsageTime(user.ID, 17*60)
+	if next := s.enforce(ctx, loop, now); !next.Equal(now.Add(2 * time.Minute)) {
+		t.Errorf("Expected next event in 2 minutes, got %v", next.Sub(now))
+	}
+	if len(mock.WarningCalls) != 1 {
+		t.Errorf("Expected one warning, got %+v", mock.WarningCalls)
+	}
+
+	// Time is up: the lock notice starts the grace period
+	store.AddUsageTime(user.ID, 3*60)
+	next := s.enforce(ctx, loop, now)
+	if !next.Equal(now.Add(s.config.GracePeriod)) {
+		t.Errorf("Expected the lock after the grace period, got %v", next.Sub(now))
+	}
+	s.enforce(ctx, loop, now.Add(10*time.Second))
+	if len(mock.LockCalls) != 1 {
+		t.Errorf("Expected a single lock notice during the grace period, got %d", len(mock.LockCalls))
+	}
+
+	// More time ends the grace period
+	store.GrantTimeExtension(user.ID, 15, "parent", "", nil)
+	s.ResetWarnings(user.Username)
+	s.enforce(ctx, loop, now)
+	if !loop.graceUntil.IsZero() {
+		t.Error("Expected extension to clear the grace period")
+	}
+
+	// A logged-out or paused user is left alone
+	loop.counted = false
+	if next := s.enforce(ctx, loop, now); !next.IsZero() {
+		t.Errorf("Expected no events for an inactive user, got %v", next)
+	}
+}
+
+func TestUserLoopLifecycle(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	s := New(store, nil, notifier.NewChain(), config.Default())
+	ctx := context.Background()
+
+	alice, _ := store.CreateUser("alice", 120)
+	bob, _ := store.CreateUser("bob", 120)
+
+	s.updateUserLoop(ctx, alice, nil, false, time.Now())
+	if len(s.userLoops) != 0 {
+		t.Fatal("Expected no loop before the user logs in")
+	}
+
+	s.updateUserLoop(ctx, alice, nil, true, time.Now())
+	s.updateUserLoop(ctx, bob, nil, true, time.Now())
+	if len(s.userLoops) != 2 {
+		t.Fatalf("Expected 2 loops, got %d", len(s.userLoops))
+	}
+
+	// Logging out keeps the loop and its state
+	s.updateUserLoop(ctx, alice, nil, false, time.Now())
+	s.stopUserLoops(map[string]bool{"alice": true})
+	if _, ok := s.userLoops["alice"]; !ok || len(s.userLoops) != 1 {
+		t.Errorf("Expected only alice's loop to remain, got %v", s.userLoops)
+	}
+
+	s.stopUserLoops(nil)
+	if len(s.userLoops) != 0 {
+		t.Errorf("Expected all loops stopped, got %d", len(s.userLoops))
+	}
+}
+
+func TestEnforceDeadline(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	mock := notifier.NewMockNotifier()
+	s := New(store, nil, notifier.NewChain(mock), config.Default())
+	ctx := context.Background()
+
+	user, _ := store.CreateUser("testuser", 10)
+	store.AddUsageTime(user.ID, 9*60+30)
+
+	// 30 seconds were left at the last tick, 10 seconds ago
+	now := time.Now()
+	loop := newUserLoop(user)
+	loop.counted = true
+	loop.accountedAt = now.Add(-10 * time.Second)
+
+	if next := s.enforce(ctx, loop, now); !next.Equal(now.Add(20 * time.Second)) {
+		t.Errorf("Expected the lock in exactly 20 seconds, got %v", next.Sub(now))
+	}
+	if len(mock.WarningCalls) == 0 {
+		t.Error("Expected a warning with 30 seconds left")
+	}
+
+	// The timer fires at the deadline, before the next tick stores the usage
+	s.enforce(ctx, loop, now.Add(20*time.Second))
+	if len(mock.LockCalls) != 1 {
+		t.Errorf("Expected the lock notice at the deadline, got %d", len(mock.LockCalls))
+	}
+
+	// On an unlimited day only the household budget has a deadline; 9:30 of
+	// its 15 minutes are used
+	kid, _ := store.CreateUser("kid", 60)
+	store.CreateDateOverride(&storage.DateOverride{
+		UserID:    kid.ID,
+		StartDate: now.Format("2006-01-02"),
+		EndDate:   now.Format("2006-01-02"),
+		Unlimited: true,
+	})
+	store.SetHouseholdBudgetMins(15)
+
+	kidLoop := newUserLoop(kid)
+	kidLoop.counted = true
+	kidLoop.accountedAt = now
+	if next := s.enforce(ctx, kidLoop, now); !next.Equal(now.Add(5*time.Minute + 30*time.Second)) {
+		t.Errorf("Expected the budget to run out in 5m30s, got %v", next.Sub(now))
+	}
+}
+
+// blockingNotifier holds notifications to one user until release is closed,
+// like a notify-send that hangs
+type blockingNotifier struct {
+	*notifier.MockNotifier
+	user    string
+	blocked chan struct{} // closed once a notification to user is held
+	once    sync.Once
+	release chan struct{}
+}
+
+func newBlockingNotifier(user string) *blockingNotifier {
+	return &blockingNotifier{
+		MockNotifier: notifier.NewMockNotifier(),
+		user:         user,
+		blocked:      make(chan struct{}),
+		release:      make(chan struct{}),
+	}
+}
+
+func (b *blockingNotifier) hold(username string) {
+	if username == b.user {
+		b.once.Do(func() { close(b.blocked) })
+		<-b.release
+	}
+}
+
+func (b *blockingNotifier) SendWarning(ctx context.Context, username string, minutesLeft int) error {
+	b.hold(username)
+	return b.MockNotifier.SendWarning(ctx, username, minutesLeft)
+}
+
+func (b *blockingNotifier) SendMessage(ctx context.Context, username, title, body string) error {
+	b.hold(username)
+	return b.MockNotifier.SendMessage(ctx, username, title, body)
+}
+
+func TestHungWarningBlocksNobodyElse(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	blocking := newBlockingNotifier("alice")
+	s := New(store, nil, notifier.NewChain(blocking), config.Default())
+	ctx := context.Background()
+
+	alice, _ := store.CreateUser("alice", 60)
+	bob, _ := store.CreateUser("bob", 60)
+	store.AddUsageTime(alice.ID, 57*60)
+	store.AddUsageTime(bob.ID, 57*60)
+
+	s.updateUserLoop(ctx, alice, nil, true, time.Now())
+	select {
+	case <-blocking.blocked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected alice's warning to be sent")
+	}
+
+	// While alice's warning hangs, bob's loop starts and warns him, and
+	// the scheduler's bookkeeping carries on
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.updateUserLoop(ctx, bob, nil, true, time.Now())
+		s.countingUsers()
+		s.ResetWarnings("carol")
+	}()
+
+	waitFor(t, "bob's warning", func() bool {
+		blocking.Mu.Lock()
+		defer blocking.Mu.Unlock()
+		for _, call := range blocking.WarningCalls {
+			if call.Username == "bob" {
+				return true
+			}
+		}
+		return false
+	})
+	<-done
+
+	close(blocking.release)
+	s.stopUserLoops(nil)
+}
+
+func TestWakeForRules(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := storage.New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	s := New(store, nil, notifier.NewChain(), config.Default())
+	ruleset, err := rules.Parse(strings.NewReader(`
+rules:
+  - name: bedtime
+    when:
+      after: "20:00"
+    then:
+      action: lock
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	s.SetRules(rules.NewEngine(ruleset))
+
+	user, _ := store.CreateUser("kid", 120)
+	loop := newUserLoop(user)
+	loop.counted = true
+
+	now := time.Date(2026, 10, 16, 19, 50, 0, 0, time.Local)
+	bedtime := time.Date(2026, 10, 16, 20, 0, 0, 0, time.Local)
+
+	// An hour of time left still wakes the loop for bedtime
+	if next := s.wakeForRules(loop, now, now.Add(time.Hour)); !next.Equal(bedtime) {
+		t.Errorf("Expected to wake at bedtime, got %v", next)
+	}
+	if next := s.wakeForRules(loop, now, time.Time{}); !next.Equal(bedtime) {
+		t.Errorf("Expected to wake at bedtime instead of only when woken, got %v", next)
+	}
+	if next := s.wakeForRules(loop, now, now.Add(5*time.Minute)); !next.Equal(now.Add(5 * time.Minute)) {
+		t.Errorf("Expected an earlier deadline to stay, got %v", next)
+	}
+
+	// Rules only apply while the user's time counts
+	loop.counted = false
+	if next := s.wakeForRules(loop, now, time.Time{}); !next.IsZero() {
+		t.Errorf("Expected no wake-up for a user who is not counting, got %v", next)
+	}
+}
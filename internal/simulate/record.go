@@ -0,0 +1,170 @@
+package simulate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/clock"
+	"github.com/florian/screentime-guardian/internal/dbus"
+)
+
+// household labels timeline entries that concern everyone
+const household = "household"
+
+// timeline writes one line per event, stamped with the simulated time
+type timeline struct {
+	w     io.Writer
+	clock clock.Clock
+}
+
+func (t *timeline) printf(who, format string, args ...interface{}) {
+	fmt.Fprintf(t.w, "%s  %-10s %s\n", t.clock.Now().Format("15:04:05"), who, fmt.Sprintf(format, args...))
+}
+
+// recordingNotifier puts every notification on the timeline
+type recordingNotifier struct {
+	out *timeline
+}
+
+func (n *recordingNotifier) SendWarning(ctx context.Context, username string, minutesLeft int) error {
+	n.out.printf(username, "warning: %d min left", minutesLeft)
+	return nil
+}
+
+func (n *recordingNotifier) SendLockNotice(ctx context.Context, username string) error {
+	n.out.printf(username, "lock notice: time is up")
+	return nil
+}
+
+func (n *recordingNotifier) SendTimeExtended(ctx context.Context, username string, minutes int) error {
+	n.out.printf(username, "told about %d extra min", minutes)
+	return nil
+}
+
+func (n *recordingNotifier) SendRequestDecision(ctx context.Context, username string, status string, minutes int) error {
+	n.out.printf(username, "request %s (%d min)", status, minutes)
+	return nil
+}
+
+func (n *recordingNotifier) SendMessage(ctx context.Context, username, title, body string) error {
+	n.out.printf(username, "message: %s: %s", title, body)
+	return nil
+}
+
+func (n *recordingNotifier) SendParentAlert(ctx context.Context, username, message string) error {
+	if username == "" {
+		username = household
+	}
+	n.out.printf(username, "parent alert: %s", message)
+	return nil
+}
+
+// recordingLogind is the mock logind with its locks, logouts and power
+// actions on the timeline. Logging a user out ends their sessions, so their
+// time stops counting.
+type recordingLogind struct {
+	*dbus.MockLogindClient
+	out *timeline
+}
+
+func (l *recordingLogind) login(session dbus.Session) {
+	l.Mu.Lock()
+	defer l.Mu.Unlock()
+
+	for _, s := range l.Sessions {
+		if s.UserName == session.UserName {
+			return
+		}
+	}
+	l.Sessions = append(l.Sessions, session)
+}
+
+// logout ends the user's sessions, or only sessionID if it is set
+func (l *recordingLogind) logout(username, sessionID string) {
+	l.Mu.Lock()
+	defer l.Mu.Unlock()
+
+	kept := l.Sessions[:0]
+	for _, s := range l.Sessions {
+		if s.UserName != username || (sessionID != "" && s.ID != sessionID) {
+			kept = append(kept, s)
+		}
+	}
+	l.Sessions = kept
+}
+
+// unlock clears the lock of the user's sessions, as their password would
+func (l *recordingLogind) unlock(username string) {
+	l.Mu.Lock()
+	defer l.Mu.Unlock()
+
+	for _, s := range l.Sessions {
+		if s.UserName == username {
+			delete(l.LockedHints, s.ID)
+		}
+	}
+}
+
+func (l *recordingLogind) owner(sessionID string) string {
+	l.Mu.Lock()
+	defer l.Mu.Unlock()
+
+	for _, s := range l.Sessions {
+		if s.ID == sessionID {
+			return s.UserName
+		}
+	}
+	return sessionID
+}
+
+func (l *recordingLogind) LockSession(sessionID string) error {
+	if err := l.MockLogindClient.LockSession(sessionID); err != nil {
+		return err
+	}
+	l.out.printf(l.owner(sessionID), "screen locked")
+	return nil
+}
+
+func (l *recordingLogind) LockUserSessions(username string) error {
+	if err := l.MockLogindClient.LockUserSessions(username); err != nil {
+		return err
+	}
+	l.out.printf(username, "screen locked")
+	return nil
+}
+
+func (l *recordingLogind) TerminateSession(sessionID string) error {
+	username := l.owner(sessionID)
+	if err := l.MockLogindClient.TerminateSession(sessionID); err != nil {
+		return err
+	}
+	l.logout(username, sessionID)
+	l.out.printf(username, "logged out")
+	return nil
+}
+
+func (l *recordingLogind) TerminateUserSessions(username string) error {
+	if err := l.MockLogindClient.TerminateUserSessions(username); err != nil {
+		return err
+	}
+	l.logout(username, "")
+	l.out.printf(username, "logged out")
+	return nil
+}
+
+func (l *recordingLogind) Suspend() error {
+	l.out.printf(household, "computer goes to sleep")
+	return l.MockLogindClient.Suspend()
+}
+
+func (l *recordingLogind) PowerOff() error {
+	l.out.printf(household, "computer powers off")
+	return l.MockLogindClient.PowerOff()
+}
+
+func (l *recordingLogind) ScheduleShutdown(kind string, at time.Time) error {
+	l.out.printf(household, "%s scheduled for %s", kind, at.Format("15:04:05"))
+	return l.MockLogindClient.ScheduleShutdown(kind, at)
+}
@@ -0,0 +1,156 @@
+package simulate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/clock"
+	"github.com/florian/screentime-guardian/internal/config"
+	"github.com/florian/screentime-guardian/internal/dbus"
+	"github.com/florian/screentime-guardian/internal/notifier"
+	"github.com/florian/screentime-guardian/internal/scheduler"
+	"github.com/florian/screentime-guardian/internal/storage"
+)
+
+// simulatedBy is recorded as the parent granting extensions and pauses
+const simulatedBy = "simulation"
+
+// Run replays the script against a throwaway database and writes the
+// timeline and the final usage to w. cfg supplies the warning intervals,
+// grace period, check interval and power settings; its database is never
+// opened. Rules are not evaluated, since they look at real processes.
+func Run(ctx context.Context, script *Script, cfg *config.Config, w io.Writer) error {
+	dir, err := os.MkdirTemp("", "screentime-simulate-")
+	if err != nil {
+		return fmt.Errorf("failed to create database directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := storage.New(filepath.Join(dir, "simulate.db"))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	c := clock.NewFake(script.start)
+	store.SetClock(c)
+
+	simCfg := *cfg
+	simCfg.DatabasePath = filepath.Join(dir, "simulate.db")
+	simCfg.RulesFile = ""
+	simCfg.LockVerifyDelay = time.Millisecond
+
+	out := &timeline{w: w, clock: c}
+	logind := &recordingLogind{MockLogindClient: dbus.NewMockLogindClient(), out: out}
+	sched := scheduler.New(store, logind, notifier.NewChain(&recordingNotifier{out: out}), &simCfg)
+	sim := scheduler.NewSimulator(sched, c)
+
+	if err := store.SetHouseholdBudgetMins(script.HouseholdBudgetMins); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "Simulating %s from %s to %s\n\n",
+		script.start.Format("Monday 2006-01-02"), script.start.Format("15:04"), script.end.Format("15:04:05"))
+
+	users := make(map[string]*storage.User)
+	uids := make(map[string]uint32)
+	for i, u := range script.Users {
+		user, err := store.CreateUser(u.Name, u.DailyLimitMins)
+		if err != nil {
+			return err
+		}
+		if u.UsedMins > 0 {
+			if err := store.AddUsageTime(user.ID, u.UsedMins*60); err != nil {
+				return err
+			}
+		}
+		users[u.Name] = user
+		uids[u.Name] = uint32(1000 + i)
+
+		remaining, err := store.GetRemainingSeconds(user.ID)
+		if err != nil {
+			return err
+		}
+		out.printf(u.Name, "%d min used, %s left", u.UsedMins, formatRemaining(remaining))
+	}
+
+	nextSession := 1
+	for _, e := range script.Events {
+		sim.RunUntil(ctx, e.at)
+		user := users[e.User]
+
+		switch e.Action {
+		case ActionLogin:
+			out.printf(e.User, "logs in")
+			logind.login(dbus.Session{ID: fmt.Sprint(nextSession), UserID: uids[e.User], UserName: e.User})
+			nextSession++
+		case ActionLogout:
+			out.printf(e.User, "logs out")
+			logind.logout(e.User, "")
+		case ActionIdle:
+			out.printf(e.User, "leaves the computer without logging out, time keeps counting")
+		case ActionUnlock:
+			out.printf(e.User, "unlocks the screen")
+			logind.unlock(e.User)
+		case ActionExtend:
+			if _, err := store.GrantTimeExtension(user.ID, e.Minutes, simulatedBy, e.Reason, nil); err != nil {
+				return err
+			}
+			out.printf(e.User, "gets %d extra min%s", e.Minutes, formatReason(e.Reason))
+		case ActionDeduct:
+			if err := store.AddTimeDeduction(user.ID, e.Minutes, e.Reason, c.Now().Format(dateLayout), simulatedBy); err != nil {
+				return err
+			}
+			out.printf(e.User, "loses %d min%s", e.Minutes, formatReason(e.Reason))
+		case ActionPause:
+			if _, err := store.PauseUser(user.ID, time.Duration(e.Minutes)*time.Minute, e.Reason, simulatedBy); err != nil {
+				return fmt.Errorf("%s at %s: %w", e.Action, e.At, err)
+			}
+			if e.Minutes > 0 {
+				out.printf(e.User, "counter paused for %d min%s", e.Minutes, formatReason(e.Reason))
+			} else {
+				out.printf(e.User, "counter paused until resumed%s", formatReason(e.Reason))
+			}
+		case ActionResume:
+			if _, err := store.ResumeUser(user.ID, simulatedBy); err != nil {
+				return fmt.Errorf("%s at %s: %w", e.Action, e.At, err)
+			}
+			out.printf(e.User, "counter resumed")
+		}
+	}
+	sim.RunUntil(ctx, script.end)
+
+	fmt.Fprintf(w, "\nUsage at %s:\n", script.end.Format("15:04:05"))
+	for _, u := range script.Users {
+		user := users[u.Name]
+		used, err := store.GetTodayUsageSeconds(user.ID)
+		if err != nil {
+			return err
+		}
+		remaining, err := store.GetRemainingSeconds(user.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "  %-10s %d:%02d used, %s left\n", u.Name, used/60, used%60, formatRemaining(remaining))
+	}
+
+	return nil
+}
+
+func formatRemaining(seconds int) string {
+	if seconds == storage.UnlimitedSeconds {
+		return "unlimited time"
+	}
+	return fmt.Sprintf("%d min", storage.MinutesRoundedUp(seconds))
+}
+
+func formatReason(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", reason)
+}
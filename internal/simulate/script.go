@@ -0,0 +1,202 @@
+// Package simulate replays a scripted day against a throwaway database on a
+// fake clock and reports the warnings, locks and usage the scheduler would
+// produce, e.g. to check what happens on Friday at 19:55 if a child has
+// three minutes left.
+package simulate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Actions an event can take
+const (
+	ActionLogin  = "login"  // the user logs in
+	ActionLogout = "logout" // the user logs out
+	ActionIdle   = "idle"   // the user leaves the computer without logging out
+	ActionUnlock = "unlock" // the user unlocks their screen
+	ActionExtend = "extend" // a parent grants extra minutes
+	ActionDeduct = "deduct" // a parent takes minutes away
+	ActionPause  = "pause"  // a parent pauses the counter, for minutes or until resumed
+	ActionResume = "resume" // a parent resumes the counter
+)
+
+const (
+	dateTimeLayout = "2006-01-02 15:04"
+	dateLayout     = "2006-01-02"
+)
+
+// Script is a scripted day
+type Script struct {
+	Start               string  `yaml:"start"` // YYYY-MM-DD HH:MM
+	End                 string  `yaml:"end"`   // HH:MM, default an hour after the last event
+	HouseholdBudgetMins int     `yaml:"household_budget_mins"`
+	Users               []User  `yaml:"users"`
+	Events              []Event `yaml:"events"`
+
+	// Where the simulated clock starts and stops, resolved by Validate from
+	// Start and End (or the last event)
+	start time.Time
+	end   time.Time
+}
+
+// User is a child in the simulation
+type User struct {
+	Name           string `yaml:"name"`
+	DailyLimitMins int    `yaml:"daily_limit_mins"`
+	UsedMins       int    `yaml:"used_mins"` // used before the start
+}
+
+// Event is something that happens during the day
+type Event struct {
+	At      string `yaml:"at"` // HH:MM or HH:MM:SS on the start day
+	User    string `yaml:"user"`
+	Action  string `yaml:"action"`
+	Minutes int    `yaml:"minutes"`
+	Reason  string `yaml:"reason"`
+
+	at time.Time
+}
+
+// Load reads and validates a script file
+func Load(path string) (*Script, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	script, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return script, nil
+}
+
+// Parse decodes and validates a script from YAML. Unknown keys are rejected
+// so that typos do not silently change the scenario.
+func Parse(r io.Reader) (*Script, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+
+	script := &Script{}
+	if err := dec.Decode(script); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse script: %w", err)
+	}
+
+	if err := script.Validate(); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// Validate checks the script, resolves the event times and sorts the
+// events by time
+func (s *Script) Validate() error {
+	start, err := time.ParseInLocation(dateTimeLayout, s.Start, time.Local)
+	if err != nil {
+		return fmt.Errorf("start must be YYYY-MM-DD HH:MM, got %q", s.Start)
+	}
+	s.start = start
+
+	if s.HouseholdBudgetMins < 0 {
+		return fmt.Errorf("household_budget_mins cannot be negative")
+	}
+
+	if len(s.Users) == 0 {
+		return fmt.Errorf("at least one user is required")
+	}
+	users := make(map[string]bool)
+	for _, u := range s.Users {
+		if u.Name == "" {
+			return fmt.Errorf("every user needs a name")
+		}
+		if users[u.Name] {
+			return fmt.Errorf("user %s is listed twice", u.Name)
+		}
+		if u.DailyLimitMins <= 0 {
+			return fmt.Errorf("user %s: daily_limit_mins must be positive", u.Name)
+		}
+		if u.UsedMins < 0 {
+			return fmt.Errorf("user %s: used_mins cannot be negative", u.Name)
+		}
+		users[u.Name] = true
+	}
+
+	for i := range s.Events {
+		e := &s.Events[i]
+		at, err := s.timeOfDay(e.At)
+		if err != nil {
+			return fmt.Errorf("event %d: %w", i+1, err)
+		}
+		if at.Before(start) {
+			return fmt.Errorf("event %d: %s is before the start", i+1, e.At)
+		}
+		e.at = at
+
+		if !users[e.User] {
+			return fmt.Errorf("event %d: unknown user %q", i+1, e.User)
+		}
+
+		switch e.Action {
+		case ActionLogin, ActionLogout, ActionIdle, ActionUnlock, ActionResume:
+		case ActionExtend, ActionDeduct:
+			if e.Minutes <= 0 {
+				return fmt.Errorf("event %d: %s needs positive minutes", i+1, e.Action)
+			}
+		case ActionPause:
+			if e.Minutes < 0 {
+				return fmt.Errorf("event %d: pause minutes cannot be negative", i+1)
+			}
+		default:
+			return fmt.Errorf("event %d: unknown action %q", i+1, e.Action)
+		}
+	}
+
+	// Events at the same time keep their order
+	sort.SliceStable(s.Events, func(i, j int) bool {
+		return s.Events[i].at.Before(s.Events[j].at)
+	})
+
+	s.end = start.Add(time.Hour)
+	if len(s.Events) > 0 {
+		s.end = s.Events[len(s.Events)-1].at.Add(time.Hour)
+	}
+	if s.End != "" {
+		end, err := s.timeOfDay(s.End)
+		if err != nil {
+			return fmt.Errorf("end: %w", err)
+		}
+		if len(s.Events) > 0 && end.Before(s.Events[len(s.Events)-1].at) {
+			return fmt.Errorf("end %s is before the last event", s.End)
+		}
+		s.end = end
+	}
+	if s.end.Before(start) {
+		return fmt.Errorf("end %s is before the start", s.End)
+	}
+
+	// Usage is kept per day, so the simulation stays on the start day
+	if midnight := time.Date(start.Year(), start.Month(), start.Day()+1, 0, 0, 0, 0, time.Local); !s.end.Before(midnight) {
+		s.end = midnight.Add(-time.Second)
+	}
+
+	return nil
+}
+
+// timeOfDay parses HH:MM or HH:MM:SS as a time on the start day
+func (s *Script) timeOfDay(value string) (time.Time, error) {
+	day := s.start.Format(dateLayout)
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.ParseInLocation(dateLayout+" "+layout, day+" "+value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("time must be HH:MM or HH:MM:SS, got %q", value)
+}
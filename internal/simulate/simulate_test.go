@@ -0,0 +1,104 @@
+package simulate
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/florian/screentime-guardian/internal/config"
+)
+
+const testScript = `
+start: "2026-10-16 19:50"
+end: "20:10"
+users:
+  - name: alice
+    daily_limit_mins: 60
+    used_mins: 57
+  - name: ben
+    daily_limit_mins: 90
+events:
+  - at: "19:55"
+    user: alice
+    action: login
+  - at: "20:05"
+    user: alice
+    action: logout
+  - at: "20:00"
+    user: ben
+    action: extend
+    minutes: 15
+    reason: chores
+`
+
+func TestParse(t *testing.T) {
+	script, err := Parse(strings.NewReader(testScript))
+	if err != nil {
+		t.Fatalf("Failed to parse script: %v", err)
+	}
+
+	// Events are sorted by time
+	var order []string
+	for _, e := range script.Events {
+		order = append(order, e.At)
+	}
+	if got := strings.Join(order, ","); got != "19:55,20:00,20:05" {
+		t.Errorf("Expected events sorted by time, got %s", got)
+	}
+	if got := script.end.Format("15:04"); got != "20:10" {
+		t.Errorf("Expected the end at 20:10, got %s", got)
+	}
+
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{"unknown key", "start: \"2026-10-16 19:50\"\nusers: [{name: a, daily_limit_mins: 60}]\nbudget: 3\n", "field budget not found"},
+		{"bad start", "start: friday\nusers: [{name: a, daily_limit_mins: 60}]\n", "start must be"},
+		{"no users", "start: \"2026-10-16 19:50\"\n", "at least one user"},
+		{"duplicate user", "start: \"2026-10-16 19:50\"\nusers: [{name: a, daily_limit_mins: 60}, {name: a, daily_limit_mins: 30}]\n", "listed twice"},
+		{"unknown user", "start: \"2026-10-16 19:50\"\nusers: [{name: a, daily_limit_mins: 60}]\nevents: [{at: \"20:00\", user: b, action: login}]\n", "unknown user"},
+		{"unknown action", "start: \"2026-10-16 19:50\"\nusers: [{name: a, daily_limit_mins: 60}]\nevents: [{at: \"20:00\", user: a, action: dance}]\n", "unknown action"},
+		{"bad time", "start: \"2026-10-16 19:50\"\nusers: [{name: a, daily_limit_mins: 60}]\nevents: [{at: \"8pm\", user: a, action: login}]\n", "HH:MM"},
+		{"event before start", "start: \"2026-10-16 19:50\"\nusers: [{name: a, daily_limit_mins: 60}]\nevents: [{at: \"19:00\", user: a, action: login}]\n", "before the start"},
+		{"extend without minutes", "start: \"2026-10-16 19:50\"\nusers: [{name: a, daily_limit_mins: 60}]\nevents: [{at: \"20:00\", user: a, action: extend}]\n", "positive minutes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.script))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	script, err := Parse(strings.NewReader(testScript))
+	if err != nil {
+		t.Fatalf("Failed to parse script: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Run(context.Background(), script, config.Default(), &out); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	timeline := out.String()
+
+	for _, want := range []string{
+		"Simulating Friday 2026-10-16 from 19:50 to 20:10:00",
+		"19:55:00  alice      logs in",
+		"19:55:30  alice      warning: 3 min left",
+		"19:58:00  alice      lock notice: time is up",
+		"19:59:00  alice      screen locked",
+		"20:00:00  ben        gets 15 extra min (chores)",
+		"  alice      67:00 used, 0 min left",
+		"  ben        0:00 used, 105 min left",
+	} {
+		if !strings.Contains(timeline, want) {
+			t.Errorf("Expected %q in the timeline:\n%s", want, timeline)
+		}
+	}
+}
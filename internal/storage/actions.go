@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Scheduled action types
+const (
+	ScheduledLock      = "lock"
+	ScheduledTerminate = "terminate"
+	ScheduledExtend    = "extend"
+	ScheduledPause     = "pause"
+	ScheduledMessage   = "message"
+)
+
+// Scheduled action states. An action moves from pending to running before
+// it is executed, so a crash mid-execution never runs it a second time.
+const (
+	ScheduledPending   = "pending"
+	ScheduledRunning   = "running"
+	ScheduledDone      = "done"
+	ScheduledFailed    = "failed"
+	ScheduledCancelled = "cancelled"
+)
+
+// ErrInvalidScheduledAction is returned for an unknown action type or
+// missing minutes or message
+var ErrInvalidScheduledAction = errors.New("invalid scheduled action")
+
+// ErrActionNotPending is returned when cancelling an action that has already
+// run or been cancelled
+var ErrActionNotPending = errors.New("scheduled action is not pending")
+
+// ScheduledAction is a one-off action queued by a parent for a later time
+type ScheduledAction struct {
+	ID         int64
+	UserID     int64
+	Username   string
+	Action     string
+	Minutes    int
+	Message    string
+	RunAt      time.Time
+	Status     string
+	Error      string
+	CreatedBy  string
+	CreatedAt  time.Time
+	ExecutedAt *time.Time
+}
+
+// ValidateScheduledAction checks the action type and that extend and pause
+// have minutes and message has text
+func ValidateScheduledAction(action string, minutes int, message string) error {
+	switch action {
+	case ScheduledLock, ScheduledTerminate:
+	case ScheduledExtend:
+		if minutes <= 0 {
+			return fmt.Errorf("%w: extend needs positive minutes", ErrInvalidScheduledAction)
+		}
+	case ScheduledPause:
+		if minutes < 0 {
+			return fmt.Errorf("%w: pause minutes cannot be negative", ErrInvalidScheduledAction)
+		}
+	case ScheduledMessage:
+		if message == "" {
+			return fmt.Errorf("%w: message needs text", ErrInvalidScheduledAction)
+		}
+	default:
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidScheduledAction, action)
+	}
+	return nil
+}
+
+// ScheduleAction queues an action for a user at runAt
+func (s *Storage) ScheduleAction(userID int64, action string, runAt time.Time, minutes int, message, createdBy string) (*ScheduledAction, error) {
+	if err := ValidateScheduledAction(action, minutes, message); err != nil {
+		return nil, err
+	}
+
+	result, err := s.db.Exec(
+		`INSERT INTO scheduled_actions (user_id, action, minutes, message, run_at, status, created_by)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		userID, action, minutes, message, dbTime(runAt), ScheduledPending, createdBy,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule action: %w", err)
+	}
+
+	id, _ := result.LastInsertId()
+	return s.GetScheduledAction(id)
+}
+
+const scheduledActionSelect = `SELECT a.id, a.user_id, u.username, a.action, a.minutes, a.message,
+	a.run_at, a.status, a.error, a.created_by, a.created_at, a.executed_at
+	FROM scheduled_actions a JOIN users u ON u.id = a.user_id`
+
+func scanScheduledActions(rows *sql.Rows) ([]*ScheduledAction, error) {
+	var actions []*ScheduledAction
+	for rows.Next() {
+		a := &ScheduledAction{}
+		var executedAt sql.NullTime
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Username, &a.Action, &a.Minutes, &a.Message,
+			&a.RunAt, &a.Status, &a.Error, &a.CreatedBy, &a.CreatedAt, &executedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan scheduled action: %w", err)
+		}
+		if executedAt.Valid {
+			a.ExecutedAt = &executedAt.Time
+		}
+		actions = append(actions, a)
+	}
+
+	return actions, rows.Err()
+}
+
+// GetScheduledAction retrieves a scheduled action by ID
+func (s *Storage) GetScheduledAction(id int64) (*ScheduledAction, error) {
+	rows, err := s.db.Query(scheduledActionSelect+` WHERE a.id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled action: %w", err)
+	}
+	defer rows.Close()
+
+	actions, err := scanScheduledActions(rows)
+	if err != nil || len(actions) == 0 {
+		return nil, err
+	}
+	return actions[0], nil
+}
+
+// ListScheduledActions returns pending actions in the order they will run,
+// followed by those that ran or were cancelled since the given time, newest
+// first. A userID of 0 lists actions for everyone.
+func (s *Storage) ListScheduledActions(userID int64, since time.Time) ([]*ScheduledAction, error) {
+	rows, err := s.db.Query(
+		scheduledActionSelect+` WHERE (? = 0 OR a.user_id = ?)
+		 AND (a.status = ? OR a.run_at >= ?)
+		 ORDER BY a.status != ?, CASE WHEN a.status = ? THEN a.run_at END, a.run_at DESC, a.id`,
+		userID, userID, ScheduledPending, dbTime(since), ScheduledPending, ScheduledPending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled actions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanScheduledActions(rows)
+}
+
+// DueScheduledActions returns pending actions whose time has come
+func (s *Storage) DueScheduledActions(now time.Time) ([]*ScheduledAction, error) {
+	rows, err := s.db.Query(
+		scheduledActionSelect+` WHERE a.status = ? AND a.run_at <= ? ORDER BY a.run_at, a.id`,
+		ScheduledPending, dbTime(now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due actions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanScheduledActions(rows)
+}
+
+// ClaimScheduledAction marks a pending action as running. It reports false
+// if the action was cancelled or claimed in the meantime, in which case it
+// must not be executed.
+func (s *Storage) ClaimScheduledAction(id int64) (bool, error) {
+	result, err := s.db.Exec(
+		`UPDATE scheduled_actions SET status = ?, executed_at = ? WHERE id = ? AND status = ?`,
+		ScheduledRunning, dbTime(s.now()), id, ScheduledPending,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim scheduled action: %w", err)
+	}
+
+	n, _ := result.RowsAffected()
+	return n == 1, nil
+}
+
+// FinishScheduledAction records the outcome of a claimed action
+func (s *Storage) FinishScheduledAction(id int64, runErr error) error {
+	status, message := ScheduledDone, ""
+	if runErr != nil {
+		status, message = ScheduledFailed, runErr.Error()
+	}
+
+	_, err := s.db.Exec(
+		`UPDATE scheduled_actions SET status = ?, error = ? WHERE id = ? AND status = ?`,
+		status, message, id, ScheduledRunning,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to finish scheduled action: %w", err)
+	}
+	return nil
+}
+
+// CancelScheduledAction cancels a pending action
+func (s *Storage) CancelScheduledAction(id int64) error {
+	result, err := s.db.Exec(
+		`UPDATE scheduled_actions SET status = ? WHERE id = ? AND status = ?`,
+		ScheduledCancelled, id, ScheduledPending,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to cancel scheduled action: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrActionNotPending
+	}
+	return nil
+}
+
+// FailInterruptedActions marks actions left running by a previous process
+// as failed. They may or may not have taken effect, so they are not retried.
+func (s *Storage) FailInterruptedActions() (int, error) {
+	result, err := s.db.Exec(
+		`UPDATE scheduled_actions SET status = ?, error = ? WHERE status = ?`,
+		ScheduledFailed, "interrupted by a restart", ScheduledRunning,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark interrupted actions: %w", err)
+	}
+
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScheduledActions(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("testuser", 60)
+	now := time.Now()
+
+	if _, err := store.ScheduleAction(user.ID, "explode", now, 0, "", "parent"); !errors.Is(err, ErrInvalidScheduledAction) {
+		t.Errorf("Expected ErrInvalidScheduledAction for unknown action, got %v", err)
+	}
+	if _, err := store.ScheduleAction(user.ID, ScheduledExtend, now, 0, "", "parent"); !errors.Is(err, ErrInvalidScheduledAction) {
+		t.Errorf("Expected ErrInvalidScheduledAction for extend without minutes, got %v", err)
+	}
+
+	lock, err := store.ScheduleAction(user.ID, ScheduledLock, now.Add(time.Hour), 0, "", "parent")
+	if err != nil {
+		t.Fatalf("ScheduleAction failed: %v", err)
+	}
+	extend, _ := store.ScheduleAction(user.ID, ScheduledExtend, now.Add(-time.Minute), 30, "", "parent")
+	cancelled, _ := store.ScheduleAction(user.ID, ScheduledMessage, now.Add(-time.Minute), 0, "Dinner!", "parent")
+
+	if err := store.CancelScheduledAction(cancelled.ID); err != nil {
+		t.Fatalf("CancelScheduledAction failed: %v", err)
+	}
+	if err := store.CancelScheduledAction(cancelled.ID); err != ErrActionNotPending {
+		t.Errorf("Expected ErrActionNotPending, got %v", err)
+	}
+
+	due, _ := store.DueScheduledActions(now)
+	if len(due) != 1 || due[0].ID != extend.ID {
+		t.Fatalf("Expected only the extend action to be due, got %+v", due)
+	}
+
+	claimed, _ := store.ClaimScheduledAction(extend.ID)
+	if !claimed {
+		t.Fatal("Expected to claim the due action")
+	}
+	if claimed, _ := store.ClaimScheduledAction(extend.ID); claimed {
+		t.Error("Expected a second claim to fail")
+	}
+	if err := store.FinishScheduledAction(extend.ID, nil); err != nil {
+		t.Fatalf("FinishScheduledAction failed: %v", err)
+	}
+
+	got, _ := store.GetScheduledAction(extend.ID)
+	if got.Status != ScheduledDone || got.ExecutedAt == nil {
+		t.Errorf("Expected extend to be done, got %+v", got)
+	}
+
+	actions, _ := store.ListScheduledActions(user.ID, now.Add(-time.Hour))
+	if len(actions) != 3 || actions[0].ID != lock.ID {
+		t.Errorf("Expected pending lock listed first of 3, got %+v", actions)
+	}
+}
+
+func TestFailInterruptedActions(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("testuser", 60)
+	action, _ := store.ScheduleAction(user.ID, ScheduledTerminate, time.Now(), 0, "", "parent")
+
+	// Simulate a crash between claiming and finishing
+	store.ClaimScheduledAction(action.ID)
+
+	n, err := store.FailInterruptedActions()
+	if err != nil || n != 1 {
+		t.Fatalf("Expected 1 interrupted action, got %d (%v)", n, err)
+	}
+
+	got, _ := store.GetScheduledAction(action.ID)
+	if got.Status != ScheduledFailed {
+		t.Errorf("Expected interrupted action to fail, got %s", got.Status)
+	}
+	if due, _ := store.DueScheduledActions(time.Now().Add(time.Minute)); len(due) != 0 {
+		t.Errorf("Expected interrupted action not to run again, got %d due", len(due))
+	}
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// SettingHouseholdBudget holds the household's daily budget in minutes,
+// shared by all enabled users. 0 or unset means no budget.
+const SettingHouseholdBudget = "household_budget_mins"
+
+// HouseholdBudget is today's state of the shared daily budget
+type HouseholdBudget struct {
+	LimitMins        int
+	UsedSeconds      int
+	RemainingSeconds int
+	RemainingMins    int // rounded up
+}
+
+// UsedMins returns today's combined usage in whole minutes
+func (b *HouseholdBudget) UsedMins() int {
+	return b.UsedSeconds / 60
+}
+
+// PercentUsed returns how much of the budget is used, capped at 100
+func (b *HouseholdBudget) PercentUsed() int {
+	if b.LimitMins <= 0 {
+		return 0
+	}
+	percent := b.UsedMins() * 100 / b.LimitMins
+	if percent > 100 {
+		percent = 100
+	}
+	return percent
+}
+
+// GetHouseholdBudgetMins returns the configured daily budget, 0 if none
+func (s *Storage) GetHouseholdBudgetMins() (int, error) {
+	value, err := s.GetSetting(SettingHouseholdBudget)
+	if err != nil || value == "" {
+		return 0, err
+	}
+
+	mins, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid household budget %q: %w", value, err)
+	}
+	return mins, nil
+}
+
+// SetHouseholdBudgetMins sets the daily budget; 0 removes it
+func (s *Storage) SetHouseholdBudgetMins(mins int) error {
+	if mins < 0 {
+		return fmt.Errorf("household budget cannot be negative")
+	}
+	return s.SetSetting(SettingHouseholdBudget, strconv.Itoa(mins))
+}
+
+// GetHouseholdUsageSeconds returns the combined usage of all enabled users
+// on a date
+func (s *Storage) GetHouseholdUsageSeconds(date string) (int, error) {
+	var seconds int
+	err := s.db.QueryRow(
+		`SELECT COALESCE(SUM(l.used_seconds), 0) FROM usage_log l
+		 JOIN users u ON u.id = l.user_id
+		 WHERE u.enabled = 1 AND l.date = ?`,
+		date,
+	).Scan(&seconds)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get household usage: %w", err)
+	}
+	return seconds, nil
+}
+
+// GetHouseholdBudget returns today's budget state, or nil if no budget is set
+func (s *Storage) GetHouseholdBudget() (*HouseholdBudget, error) {
+	limit, err := s.GetHouseholdBudgetMins()
+	if err != nil || limit <= 0 {
+		return nil, err
+	}
+
+	used, err := s.GetHouseholdUsageSeconds(s.today())
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := limit*60 - used
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return &HouseholdBudget{
+		LimitMins:        limit,
+		UsedSeconds:      used,
+		RemainingSeconds: remaining,
+		RemainingMins:    MinutesRoundedUp(remaining),
+	}, nil
+}
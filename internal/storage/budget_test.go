@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestHouseholdBudget(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	alice, _ := store.CreateUser("alice", 180)
+	bob, _ := store.CreateUser("bob", 180)
+	carol, _ := store.CreateUser("carol", 180)
+
+	if budget, err := store.GetHouseholdBudget(); err != nil || budget != nil {
+		t.Fatalf("Expected no budget by default, got %+v (%v)", budget, err)
+	}
+
+	if err := store.SetHouseholdBudgetMins(300); err != nil {
+		t.Fatalf("SetHouseholdBudgetMins failed: %v", err)
+	}
+	if err := store.SetHouseholdBudgetMins(-1); err == nil {
+		t.Error("Expected error for negative budget")
+	}
+
+	store.AddUsageTime(alice.ID, 120*60)
+	store.AddUsageTime(bob.ID, 90*60)
+	store.AddUsageTime(carol.ID, 60*60)
+
+	// Disabled users are not children whose time counts
+	store.UpdateUser(carol.ID, 180, false)
+
+	budget, err := store.GetHouseholdBudget()
+	if err != nil {
+		t.Fatalf("GetHouseholdBudget failed: %v", err)
+	}
+	if budget.UsedMins() != 210 || budget.RemainingMins != 90 {
+		t.Errorf("Expected 210 used and 90 remaining, got %d and %d", budget.UsedMins(), budget.RemainingMins)
+	}
+	if budget.PercentUsed() != 70 {
+		t.Errorf("Expected 70%% used, got %d", budget.PercentUsed())
+	}
+
+	store.AddUsageTime(bob.ID, 120*60)
+	budget, _ = store.GetHouseholdBudget()
+	if budget.RemainingMins != 0 || budget.PercentUsed() != 100 {
+		t.Errorf("Expected budget used up, got %+v", budget)
+	}
+
+	store.SetHouseholdBudgetMins(0)
+	if budget, _ := store.GetHouseholdBudget(); budget != nil {
+		t.Errorf("Expected budget removed, got %+v", budget)
+	}
+}
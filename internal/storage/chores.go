@@ -0,0 +1,299 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Chore completion states
+const (
+	ChoreStatusPending  = "pending"
+	ChoreStatusApproved = "approved"
+	ChoreStatusRejected = "rejected"
+)
+
+// DefaultChoreDailyCap is how often a chore is rewarded per day unless set
+// otherwise, the same as the column default
+const DefaultChoreDailyCap = 1
+
+// ErrChoreCapReached is returned when a chore has already been rewarded
+// the maximum number of times for the day
+var ErrChoreCapReached = errors.New("daily cap for this chore has been reached")
+
+// ErrCompletionNotPending is returned when reviewing a completion that has
+// already been approved or rejected
+var ErrCompletionNotPending = errors.New("chore completion is not pending")
+
+// Chore represents a task a user can complete to earn extra screen time
+type Chore struct {
+	ID            int64
+	UserID        int64
+	Name          string
+	MinutesReward int
+	DailyCap      int
+	CreatedAt     time.Time
+}
+
+// ChoreCompletion represents a submitted chore and its review state
+type ChoreCompletion struct {
+	ID          int64
+	ChoreID     int64
+	UserID      int64
+	Username    string
+	ChoreName   string
+	Minutes     int
+	Date        string
+	Status      string
+	SubmittedAt time.Time
+	ReviewedAt  *time.Time
+}
+
+// CreateChore adds a chore to a user's catalogue. A daily cap of 0 means the
+// chore can be rewarded any number of times per day.
+func (s *Storage) CreateChore(userID int64, name string, minutesReward, dailyCap int) (*Chore, error) {
+	result, err := s.db.Exec(
+		`INSERT INTO chores (user_id, name, minutes_reward, daily_cap) VALUES (?, ?, ?, ?)`,
+		userID, name, minutesReward, dailyCap,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create chore: %w", err)
+	}
+
+	id, _ := result.LastInsertId()
+	return s.GetChore(id)
+}
+
+// GetChore retrieves a chore by ID
+func (s *Storage) GetChore(id int64) (*Chore, error) {
+	chore := &Chore{}
+	err := s.db.QueryRow(
+		`SELECT id, user_id, name, minutes_reward, daily_cap, created_at
+		 FROM chores WHERE id = ?`,
+		id,
+	).Scan(&chore.ID, &chore.UserID, &chore.Name, &chore.MinutesReward, &chore.DailyCap, &chore.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chore: %w", err)
+	}
+
+	return chore, nil
+}
+
+// ListChores returns the chore catalogue for a user
+func (s *Storage) ListChores(userID int64) ([]*Chore, error) {
+	rows, err := s.db.Query(
+		`SELECT id, user_id, name, minutes_reward, daily_cap, created_at
+		 FROM chores WHERE user_id = ? ORDER BY name`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chores: %w", err)
+	}
+	defer rows.Close()
+
+	var chores []*Chore
+	for rows.Next() {
+		chore := &Chore{}
+		if err := rows.Scan(&chore.ID, &chore.UserID, &chore.Name, &chore.MinutesReward, &chore.DailyCap, &chore.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan chore: %w", err)
+		}
+		chores = append(chores, chore)
+	}
+
+	return chores, nil
+}
+
+// DeleteChore removes a chore and its completion history
+func (s *Storage) DeleteChore(id int64) error {
+	_, err := s.db.Exec(`DELETE FROM chores WHERE id = ?`, id)
+	return err
+}
+
+// SubmitChoreCompletion records that a chore was done today and queues it
+// for parent approval
+func (s *Storage) SubmitChoreCompletion(choreID int64) (*ChoreCompletion, error) {
+	chore, err := s.GetChore(choreID)
+	if err != nil {
+		return nil, err
+	}
+	if chore == nil {
+		return nil, fmt.Errorf("chore %d not found", choreID)
+	}
+
+	today := s.today()
+
+	if chore.DailyCap > 0 {
+		var count int
+		err := s.db.QueryRow(
+			`SELECT COUNT(*) FROM chore_completions
+			 WHERE chore_id = ? AND date = ? AND status IN (?, ?)`,
+			choreID, today, ChoreStatusPending, ChoreStatusApproved,
+		).Scan(&count)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count chore completions: %w", err)
+		}
+		if count >= chore.DailyCap {
+			return nil, ErrChoreCapReached
+		}
+	}
+
+	result, err := s.db.Exec(
+		`INSERT INTO chore_completions (chore_id, user_id, date, status) VALUES (?, ?, ?, ?)`,
+		choreID, chore.UserID, today, ChoreStatusPending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit chore completion: %w", err)
+	}
+
+	id, _ := result.LastInsertId()
+	return s.GetChoreCompletion(id)
+}
+
+const choreCompletionColumns = `c.id, c.chore_id, c.user_id, u.username, ch.name, ch.minutes_reward,
+	c.date, c.status, c.submitted_at, c.reviewed_at`
+
+const choreCompletionJoins = `FROM chore_completions c
+	JOIN chores ch ON ch.id = c.chore_id
+	JOIN users u ON u.id = c.user_id`
+
+func scanChoreCompletion(row interface{ Scan(...any) error }) (*ChoreCompletion, error) {
+	cc := &ChoreCompletion{}
+	var reviewedAt sql.NullTime
+	if err := row.Scan(&cc.ID, &cc.ChoreID, &cc.UserID, &cc.Username, &cc.ChoreName, &cc.Minutes,
+		&cc.Date, &cc.Status, &cc.SubmittedAt, &reviewedAt); err != nil {
+		return nil, err
+	}
+	if reviewedAt.Valid {
+		cc.ReviewedAt = &reviewedAt.Time
+	}
+	return cc, nil
+}
+
+// GetChoreCompletion retrieves a chore completion by ID
+func (s *Storage) GetChoreCompletion(id int64) (*ChoreCompletion, error) {
+	cc, err := scanChoreCompletion(s.db.QueryRow(
+		`SELECT `+choreCompletionColumns+` `+choreCompletionJoins+` WHERE c.id = ?`,
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chore completion: %w", err)
+	}
+
+	return cc, nil
+}
+
+// ListPendingChoreCompletions returns all completions awaiting parent review
+func (s *Storage) ListPendingChoreCompletions() ([]*ChoreCompletion, error) {
+	rows, err := s.db.Query(
+		`SELECT `+choreCompletionColumns+` `+choreCompletionJoins+`
+		 WHERE c.status = ? ORDER BY c.submitted_at`,
+		ChoreStatusPending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chore completions: %w", err)
+	}
+	defer rows.Close()
+
+	var completions []*ChoreCompletion
+	for rows.Next() {
+		cc, err := scanChoreCompletion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan chore completion: %w", err)
+		}
+		completions = append(completions, cc)
+	}
+
+	return completions, nil
+}
+
+// ApproveChoreCompletion approves a pending completion and grants the chore's
+// reward as a time extension for today. A chore approved the morning after
+// it was done still earns time the child can use.
+func (s *Storage) ApproveChoreCompletion(id int64) (*ChoreCompletion, error) {
+	cc, err := s.GetChoreCompletion(id)
+	if err != nil {
+		return nil, err
+	}
+	if cc == nil {
+		return nil, fmt.Errorf("chore completion %d not found", id)
+	}
+	if cc.Status != ChoreStatusPending {
+		return nil, ErrCompletionNotPending
+	}
+
+	chore, err := s.GetChore(cc.ChoreID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if chore.DailyCap > 0 {
+		var approved int
+		err := tx.QueryRow(
+			`SELECT COUNT(*) FROM chore_completions WHERE chore_id = ? AND date = ? AND status = ?`,
+			cc.ChoreID, cc.Date, ChoreStatusApproved,
+		).Scan(&approved)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count chore completions: %w", err)
+		}
+		if approved >= chore.DailyCap {
+			return nil, ErrChoreCapReached
+		}
+	}
+
+	result, err := tx.Exec(
+		`UPDATE chore_completions SET status = ?, reviewed_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status = ?`,
+		ChoreStatusApproved, id, ChoreStatusPending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to approve chore completion: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrCompletionNotPending
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO time_extensions (user_id, date, minutes, granted_by, reason) VALUES (?, ?, ?, ?, ?)`,
+		cc.UserID, s.today(), cc.Minutes, "chore:"+cc.ChoreName,
+		fmt.Sprintf("%s, done on %s", cc.ChoreName, cc.Date),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to grant chore reward: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit chore approval: %w", err)
+	}
+
+	return s.GetChoreCompletion(id)
+}
+
+// RejectChoreCompletion rejects a pending completion without granting time
+func (s *Storage) RejectChoreCompletion(id int64) error {
+	result, err := s.db.Exec(
+		`UPDATE chore_completions SET status = ?, reviewed_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status = ?`,
+		ChoreStatusRejected, id, ChoreStatusPending,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reject chore completion: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrCompletionNotPending
+	}
+	return nil
+}
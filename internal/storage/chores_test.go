@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/clock"
+)
+
+func TestChoreApprovalGrantsExtension(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("testuser", 60)
+
+	chore, err := store.CreateChore(user.ID, "Dishes", 15, 1)
+	if err != nil {
+		t.Fatalf("Failed to create chore: %v", err)
+	}
+
+	chores, _ := store.ListChores(user.ID)
+	if len(chores) != 1 || chores[0].Name != "Dishes" {
+		t.Fatalf("Expected chore catalogue with Dishes, got %v", chores)
+	}
+
+	completion, err := store.SubmitChoreCompletion(chore.ID)
+	if err != nil {
+		t.Fatalf("Failed to submit chore: %v", err)
+	}
+
+	if completion.Status != ChoreStatusPending {
+		t.Errorf("Expected pending completion, got %s", completion.Status)
+	}
+
+	// Submitted but not yet approved: no extra time
+	remaining, _ := store.GetRemainingMinutes(user.ID)
+	if remaining != 60 {
+		t.Errorf("Expected 60 minutes remaining before approval, got %d", remaining)
+	}
+
+	pending, _ := store.ListPendingChoreCompletions()
+	if len(pending) != 1 {
+		t.Fatalf("Expected 1 pending completion, got %d", len(pending))
+	}
+
+	approved, err := store.ApproveChoreCompletion(completion.ID)
+	if err != nil {
+		t.Fatalf("Failed to approve chore: %v", err)
+	}
+
+	if approved.Status != ChoreStatusApproved || approved.ReviewedAt == nil {
+		t.Errorf("Expected approved completion with review time, got %+v", approved)
+	}
+
+	remaining, _ = store.GetRemainingMinutes(user.ID)
+	if remaining != 75 {
+		t.Errorf("Expected 75 minutes remaining after approval, got %d", remaining)
+	}
+
+	var grantedBy string
+	store.db.QueryRow(`SELECT granted_by FROM time_extensions WHERE user_id = ?`, user.ID).Scan(&grantedBy)
+	if grantedBy != "chore:Dishes" {
+		t.Errorf("Expected granted_by chore:Dishes, got %s", grantedBy)
+	}
+
+	if _, err := store.ApproveChoreCompletion(completion.ID); err != ErrCompletionNotPending {
+		t.Errorf("Expected ErrCompletionNotPending on double approval, got %v", err)
+	}
+}
+
+func TestChoreDailyCap(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("testuser", 60)
+	chore, _ := store.CreateChore(user.ID, "Tidy room", 10, 1)
+
+	first, err := store.SubmitChoreCompletion(chore.ID)
+	if err != nil {
+		t.Fatalf("Failed to submit chore: %v", err)
+	}
+
+	if _, err := store.SubmitChoreCompletion(chore.ID); err != ErrChoreCapReached {
+		t.Errorf("Expected ErrChoreCapReached, got %v", err)
+	}
+
+	// A rejected completion frees up the slot again
+	if err := store.RejectChoreCompletion(first.ID); err != nil {
+		t.Fatalf("Failed to reject chore: %v", err)
+	}
+
+	if _, err := store.SubmitChoreCompletion(chore.ID); err != nil {
+		t.Errorf("Expected resubmission after rejection to succeed, got %v", err)
+	}
+
+	remaining, _ := store.GetRemainingMinutes(user.ID)
+	if remaining != 60 {
+		t.Errorf("Expected rejected chore not to grant time, got %d remaining", remaining)
+	}
+}
+
+func TestChoreApprovedNextDay(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	c := clock.NewFake(time.Date(2026, 10, 16, 19, 30, 0, 0, time.Local))
+	store.SetClock(c)
+
+	user, _ := store.CreateUser("testuser", 60)
+	chore, _ := store.CreateChore(user.ID, "Dishes", 15, 1)
+	completion, err := store.SubmitChoreCompletion(chore.ID)
+	if err != nil {
+		t.Fatalf("Failed to submit chore: %v", err)
+	}
+
+	// The parents only get round to it the next morning
+	c.Set(time.Date(2026, 10, 17, 8, 0, 0, 0, time.Local))
+	if _, err := store.ApproveChoreCompletion(completion.ID); err != nil {
+		t.Fatalf("Failed to approve chore: %v", err)
+	}
+
+	if remaining, _ := store.GetRemainingMinutes(user.ID); remaining != 75 {
+		t.Errorf("Expected the reward to count on the day of approval, got %d minutes remaining", remaining)
+	}
+
+	extensions, _ := store.ListTodayExtensions(user.ID)
+	if len(extensions) != 1 || extensions[0].Reason != "Dishes, done on 2026-10-16" {
+		t.Errorf("Expected today's extension to name the chore, got %+v", extensions)
+	}
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrCorrectionNote is returned when a usage correction has no note
+var ErrCorrectionNote = errors.New("a note explaining the correction is required")
+
+// UsageCorrection records a manual change to a day's used seconds, so the
+// value the daemon measured is never lost
+type UsageCorrection struct {
+	ID          int64
+	UserID      int64
+	Date        string
+	OldSeconds  int
+	NewSeconds  int
+	Note        string
+	CorrectedBy string
+	CreatedAt   time.Time
+}
+
+// SetUsageSeconds replaces the used seconds recorded for a user on date
+func (s *Storage) SetUsageSeconds(userID int64, date string, seconds int, note, correctedBy string) (*UsageCorrection, error) {
+	return s.correctUsage(userID, date, note, correctedBy, func(int) int { return seconds })
+}
+
+// AdjustUsageSeconds adds delta (which may be negative) to the used seconds
+// recorded for a user on date. Usage never drops below zero.
+func (s *Storage) AdjustUsageSeconds(userID int64, date string, delta int, note, correctedBy string) (*UsageCorrection, error) {
+	return s.correctUsage(userID, date, note, correctedBy, func(old int) int { return old + delta })
+}
+
+func (s *Storage) correctUsage(userID int64, date, note, correctedBy string, update func(old int) int) (*UsageCorrection, error) {
+	if note == "" {
+		return nil, ErrCorrectionNote
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var old int
+	err = tx.QueryRow(
+		`SELECT used_seconds FROM usage_log WHERE user_id = ? AND date = ?`,
+		userID, date,
+	).Scan(&old)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to read usage: %w", err)
+	}
+
+	seconds := update(old)
+	if seconds < 0 {
+		seconds = 0
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO usage_log (user_id, date, used_seconds)
+		 VALUES (?, ?, ?)
+		 ON CONFLICT(user_id, date) DO UPDATE SET
+		 used_seconds = excluded.used_seconds,
+		 updated_at = CURRENT_TIMESTAMP`,
+		userID, date, seconds,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update usage: %w", err)
+	}
+
+	result, err := tx.Exec(
+		`INSERT INTO usage_corrections (user_id, date, old_seconds, new_seconds, note, corrected_by)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		userID, date, old, seconds, note, correctedBy,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record correction: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit correction: %w", err)
+	}
+
+	id, _ := result.LastInsertId()
+	return &UsageCorrection{
+		ID:          id,
+		UserID:      userID,
+		Date:        date,
+		OldSeconds:  old,
+		NewSeconds:  seconds,
+		Note:        note,
+		CorrectedBy: correctedBy,
+		CreatedAt:   s.now(),
+	}, nil
+}
+
+// ListUsageCorrections returns a user's corrections since the given date,
+// newest first
+func (s *Storage) ListUsageCorrections(userID int64, fromDate string) ([]*UsageCorrection, error) {
+	rows, err := s.db.Query(
+		`SELECT id, user_id, date, old_seconds, new_seconds, note, corrected_by, created_at
+		 FROM usage_corrections WHERE user_id = ? AND date >= ?
+		 ORDER BY id DESC`,
+		userID, fromDate,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list usage corrections: %w", err)
+	}
+	defer rows.Close()
+
+	var corrections []*UsageCorrection
+	for rows.Next() {
+		c := &UsageCorrection{}
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Date, &c.OldSeconds, &c.NewSeconds,
+			&c.Note, &c.CorrectedBy, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan usage correction: %w", err)
+		}
+		corrections = append(corrections, c)
+	}
+
+	return corrections, rows.Err()
+}
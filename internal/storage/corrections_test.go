@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUsageCorrections(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("testuser", 120)
+	today := time.Now().Format("2006-01-02")
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+
+	store.AddUsageTime(user.ID, 3600)
+
+	if _, err := store.SetUsageSeconds(user.ID, today, 0, "", "parent"); err != ErrCorrectionNote {
+		t.Errorf("Expected ErrCorrectionNote, got %v", err)
+	}
+
+	c, err := store.AdjustUsageSeconds(user.ID, today, -1800, "film night", "parent")
+	if err != nil {
+		t.Fatalf("AdjustUsageSeconds failed: %v", err)
+	}
+	if c.OldSeconds != 3600 || c.NewSeconds != 1800 {
+		t.Errorf("Expected 3600 -> 1800, got %d -> %d", c.OldSeconds, c.NewSeconds)
+	}
+
+	if _, err := store.AdjustUsageSeconds(user.ID, today, -7200, "more film", "parent"); err != nil {
+		t.Fatalf("AdjustUsageSeconds failed: %v", err)
+	}
+	if used, _ := store.GetTodayUsageSeconds(user.ID); used != 0 {
+		t.Errorf("Expected usage clamped to 0, got %d", used)
+	}
+
+	// Days without usage can be corrected too
+	if _, err := store.SetUsageSeconds(user.ID, yesterday, 2700, "forgot to log out", "parent"); err != nil {
+		t.Fatalf("SetUsageSeconds failed: %v", err)
+	}
+	history, _ := store.GetUsageHistory(user.ID, 2)
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 usage records, got %d", len(history))
+	}
+
+	corrections, _ := store.ListUsageCorrections(user.ID, yesterday)
+	if len(corrections) != 3 || corrections[0].Date != yesterday {
+		t.Fatalf("Expected 3 corrections newest first, got %+v", corrections)
+	}
+	if corrections[2].OldSeconds != 3600 {
+		t.Errorf("Expected the measured 3600 seconds to be kept, got %d", corrections[2].OldSeconds)
+	}
+}
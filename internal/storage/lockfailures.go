@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLockFailureAcknowledged is returned when acknowledging a lock failure
+// that is unknown or was already acknowledged
+var ErrLockFailureAcknowledged = errors.New("lock failure not found or already acknowledged")
+
+// LockFailure records a lock that could not be confirmed. It stays on the
+// dashboard until a parent acknowledges it, so it is seen even when no
+// alert reaches the parents.
+type LockFailure struct {
+	ID             int64
+	UserID         int64
+	Username       string
+	Message        string
+	CreatedAt      time.Time
+	AcknowledgedAt *time.Time
+}
+
+// RecordLockFailure records that a user's sessions could not be locked
+func (s *Storage) RecordLockFailure(userID int64, message string) (*LockFailure, error) {
+	result, err := s.db.Exec(
+		`INSERT INTO lock_failures (user_id, message, created_at) VALUES (?, ?, ?)`,
+		userID, message, dbTime(s.now()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record lock failure: %w", err)
+	}
+
+	id, _ := result.LastInsertId()
+	return &LockFailure{ID: id, UserID: userID, Message: message, CreatedAt: dbTime(s.now())}, nil
+}
+
+// ListLockFailures returns the lock failures no parent has acknowledged
+// yet, newest first
+func (s *Storage) ListLockFailures() ([]*LockFailure, error) {
+	rows, err := s.db.Query(
+		`SELECT f.id, f.user_id, u.username, f.message, f.created_at
+		 FROM lock_failures f JOIN users u ON u.id = f.user_id
+		 WHERE f.acknowledged_at IS NULL
+		 ORDER BY f.created_at DESC, f.id DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lock failures: %w", err)
+	}
+	defer rows.Close()
+
+	var failures []*LockFailure
+	for rows.Next() {
+		f := &LockFailure{}
+		if err := rows.Scan(&f.ID, &f.UserID, &f.Username, &f.Message, &f.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan lock failure: %w", err)
+		}
+		failures = append(failures, f)
+	}
+
+	return failures, rows.Err()
+}
+
+// AcknowledgeLockFailure removes a lock failure from the dashboard
+func (s *Storage) AcknowledgeLockFailure(id int64) error {
+	result, err := s.db.Exec(
+		`UPDATE lock_failures SET acknowledged_at = ? WHERE id = ? AND acknowledged_at IS NULL`,
+		dbTime(s.now()), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to acknowledge lock failure: %w", err)
+	}
+
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrLockFailureAcknowledged
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLockFailures(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("kid", 60)
+
+	first, err := store.RecordLockFailure(user.ID, "could not be locked")
+	if err != nil {
+		t.Fatalf("RecordLockFailure failed: %v", err)
+	}
+	store.RecordLockFailure(user.ID, "could not be locked again")
+
+	failures, err := store.ListLockFailures()
+	if err != nil {
+		t.Fatalf("ListLockFailures failed: %v", err)
+	}
+	if len(failures) != 2 || failures[0].Message != "could not be locked again" || failures[0].Username != "kid" {
+		t.Fatalf("Expected both failures, newest first, got %+v", failures)
+	}
+
+	if err := store.AcknowledgeLockFailure(first.ID); err != nil {
+		t.Fatalf("AcknowledgeLockFailure failed: %v", err)
+	}
+	if err := store.AcknowledgeLockFailure(first.ID); err != ErrLockFailureAcknowledged {
+		t.Errorf("Expected ErrLockFailureAcknowledged on second acknowledgement, got %v", err)
+	}
+
+	failures, _ = store.ListLockFailures()
+	if len(failures) != 1 || failures[0].ID == first.ID {
+		t.Errorf("Expected only the unacknowledged failure, got %+v", failures)
+	}
+}
@@ -0,0 +1,313 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Override sources
+const (
+	OverrideSourceManual   = "manual"
+	OverrideSourceCalendar = "calendar"
+	OverrideSourceUpload   = "calendar-upload"
+)
+
+// UnlimitedMinutes is reported as the remaining time on unlimited days. It
+// is a full day, so the limit is never reached.
+const UnlimitedMinutes = 24 * 60
+
+// UnlimitedSeconds is UnlimitedMinutes in seconds
+const UnlimitedSeconds = UnlimitedMinutes * 60
+
+// MinutesRoundedUp converts remaining seconds to minutes for display, so
+// that a partial minute still shows as time left
+func MinutesRoundedUp(seconds int) int {
+	return (seconds + 59) / 60
+}
+
+// DateOverride replaces the regular daily limit on a date or date range,
+// either for a single user or for the whole household
+type DateOverride struct {
+	ID        int64
+	UserID    int64 // 0 for household-wide overrides
+	Username  string
+	StartDate string
+	EndDate   string
+	LimitMins int
+	Unlimited bool
+	Label     string
+	Source    string
+	CreatedAt time.Time
+}
+
+// Household reports whether the override applies to every user
+func (o *DateOverride) Household() bool {
+	return o.UserID == 0
+}
+
+// Policy is the set of rules that applies to a user on a given date
+type Policy struct {
+	Date             string
+	LimitMins        int
+	Unlimited        bool
+	Override         *DateOverride
+	Profile          *Profile
+	Windows          []Window // empty allows any time of day
+	EnforcementMode  string
+	WarningIntervals []int // empty uses the configured defaults
+}
+
+// Allowed reports whether t falls inside one of the policy's windows
+func (p *Policy) Allowed(t time.Time) bool {
+	if len(p.Windows) == 0 {
+		return true
+	}
+	for _, w := range p.Windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// MinutesUntilClose returns the minutes until the current window closes,
+// rounded up. It reports false when the policy has no windows, and 0
+// outside all windows.
+func (p *Policy) MinutesUntilClose(t time.Time) (int, bool) {
+	secs, ok := p.SecondsUntilClose(t)
+	return MinutesRoundedUp(secs), ok
+}
+
+// SecondsUntilClose returns the exact seconds until the current window
+// closes. It reports false when the policy has no windows, and 0 outside all
+// windows.
+func (p *Policy) SecondsUntilClose(t time.Time) (int, bool) {
+	if len(p.Windows) == 0 {
+		return 0, false
+	}
+
+	secs := t.Hour()*3600 + t.Minute()*60 + t.Second()
+	for _, w := range p.Windows {
+		if w.Contains(t) {
+			return w.End*60 - secs, true
+		}
+	}
+	return 0, true
+}
+
+// CreateDateOverride adds an override. A userID of 0 makes it household-wide.
+func (s *Storage) CreateDateOverride(o *DateOverride) (*DateOverride, error) {
+	if o.Source == "" {
+		o.Source = OverrideSourceManual
+	}
+
+	var userID interface{}
+	if o.UserID != 0 {
+		userID = o.UserID
+	}
+
+	result, err := s.db.Exec(
+		`INSERT INTO date_overrides (user_id, start_date, end_date, limit_mins, unlimited, label, source)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		userID, o.StartDate, o.EndDate, o.LimitMins, o.Unlimited, o.Label, o.Source,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create date override: %w", err)
+	}
+
+	id, _ := result.LastInsertId()
+	return s.GetDateOverride(id)
+}
+
+// ReplaceDateOverrides atomically swaps all overrides from the given source
+// for a new set, used when re-importing a calendar
+func (s *Storage) ReplaceDateOverrides(source string, overrides []*DateOverride) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM date_overrides WHERE source = ?`, source); err != nil {
+		return fmt.Errorf("failed to clear date overrides: %w", err)
+	}
+
+	for _, o := range overrides {
+		var userID interface{}
+		if o.UserID != 0 {
+			userID = o.UserID
+		}
+
+		_, err := tx.Exec(
+			`INSERT INTO date_overrides (user_id, start_date, end_date, limit_mins, unlimited, label, source)
+			 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			userID, o.StartDate, o.EndDate, o.LimitMins, o.Unlimited, o.Label, source,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert date override: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListDateOverridesBySource returns all overrides from a source, e.g. the
+// ranges imported from a holiday calendar
+func (s *Storage) ListDateOverridesBySource(source string) ([]*DateOverride, error) {
+	rows, err := s.db.Query(
+		dateOverrideSelect+` WHERE o.source = ? ORDER BY o.start_date, o.id`,
+		source,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list date overrides: %w", err)
+	}
+	defer rows.Close()
+
+	return scanDateOverrides(rows)
+}
+
+// DeleteDateOverride removes an override
+func (s *Storage) DeleteDateOverride(id int64) error {
+	_, err := s.db.Exec(`DELETE FROM date_overrides WHERE id = ?`, id)
+	return err
+}
+
+const dateOverrideSelect = `SELECT o.id, COALESCE(o.user_id, 0), COALESCE(u.username, ''), o.start_date, o.end_date,
+	o.limit_mins, o.unlimited, o.label, o.source, o.created_at
+	FROM date_overrides o LEFT JOIN users u ON u.id = o.user_id`
+
+func scanDateOverrides(rows *sql.Rows) ([]*DateOverride, error) {
+	var overrides []*DateOverride
+	for rows.Next() {
+		o := &DateOverride{}
+		if err := rows.Scan(&o.ID, &o.UserID, &o.Username, &o.StartDate, &o.EndDate,
+			&o.LimitMins, &o.Unlimited, &o.Label, &o.Source, &o.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan date override: %w", err)
+		}
+		overrides = append(overrides, o)
+	}
+
+	return overrides, rows.Err()
+}
+
+// GetDateOverride retrieves an override by ID
+func (s *Storage) GetDateOverride(id int64) (*DateOverride, error) {
+	rows, err := s.db.Query(dateOverrideSelect+` WHERE o.id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get date override: %w", err)
+	}
+	defer rows.Close()
+
+	overrides, err := scanDateOverrides(rows)
+	if err != nil || len(overrides) == 0 {
+		return nil, err
+	}
+	return overrides[0], nil
+}
+
+// ListDateOverrides returns all overrides that have not ended before fromDate
+func (s *Storage) ListDateOverrides(fromDate string) ([]*DateOverride, error) {
+	rows, err := s.db.Query(
+		dateOverrideSelect+` WHERE o.end_date >= ? ORDER BY o.start_date, o.id`,
+		fromDate,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list date overrides: %w", err)
+	}
+	defer rows.Close()
+
+	return scanDateOverrides(rows)
+}
+
+// ListUserDateOverrides returns the user's own and household-wide overrides
+// that have not ended before fromDate
+func (s *Storage) ListUserDateOverrides(userID int64, fromDate string) ([]*DateOverride, error) {
+	rows, err := s.db.Query(
+		dateOverrideSelect+` WHERE (o.user_id = ? OR o.user_id IS NULL) AND o.end_date >= ?
+		 ORDER BY o.start_date, o.id`,
+		userID, fromDate,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list date overrides: %w", err)
+	}
+	defer rows.Close()
+
+	return scanDateOverrides(rows)
+}
+
+// GetDateOverrideFor returns the override in effect for a user on a date, or
+// nil. A user's own override wins over a household-wide one, and a manual
+// override wins over an imported one; among equals the most recently created
+// wins.
+func (s *Storage) GetDateOverrideFor(userID int64, date string) (*DateOverride, error) {
+	rows, err := s.db.Query(
+		dateOverrideSelect+` WHERE (o.user_id = ? OR o.user_id IS NULL)
+		 AND o.start_date <= ? AND o.end_date >= ?
+		 ORDER BY o.user_id IS NULL, o.source != ?, o.id DESC LIMIT 1`,
+		userID, date, date, OverrideSourceManual,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get date override: %w", err)
+	}
+	defer rows.Close()
+
+	overrides, err := scanDateOverrides(rows)
+	if err != nil || len(overrides) == 0 {
+		return nil, err
+	}
+	return overrides[0], nil
+}
+
+// GetPolicy resolves the rules that apply to a user on a date (YYYY-MM-DD).
+// The user's active profile replaces their own daily limit, and date
+// overrides take priority over both.
+func (s *Storage) GetPolicy(userID int64, date string) (*Policy, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("user %d not found", userID)
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q: %w", date, err)
+	}
+
+	policy := &Policy{
+		Date:            date,
+		LimitMins:       user.DailyLimitMins,
+		EnforcementMode: EnforcementLock,
+	}
+
+	profile, err := s.profileFor(userID, date)
+	if err != nil {
+		return nil, err
+	}
+	if profile != nil {
+		policy.Profile = profile
+		policy.LimitMins = profile.LimitFor(day)
+		policy.Windows = profile.Windows
+		policy.EnforcementMode = profile.EnforcementMode
+		policy.WarningIntervals = profile.WarningIntervals
+	}
+
+	override, err := s.GetDateOverrideFor(userID, date)
+	if err != nil {
+		return nil, err
+	}
+	if override != nil {
+		policy.Override = override
+		policy.LimitMins = override.LimitMins
+		policy.Unlimited = override.Unlimited
+	}
+
+	return policy, nil
+}
+
+// GetTodayPolicy resolves the policy that applies to a user today
+func (s *Storage) GetTodayPolicy(userID int64) (*Policy, error) {
+	return s.GetPolicy(userID, s.today())
+}
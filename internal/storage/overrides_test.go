@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDateOverridePriority(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	alice, _ := store.CreateUser("alice", 120)
+	bob, _ := store.CreateUser("bob", 90)
+
+	// Christmas week for everyone
+	_, err = store.CreateDateOverride(&DateOverride{
+		StartDate: "2024-12-23",
+		EndDate:   "2024-12-31",
+		LimitMins: 240,
+		Label:     "Christmas week",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create household override: %v", err)
+	}
+
+	// Bob is grounded on the 27th
+	_, err = store.CreateDateOverride(&DateOverride{
+		UserID:    bob.ID,
+		StartDate: "2024-12-27",
+		EndDate:   "2024-12-27",
+		LimitMins: 0,
+		Label:     "Grounded",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create user override: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		userID    int64
+		date      string
+		wantLimit int
+		wantLabel string
+	}{
+		{"regular day", alice.ID, "2024-12-20", 120, ""},
+		{"household override", alice.ID, "2024-12-27", 240, "Christmas week"},
+		{"user override wins", bob.ID, "2024-12-27", 0, "Grounded"},
+		{"household outside user override", bob.ID, "2024-12-28", 240, "Christmas week"},
+		{"after range", bob.ID, "2025-01-01", 90, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := store.GetPolicy(tt.userID, tt.date)
+			if err != nil {
+				t.Fatalf("GetPolicy failed: %v", err)
+			}
+
+			if policy.LimitMins != tt.wantLimit {
+				t.Errorf("Expected limit %d, got %d", tt.wantLimit, policy.LimitMins)
+			}
+
+			label := ""
+			if policy.Override != nil {
+				label = policy.Override.Label
+			}
+			if label != tt.wantLabel {
+				t.Errorf("Expected override %q, got %q", tt.wantLabel, label)
+			}
+		})
+	}
+
+	overrides, _ := store.ListUserDateOverrides(alice.ID, "2024-12-01")
+	if len(overrides) != 1 || !overrides[0].Household() {
+		t.Errorf("Expected alice to see only the household override, got %v", overrides)
+	}
+}
+
+func TestUnlimitedOverride(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("testuser", 60)
+	store.AddUsageTime(user.ID, 3600)
+
+	remaining, _ := store.GetRemainingMinutes(user.ID)
+	if remaining != 0 {
+		t.Fatalf("Expected 0 minutes remaining, got %d", remaining)
+	}
+
+	today := time.Now().Format("2006-01-02")
+	override, _ := store.CreateDateOverride(&DateOverride{
+		UserID:    user.ID,
+		StartDate: today,
+		EndDate:   today,
+		Unlimited: true,
+		Label:     "Sick day",
+	})
+
+	policy, _ := store.GetTodayPolicy(user.ID)
+	if !policy.Unlimited {
+		t.Error("Expected today's policy to be unlimited")
+	}
+
+	remaining, _ = store.GetRemainingMinutes(user.ID)
+	if remaining != UnlimitedMinutes {
+		t.Errorf("Expected %d minutes remaining on unlimited day, got %d", UnlimitedMinutes, remaining)
+	}
+
+	store.DeleteDateOverride(override.ID)
+
+	remaining, _ = store.GetRemainingMinutes(user.ID)
+	if remaining != 0 {
+		t.Errorf("Expected regular limit after deleting override, got %d", remaining)
+	}
+}
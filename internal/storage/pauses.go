@@ -0,0 +1,206 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// PauseResumedAuto is recorded as ResumedBy when a pause runs out
+const PauseResumedAuto = "auto"
+
+// ErrAlreadyPaused is returned when pausing a user who is already paused
+var ErrAlreadyPaused = errors.New("usage counter is already paused")
+
+// ErrNotPaused is returned when resuming a user who is not paused
+var ErrNotPaused = errors.New("usage counter is not paused")
+
+// Pause stops a user's usage counter, e.g. during a video call with
+// grandparents or an online lesson
+type Pause struct {
+	ID        int64
+	UserID    int64
+	Username  string
+	Reason    string
+	PausedBy  string
+	StartedAt time.Time
+	EndsAt    *time.Time // nil pauses until resumed
+	ResumedAt *time.Time
+	ResumedBy string
+}
+
+// Active reports whether the pause is in effect at t
+func (p *Pause) Active(t time.Time) bool {
+	if p.ResumedAt != nil {
+		return false
+	}
+	return p.EndsAt == nil || p.EndsAt.After(t)
+}
+
+// PauseUser pauses a user's usage counter for d, or until resumed if d is 0
+func (s *Storage) PauseUser(userID int64, d time.Duration, reason, pausedBy string) (*Pause, error) {
+	now := s.now()
+
+	active, err := s.GetActivePause(userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		return nil, ErrAlreadyPaused
+	}
+
+	var endsAt interface{}
+	if d > 0 {
+		endsAt = dbTime(now.Add(d))
+	}
+
+	result, err := s.db.Exec(
+		`INSERT INTO pauses (user_id, reason, paused_by, started_at, ends_at) VALUES (?, ?, ?, ?, ?)`,
+		userID, reason, pausedBy, dbTime(now), endsAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pause user: %w", err)
+	}
+
+	id, _ := result.LastInsertId()
+	return s.GetPause(id)
+}
+
+// ResumeUser ends the user's active pause
+func (s *Storage) ResumeUser(userID int64, resumedBy string) (*Pause, error) {
+	now := s.now()
+
+	active, err := s.GetActivePause(userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if active == nil {
+		return nil, ErrNotPaused
+	}
+
+	_, err = s.db.Exec(
+		`UPDATE pauses SET resumed_at = ?, resumed_by = ? WHERE id = ?`,
+		dbTime(now), resumedBy, active.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resume user: %w", err)
+	}
+
+	return s.GetPause(active.ID)
+}
+
+const pauseSelect = `SELECT p.id, p.user_id, u.username, p.reason, p.paused_by, p.started_at,
+	p.ends_at, p.resumed_at, p.resumed_by
+	FROM pauses p JOIN users u ON u.id = p.user_id`
+
+func scanPauses(rows *sql.Rows) ([]*Pause, error) {
+	var pauses []*Pause
+	for rows.Next() {
+		p := &Pause{}
+		var endsAt, resumedAt sql.NullTime
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Username, &p.Reason, &p.PausedBy, &p.StartedAt,
+			&endsAt, &resumedAt, &p.ResumedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan pause: %w", err)
+		}
+		if endsAt.Valid {
+			p.EndsAt = &endsAt.Time
+		}
+		if resumedAt.Valid {
+			p.ResumedAt = &resumedAt.Time
+		}
+		pauses = append(pauses, p)
+	}
+
+	return pauses, rows.Err()
+}
+
+// GetPause retrieves a pause by ID
+func (s *Storage) GetPause(id int64) (*Pause, error) {
+	rows, err := s.db.Query(pauseSelect+` WHERE p.id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pause: %w", err)
+	}
+	defer rows.Close()
+
+	pauses, err := scanPauses(rows)
+	if err != nil || len(pauses) == 0 {
+		return nil, err
+	}
+	return pauses[0], nil
+}
+
+// GetActivePause returns the user's pause in effect at t, or nil
+func (s *Storage) GetActivePause(userID int64, t time.Time) (*Pause, error) {
+	rows, err := s.db.Query(
+		pauseSelect+` WHERE p.user_id = ? AND p.resumed_at IS NULL
+		 AND (p.ends_at IS NULL OR p.ends_at > ?)
+		 ORDER BY p.id DESC LIMIT 1`,
+		userID, dbTime(t),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active pause: %w", err)
+	}
+	defer rows.Close()
+
+	pauses, err := scanPauses(rows)
+	if err != nil || len(pauses) == 0 {
+		return nil, err
+	}
+	return pauses[0], nil
+}
+
+// ListPauses returns the user's most recent pauses, newest first
+func (s *Storage) ListPauses(userID int64, limit int) ([]*Pause, error) {
+	rows, err := s.db.Query(
+		pauseSelect+` WHERE p.user_id = ? ORDER BY p.id DESC LIMIT ?`,
+		userID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pauses: %w", err)
+	}
+	defer rows.Close()
+
+	return scanPauses(rows)
+}
+
+// ExpirePauses closes every pause that ran out before now and returns them.
+// Either all of them are closed or none are.
+func (s *Storage) ExpirePauses(now time.Time) ([]*Pause, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(
+		pauseSelect+` WHERE p.resumed_at IS NULL AND p.ends_at IS NOT NULL AND p.ends_at <= ?`,
+		dbTime(now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired pauses: %w", err)
+	}
+	expired, err := scanPauses(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range expired {
+		_, err := tx.Exec(
+			`UPDATE pauses SET resumed_at = ends_at, resumed_by = ? WHERE id = ?`,
+			PauseResumedAuto, p.ID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expire pause: %w", err)
+		}
+		p.ResumedAt = p.EndsAt
+		p.ResumedBy = PauseResumedAuto
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit expired pauses: %w", err)
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPauseAndResume(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("testuser", 60)
+
+	pause, err := store.PauseUser(user.ID, 0, "video call with grandma", "parent")
+	if err != nil {
+		t.Fatalf("PauseUser failed: %v", err)
+	}
+	if pause.EndsAt != nil || pause.Reason != "video call with grandma" {
+		t.Errorf("Unexpected pause: %+v", pause)
+	}
+
+	if _, err := store.PauseUser(user.ID, time.Hour, "", "parent"); err != ErrAlreadyPaused {
+		t.Errorf("Expected ErrAlreadyPaused, got %v", err)
+	}
+
+	active, _ := store.GetActivePause(user.ID, time.Now().Add(24*time.Hour))
+	if active == nil {
+		t.Fatal("Expected open-ended pause to stay active")
+	}
+
+	resumed, err := store.ResumeUser(user.ID, "mum")
+	if err != nil {
+		t.Fatalf("ResumeUser failed: %v", err)
+	}
+	if resumed.ResumedAt == nil || resumed.ResumedBy != "mum" {
+		t.Errorf("Expected pause to record who resumed it, got %+v", resumed)
+	}
+
+	if _, err := store.ResumeUser(user.ID, "mum"); err != ErrNotPaused {
+		t.Errorf("Expected ErrNotPaused, got %v", err)
+	}
+
+	pauses, _ := store.ListPauses(user.ID, 10)
+	if len(pauses) != 1 {
+		t.Errorf("Expected 1 pause in the log, got %d", len(pauses))
+	}
+}
+
+func TestExpirePauses(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("testuser", 60)
+
+	pause, err := store.PauseUser(user.ID, 30*time.Minute, "online lesson", "parent")
+	if err != nil {
+		t.Fatalf("PauseUser failed: %v", err)
+	}
+
+	expired, _ := store.ExpirePauses(time.Now())
+	if len(expired) != 0 {
+		t.Errorf("Expected no expired pauses yet, got %d", len(expired))
+	}
+
+	later := time.Now().Add(31 * time.Minute)
+	if active, _ := store.GetActivePause(user.ID, later); active != nil {
+		t.Error("Expected pause to have run out")
+	}
+
+	expired, err = store.ExpirePauses(later)
+	if err != nil {
+		t.Fatalf("ExpirePauses failed: %v", err)
+	}
+	if len(expired) != 1 || expired[0].ResumedBy != PauseResumedAuto {
+		t.Fatalf("Expected 1 auto-resumed pause, got %+v", expired)
+	}
+
+	got, _ := store.GetPause(pause.ID)
+	if got.ResumedAt == nil || !got.ResumedAt.Equal(*got.EndsAt) {
+		t.Errorf("Expected pause to end at its scheduled time, got %+v", got)
+	}
+
+	// A new pause is allowed once the previous one has ended
+	if _, err := store.PauseUser(user.ID, time.Hour, "", "parent"); err != nil {
+		t.Errorf("Expected to pause again, got %v", err)
+	}
+}
+
+func TestExpirePausesIsAtomic(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	alice, _ := store.CreateUser("alice", 60)
+	bob, _ := store.CreateUser("bob", 60)
+	first, _ := store.PauseUser(alice.ID, 30*time.Minute, "", "parent")
+	second, _ := store.PauseUser(bob.ID, 30*time.Minute, "", "parent")
+
+	// Closing the second pause fails, as if the disk filled up halfway
+	if _, err := store.db.Exec(`CREATE TRIGGER fail_expire BEFORE UPDATE ON pauses
+		WHEN NEW.id = ` + strconv.FormatInt(second.ID, 10) + `
+		BEGIN SELECT RAISE(ABORT, 'disk full'); END`); err != nil {
+		t.Fatalf("Failed to create trigger: %v", err)
+	}
+
+	later := time.Now().Add(31 * time.Minute)
+	if _, err := store.ExpirePauses(later); err == nil {
+		t.Fatal("Expected ExpirePauses to fail")
+	}
+
+	if got, _ := store.GetPause(first.ID); got.ResumedAt != nil {
+		t.Errorf("Expected the first pause to stay open when the second fails, got %+v", got)
+	}
+
+	store.db.Exec(`DROP TRIGGER fail_expire`)
+	expired, err := store.ExpirePauses(later)
+	if err != nil || len(expired) != 2 {
+		t.Errorf("Expected both pauses to expire on retry, got %d: %v", len(expired), err)
+	}
+}
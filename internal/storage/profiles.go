@@ -0,0 +1,387 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/clock"
+)
+
+// Enforcement modes decide what happens when a child runs out of time
+const (
+	EnforcementLock   = "lock"   // lock the screen
+	EnforcementLogout = "logout" // terminate the session
+	EnforcementWarn   = "warn"   // notify only
+)
+
+// ErrInvalidEnforcementMode is returned for unknown enforcement modes
+var ErrInvalidEnforcementMode = errors.New("enforcement mode must be lock, logout or warn")
+
+// ValidEnforcementMode reports whether mode is a known enforcement mode
+func ValidEnforcementMode(mode string) bool {
+	switch mode {
+	case EnforcementLock, EnforcementLogout, EnforcementWarn:
+		return true
+	}
+	return false
+}
+
+// Window is a time of day during which screen time is allowed, in minutes
+// since midnight. End is exclusive and may be 24:00.
+type Window struct {
+	Start int
+	End   int
+}
+
+// String formats the window as HH:MM-HH:MM
+func (w Window) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.Start/60, w.Start%60, w.End/60, w.End%60)
+}
+
+// Contains reports whether the time of day of t lies inside the window
+func (w Window) Contains(t time.Time) bool {
+	mins := t.Hour()*60 + t.Minute()
+	return mins >= w.Start && mins < w.End
+}
+
+// ParseWindows parses a comma separated list such as "07:00-08:00,15:00-20:00"
+func ParseWindows(value string) ([]Window, error) {
+	var windows []Window
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		from, to, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid window %q: expected HH:MM-HH:MM", part)
+		}
+
+		start, err := clock.Parse(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: %w", part, err)
+		}
+		end, err := clock.Parse(to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: %w", part, err)
+		}
+		if end <= start {
+			return nil, fmt.Errorf("invalid window %q: end must be after start", part)
+		}
+
+		windows = append(windows, Window{Start: start, End: end})
+	}
+
+	sort.Slice(windows, func(i, j int) bool { return windows[i].Start < windows[j].Start })
+	return windows, nil
+}
+
+// FormatWindows is the inverse of ParseWindows
+func FormatWindows(windows []Window) string {
+	parts := make([]string, len(windows))
+	for i, w := range windows {
+		parts[i] = w.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseIntervals parses a comma separated list of warning minutes
+func ParseIntervals(value string) ([]int, error) {
+	var intervals []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		mins, err := strconv.Atoi(part)
+		if err != nil || mins <= 0 {
+			return nil, fmt.Errorf("invalid warning interval %q", part)
+		}
+		intervals = append(intervals, mins)
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(intervals)))
+	return intervals, nil
+}
+
+// FormatIntervals is the inverse of ParseIntervals
+func FormatIntervals(intervals []int) string {
+	parts := make([]string, len(intervals))
+	for i, mins := range intervals {
+		parts[i] = strconv.Itoa(mins)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Profile is a named bundle of rules such as "school week" or "holidays"
+// that can be switched per child
+type Profile struct {
+	ID               int64
+	Name             string
+	WeekdayLimitMins int
+	WeekendLimitMins int
+	Windows          []Window // empty allows any time of day
+	EnforcementMode  string
+	WarningIntervals []int // empty uses the configured defaults
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// LimitFor returns the profile's daily limit for a date
+func (p *Profile) LimitFor(date time.Time) int {
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return p.WeekendLimitMins
+	}
+	return p.WeekdayLimitMins
+}
+
+// WindowsString formats the profile's windows for display and forms
+func (p *Profile) WindowsString() string {
+	return FormatWindows(p.Windows)
+}
+
+// IntervalsString formats the profile's warning intervals for display and forms
+func (p *Profile) IntervalsString() string {
+	return FormatIntervals(p.WarningIntervals)
+}
+
+// ProfileAssignment records which profile a child is on and when it reverts
+type ProfileAssignment struct {
+	UserID            int64
+	ProfileID         int64
+	ProfileName       string
+	UntilDate         string // profile applies before this date; empty for no end
+	RevertProfileID   int64  // 0 reverts to the user's own daily limit
+	RevertProfileName string
+	SetBy             string
+	SetAt             time.Time
+}
+
+// CreateProfile adds a named profile
+func (s *Storage) CreateProfile(p *Profile) (*Profile, error) {
+	if p.EnforcementMode == "" {
+		p.EnforcementMode = EnforcementLock
+	}
+	if !ValidEnforcementMode(p.EnforcementMode) {
+		return nil, ErrInvalidEnforcementMode
+	}
+
+	result, err := s.db.Exec(
+		`INSERT INTO profiles (name, weekday_limit_mins, weekend_limit_mins, windows, enforcement_mode, warning_intervals)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		p.Name, p.WeekdayLimitMins, p.WeekendLimitMins, FormatWindows(p.Windows),
+		p.EnforcementMode, FormatIntervals(p.WarningIntervals),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create profile: %w", err)
+	}
+
+	id, _ := result.LastInsertId()
+	return s.GetProfile(id)
+}
+
+// UpdateProfile saves changes to a profile
+func (s *Storage) UpdateProfile(p *Profile) error {
+	if !ValidEnforcementMode(p.EnforcementMode) {
+		return ErrInvalidEnforcementMode
+	}
+
+	_, err := s.db.Exec(
+		`UPDATE profiles SET name = ?, weekday_limit_mins = ?, weekend_limit_mins = ?, windows = ?,
+		 enforcement_mode = ?, warning_intervals = ?, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = ?`,
+		p.Name, p.WeekdayLimitMins, p.WeekendLimitMins, FormatWindows(p.Windows),
+		p.EnforcementMode, FormatIntervals(p.WarningIntervals), p.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update profile: %w", err)
+	}
+	return nil
+}
+
+// DeleteProfile removes a profile. Children on it fall back to their own
+// daily limit.
+func (s *Storage) DeleteProfile(id int64) error {
+	_, err := s.db.Exec(`DELETE FROM profiles WHERE id = ?`, id)
+	return err
+}
+
+const profileSelect = `SELECT id, name, weekday_limit_mins, weekend_limit_mins, windows,
+	enforcement_mode, warning_intervals, created_at, updated_at FROM profiles`
+
+func scanProfiles(rows *sql.Rows) ([]*Profile, error) {
+	var profiles []*Profile
+	for rows.Next() {
+		p := &Profile{}
+		var windows, intervals string
+		if err := rows.Scan(&p.ID, &p.Name, &p.WeekdayLimitMins, &p.WeekendLimitMins, &windows,
+			&p.EnforcementMode, &intervals, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan profile: %w", err)
+		}
+
+		var err error
+		if p.Windows, err = ParseWindows(windows); err != nil {
+			return nil, fmt.Errorf("profile %q: %w", p.Name, err)
+		}
+		if p.WarningIntervals, err = ParseIntervals(intervals); err != nil {
+			return nil, fmt.Errorf("profile %q: %w", p.Name, err)
+		}
+
+		profiles = append(profiles, p)
+	}
+
+	return profiles, rows.Err()
+}
+
+// GetProfile retrieves a profile by ID
+func (s *Storage) GetProfile(id int64) (*Profile, error) {
+	rows, err := s.db.Query(profileSelect+` WHERE id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get profile: %w", err)
+	}
+	defer rows.Close()
+
+	profiles, err := scanProfiles(rows)
+	if err != nil || len(profiles) == 0 {
+		return nil, err
+	}
+	return profiles[0], nil
+}
+
+// ListProfiles returns all profiles ordered by name
+func (s *Storage) ListProfiles() ([]*Profile, error) {
+	rows, err := s.db.Query(profileSelect + ` ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+	defer rows.Close()
+
+	return scanProfiles(rows)
+}
+
+// GetUserProfile returns the user's profile assignment, or nil if the user is
+// on their own daily limit
+func (s *Storage) GetUserProfile(userID int64) (*ProfileAssignment, error) {
+	a := &ProfileAssignment{}
+	err := s.db.QueryRow(
+		`SELECT up.user_id, up.profile_id, p.name, up.until_date, COALESCE(up.revert_profile_id, 0),
+		 COALESCE(rp.name, ''), up.set_by, up.set_at
+		 FROM user_profiles up
+		 JOIN profiles p ON p.id = up.profile_id
+		 LEFT JOIN profiles rp ON rp.id = up.revert_profile_id
+		 WHERE up.user_id = ?`,
+		userID,
+	).Scan(&a.UserID, &a.ProfileID, &a.ProfileName, &a.UntilDate, &a.RevertProfileID,
+		&a.RevertProfileName, &a.SetBy, &a.SetAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user profile: %w", err)
+	}
+
+	return a, nil
+}
+
+// SetUserProfile switches a user to a profile. With an untilDate the user
+// reverts to the profile that was active before on that date. A profileID of
+// 0 puts the user back on their own daily limit.
+func (s *Storage) SetUserProfile(userID, profileID int64, untilDate, setBy string) error {
+	if profileID == 0 {
+		_, err := s.db.Exec(`DELETE FROM user_profiles WHERE user_id = ?`, userID)
+		return err
+	}
+
+	var revertTo interface{}
+	if untilDate != "" {
+		current, err := s.GetUserProfile(userID)
+		if err != nil {
+			return err
+		}
+		if current != nil && current.ProfileID != profileID {
+			revertTo = current.ProfileID
+		}
+	}
+
+	_, err := s.db.Exec(
+		`INSERT INTO user_profiles (user_id, profile_id, until_date, revert_profile_id, set_by, set_at)
+		 VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+		 ON CONFLICT(user_id) DO UPDATE SET
+			profile_id = excluded.profile_id,
+			until_date = excluded.until_date,
+			revert_profile_id = excluded.revert_profile_id,
+			set_by = excluded.set_by,
+			set_at = excluded.set_at`,
+		userID, profileID, untilDate, revertTo, setBy,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set user profile: %w", err)
+	}
+	return nil
+}
+
+// RevertExpiredProfiles applies the revert of every assignment whose revert
+// date is on or before today and returns the assignments as they were
+func (s *Storage) RevertExpiredProfiles(today string) ([]*ProfileAssignment, error) {
+	rows, err := s.db.Query(
+		`SELECT user_id FROM user_profiles WHERE until_date != '' AND until_date <= ?`,
+		today,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired profiles: %w", err)
+	}
+
+	var userIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan expired profile: %w", err)
+		}
+		userIDs = append(userIDs, id)
+	}
+	rows.Close()
+
+	var reverted []*ProfileAssignment
+	for _, id := range userIDs {
+		a, err := s.GetUserProfile(id)
+		if err != nil || a == nil {
+			continue
+		}
+
+		if err := s.SetUserProfile(id, a.RevertProfileID, "", "revert"); err != nil {
+			return reverted, err
+		}
+		reverted = append(reverted, a)
+	}
+
+	return reverted, nil
+}
+
+// profileFor resolves the profile that applies to a user on a date, taking a
+// pending revert into account. It returns nil when the user's own daily limit
+// applies.
+func (s *Storage) profileFor(userID int64, date string) (*Profile, error) {
+	a, err := s.GetUserProfile(userID)
+	if err != nil || a == nil {
+		return nil, err
+	}
+
+	profileID := a.ProfileID
+	if a.UntilDate != "" && date >= a.UntilDate {
+		profileID = a.RevertProfileID
+	}
+	if profileID == 0 {
+		return nil, nil
+	}
+
+	return s.GetProfile(profileID)
+}
@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseWindows(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"15:00-20:00, 07:00-08:00", "07:00-08:00,15:00-20:00", false},
+		{"18:00-24:00", "18:00-24:00", false},
+		{"20:00-15:00", "", true},
+		{"7-8", "", true},
+		{"07:00-25:00", "", true},
+		{"07:60-08:00", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			windows, err := ParseWindows(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWindows(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got := FormatWindows(windows); !tt.wantErr && got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPolicyWindows(t *testing.T) {
+	windows, _ := ParseWindows("07:00-08:00,15:00-20:00")
+	policy := &Policy{Windows: windows}
+
+	at := func(hour, min int) time.Time {
+		return time.Date(2024, 12, 20, hour, min, 0, 0, time.Local)
+	}
+
+	if policy.Allowed(at(12, 0)) {
+		t.Error("Expected 12:00 to be outside the allowed windows")
+	}
+	if !policy.Allowed(at(15, 0)) {
+		t.Error("Expected 15:00 to be allowed")
+	}
+	if policy.Allowed(at(20, 0)) {
+		t.Error("Expected window end to be exclusive")
+	}
+
+	if mins, ok := policy.MinutesUntilClose(at(19, 15)); !ok || mins != 45 {
+		t.Errorf("Expected 45 minutes until close, got %d (%v)", mins, ok)
+	}
+	if mins, ok := policy.MinutesUntilClose(at(12, 0)); !ok || mins != 0 {
+		t.Errorf("Expected 0 minutes outside windows, got %d (%v)", mins, ok)
+	}
+	if _, ok := (&Policy{}).MinutesUntilClose(at(12, 0)); ok {
+		t.Error("Expected no window limit without windows")
+	}
+
+	closing := time.Date(2024, 12, 20, 19, 59, 20, 0, time.Local)
+	if secs, ok := policy.SecondsUntilClose(closing); !ok || secs != 40 {
+		t.Errorf("Expected 40 seconds until close, got %d (%v)", secs, ok)
+	}
+	if mins, _ := policy.MinutesUntilClose(closing); mins != 1 {
+		t.Errorf("Expected partial minute to round up, got %d", mins)
+	}
+}
+
+func TestProfileSwitchAndRevert(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("testuser", 60)
+
+	windows, _ := ParseWindows("15:00-20:00")
+	school, err := store.CreateProfile(&Profile{
+		Name:             "School week",
+		WeekdayLimitMins: 90,
+		WeekendLimitMins: 180,
+		Windows:          windows,
+		WarningIntervals: []int{1, 10},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create profile: %v", err)
+	}
+	if school.EnforcementMode != EnforcementLock {
+		t.Errorf("Expected default enforcement mode lock, got %q", school.EnforcementMode)
+	}
+	if school.IntervalsString() != "10,1" {
+		t.Errorf("Expected intervals sorted descending, got %q", school.IntervalsString())
+	}
+
+	sick, _ := store.CreateProfile(&Profile{
+		Name:             "Sick day",
+		WeekdayLimitMins: 300,
+		WeekendLimitMins: 300,
+		EnforcementMode:  EnforcementWarn,
+	})
+
+	if _, err := store.CreateProfile(&Profile{Name: "Bad", EnforcementMode: "shutdown"}); err != ErrInvalidEnforcementMode {
+		t.Errorf("Expected ErrInvalidEnforcementMode, got %v", err)
+	}
+
+	// Without a profile the user's own limit applies
+	policy, _ := store.GetPolicy(user.ID, "2024-12-20")
+	if policy.Profile != nil || policy.LimitMins != 60 {
+		t.Errorf("Expected own limit without profile, got %+v", policy)
+	}
+
+	store.SetUserProfile(user.ID, school.ID, "", "parent")
+
+	policy, _ = store.GetPolicy(user.ID, "2024-12-20") // Friday
+	if policy.LimitMins != 90 || len(policy.Windows) != 1 || len(policy.WarningIntervals) != 2 {
+		t.Errorf("Expected school weekday policy, got %+v", policy)
+	}
+	policy, _ = store.GetPolicy(user.ID, "2024-12-21") // Saturday
+	if policy.LimitMins != 180 {
+		t.Errorf("Expected weekend limit 180, got %d", policy.LimitMins)
+	}
+
+	// Date overrides still win over the profile's limit
+	store.CreateDateOverride(&DateOverride{StartDate: "2024-12-20", EndDate: "2024-12-20", LimitMins: 30})
+	policy, _ = store.GetPolicy(user.ID, "2024-12-20")
+	if policy.LimitMins != 30 || policy.Profile == nil {
+		t.Errorf("Expected override limit with profile rules, got %+v", policy)
+	}
+
+	// Sick until the 23rd, then back to school week
+	if err := store.SetUserProfile(user.ID, sick.ID, "2024-12-23", "parent"); err != nil {
+		t.Fatalf("Failed to switch profile: %v", err)
+	}
+
+	assignment, _ := store.GetUserProfile(user.ID)
+	if assignment.ProfileName != "Sick day" || assignment.RevertProfileName != "School week" {
+		t.Errorf("Unexpected assignment: %+v", assignment)
+	}
+
+	policy, _ = store.GetPolicy(user.ID, "2024-12-22")
+	if policy.Profile.Name != "Sick day" || policy.EnforcementMode != EnforcementWarn {
+		t.Errorf("Expected sick day before revert date, got %+v", policy.Profile)
+	}
+	policy, _ = store.GetPolicy(user.ID, "2024-12-23")
+	if policy.Profile.Name != "School week" {
+		t.Errorf("Expected school week from revert date, got %+v", policy.Profile)
+	}
+
+	reverted, err := store.RevertExpiredProfiles("2024-12-22")
+	if err != nil || len(reverted) != 0 {
+		t.Errorf("Expected nothing to revert yet, got %v, %v", reverted, err)
+	}
+
+	reverted, _ = store.RevertExpiredProfiles("2024-12-23")
+	if len(reverted) != 1 {
+		t.Fatalf("Expected 1 reverted assignment, got %d", len(reverted))
+	}
+
+	assignment, _ = store.GetUserProfile(user.ID)
+	if assignment.ProfileID != school.ID || assignment.UntilDate != "" {
+		t.Errorf("Expected school week without revert date, got %+v", assignment)
+	}
+
+	// Deleting the active profile returns the user to their own limit
+	store.DeleteProfile(school.ID)
+	assignment, _ = store.GetUserProfile(user.ID)
+	if assignment != nil {
+		t.Errorf("Expected no assignment after deleting profile, got %+v", assignment)
+	}
+}
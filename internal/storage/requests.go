@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Time request states
+const (
+	RequestStatusPending  = "pending"
+	RequestStatusApproved = "approved"
+	RequestStatusDenied   = "denied"
+	RequestStatusExpired  = "expired"
+)
+
+// ErrRequestAlreadyPending is returned when a user submits a new request
+// while an earlier one is still waiting for an answer
+var ErrRequestAlreadyPending = errors.New("a request is already waiting for an answer")
+
+// ErrRequestNotPending is returned when answering a request that has already
+// been decided or has expired
+var ErrRequestNotPending = errors.New("time request is not pending")
+
+// TimeRequest represents a child's request for extra screen time
+type TimeRequest struct {
+	ID             int64
+	UserID         int64
+	Username       string
+	Minutes        int
+	Reason         string
+	Status         string
+	GrantedMinutes int
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+	DecidedAt      *time.Time
+}
+
+// CreateTimeRequest queues a request for extra minutes that expires after
+// timeout if no parent answers it
+func (s *Storage) CreateTimeRequest(userID int64, minutes int, reason string, timeout time.Duration) (*TimeRequest, error) {
+	pending, err := s.ListPendingTimeRequests()
+	if err != nil {
+		return nil, err
+	}
+	for _, req := range pending {
+		if req.UserID == userID && req.ExpiresAt.After(s.now()) {
+			return nil, ErrRequestAlreadyPending
+		}
+	}
+
+	result, err := s.db.Exec(
+		`INSERT INTO time_requests (user_id, minutes, reason, status, expires_at) VALUES (?, ?, ?, ?, ?)`,
+		userID, minutes, reason, RequestStatusPending, dbTime(s.now().Add(timeout)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create time request: %w", err)
+	}
+
+	id, _ := result.LastInsertId()
+	return s.GetTimeRequest(id)
+}
+
+const timeRequestColumns = `r.id, r.user_id, u.username, r.minutes, r.reason, r.status,
+	r.granted_minutes, r.created_at, r.expires_at, r.decided_at`
+
+func scanTimeRequest(row interface{ Scan(...any) error }) (*TimeRequest, error) {
+	req := &TimeRequest{}
+	var decidedAt sql.NullTime
+	if err := row.Scan(&req.ID, &req.UserID, &req.Username, &req.Minutes, &req.Reason, &req.Status,
+		&req.GrantedMinutes, &req.CreatedAt, &req.ExpiresAt, &decidedAt); err != nil {
+		return nil, err
+	}
+	if decidedAt.Valid {
+		req.DecidedAt = &decidedAt.Time
+	}
+	return req, nil
+}
+
+// GetTimeRequest retrieves a time request by ID
+func (s *Storage) GetTimeRequest(id int64) (*TimeRequest, error) {
+	req, err := scanTimeRequest(s.db.QueryRow(
+		`SELECT `+timeRequestColumns+` FROM time_requests r
+		 JOIN users u ON u.id = r.user_id WHERE r.id = ?`,
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time request: %w", err)
+	}
+
+	return req, nil
+}
+
+// ListPendingTimeRequests returns all requests still waiting for an answer,
+// oldest first
+func (s *Storage) ListPendingTimeRequests() ([]*TimeRequest, error) {
+	rows, err := s.db.Query(
+		`SELECT `+timeRequestColumns+` FROM time_requests r
+		 JOIN users u ON u.id = r.user_id
+		 WHERE r.status = ? ORDER BY r.created_at, r.id`,
+		RequestStatusPending,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list time requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []*TimeRequest
+	for rows.Next() {
+		req, err := scanTimeRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan time request: %w", err)
+		}
+		requests = append(requests, req)
+	}
+
+	return requests, nil
+}
+
+// ApproveTimeRequest approves a pending request and grants the given number
+// of minutes as today's extension. Granting fewer minutes than requested is
+// a partial approval.
+func (s *Storage) ApproveTimeRequest(id int64, minutes int, grantedBy string) (*TimeRequest, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	var reason string
+	var expiresAt time.Time
+	err = tx.QueryRow(
+		`SELECT user_id, reason, expires_at FROM time_requests WHERE id = ? AND status = ?`,
+		id, RequestStatusPending,
+	).Scan(&userID, &reason, &expiresAt)
+	if err == sql.ErrNoRows || (err == nil && !expiresAt.After(s.now())) {
+		return nil, ErrRequestNotPending
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time request: %w", err)
+	}
+
+	_, err = tx.Exec(
+		`UPDATE time_requests SET status = ?, granted_minutes = ?, decided_at = CURRENT_TIMESTAMP
+		 WHERE id = ?`,
+		RequestStatusApproved, minutes, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to approve time request: %w", err)
+	}
+
+	if reason == "" {
+		reason = "Requested by child"
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO time_extensions (user_id, date, minutes, granted_by, reason) VALUES (?, ?, ?, ?, ?)`,
+		userID, s.today(), minutes, grantedBy, reason,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to grant requested time: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit time request approval: %w", err)
+	}
+
+	return s.GetTimeRequest(id)
+}
+
+// DenyTimeRequest denies a pending request
+func (s *Storage) DenyTimeRequest(id int64) (*TimeRequest, error) {
+	result, err := s.db.Exec(
+		`UPDATE time_requests SET status = ?, decided_at = CURRENT_TIMESTAMP
+		 WHERE id = ? AND status = ? AND expires_at > ?`,
+		RequestStatusDenied, id, RequestStatusPending, dbTime(s.now()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deny time request: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrRequestNotPending
+	}
+
+	return s.GetTimeRequest(id)
+}
+
+// ExpireTimeRequests marks pending requests whose timeout has passed as
+// expired and returns them so the children can be told
+func (s *Storage) ExpireTimeRequests(now time.Time) ([]*TimeRequest, error) {
+	pending, err := s.ListPendingTimeRequests()
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []*TimeRequest
+	for _, req := range pending {
+		if req.ExpiresAt.After(now) {
+			continue
+		}
+
+		result, err := s.db.Exec(
+			`UPDATE time_requests SET status = ?, decided_at = CURRENT_TIMESTAMP
+			 WHERE id = ? AND status = ?`,
+			RequestStatusExpired, req.ID, RequestStatusPending,
+		)
+		if err != nil {
+			return expired, fmt.Errorf("failed to expire time request: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+
+		req.Status = RequestStatusExpired
+		expired = append(expired, req)
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/florian/screentime-guardian/internal/clock"
+)
+
+func TestTimeRequestApproval(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("testuser", 60)
+
+	req, err := store.CreateTimeRequest(user.ID, 30, "finishing homework", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to create time request: %v", err)
+	}
+
+	if req.Status != RequestStatusPending || req.Username != "testuser" {
+		t.Errorf("Unexpected request: %+v", req)
+	}
+
+	if _, err := store.CreateTimeRequest(user.ID, 10, "again", 15*time.Minute); err != ErrRequestAlreadyPending {
+		t.Errorf("Expected ErrRequestAlreadyPending, got %v", err)
+	}
+
+	// Partial approval: only 20 of the 30 requested minutes
+	approved, err := store.ApproveTimeRequest(req.ID, 20, "parent")
+	if err != nil {
+		t.Fatalf("Failed to approve request: %v", err)
+	}
+
+	if approved.Status != RequestStatusApproved || approved.GrantedMinutes != 20 {
+		t.Errorf("Expected approved request with 20 minutes, got %+v", approved)
+	}
+
+	remaining, _ := store.GetRemainingMinutes(user.ID)
+	if remaining != 80 {
+		t.Errorf("Expected 80 minutes remaining, got %d", remaining)
+	}
+
+	if _, err := store.DenyTimeRequest(req.ID); err != ErrRequestNotPending {
+		t.Errorf("Expected ErrRequestNotPending when denying decided request, got %v", err)
+	}
+}
+
+func TestTimeRequestExpiry(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("testuser", 60)
+
+	req, _ := store.CreateTimeRequest(user.ID, 15, "", 10*time.Minute)
+
+	expired, err := store.ExpireTimeRequests(time.Now())
+	if err != nil {
+		t.Fatalf("Failed to expire requests: %v", err)
+	}
+	if len(expired) != 0 {
+		t.Errorf("Expected no expired requests yet, got %d", len(expired))
+	}
+
+	expired, _ = store.ExpireTimeRequests(time.Now().Add(11 * time.Minute))
+	if len(expired) != 1 || expired[0].ID != req.ID {
+		t.Fatalf("Expected request %d to expire, got %v", req.ID, expired)
+	}
+
+	if _, err := store.ApproveTimeRequest(req.ID, 15, "parent"); err != ErrRequestNotPending {
+		t.Errorf("Expected ErrRequestNotPending for expired request, got %v", err)
+	}
+
+	pending, _ := store.ListPendingTimeRequests()
+	if len(pending) != 0 {
+		t.Errorf("Expected no pending requests, got %d", len(pending))
+	}
+}
+
+func TestTimeRequestTimedOutBeforeSweep(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	c := clock.NewFake(time.Date(2026, 10, 16, 19, 30, 0, 0, time.Local))
+	store.SetClock(c)
+
+	user, _ := store.CreateUser("testuser", 60)
+
+	req, _ := store.CreateTimeRequest(user.ID, 15, "", 10*time.Minute)
+	if want := c.Now().Add(10 * time.Minute); !req.ExpiresAt.Equal(want) {
+		t.Errorf("Expected request to expire at %v, got %v", want, req.ExpiresAt)
+	}
+
+	// The timeout has passed but ExpireTimeRequests hasn't run yet
+	c.Advance(11 * time.Minute)
+
+	if _, err := store.ApproveTimeRequest(req.ID, 15, "parent"); err != ErrRequestNotPending {
+		t.Errorf("Expected ErrRequestNotPending approving a timed-out request, got %v", err)
+	}
+	if _, err := store.DenyTimeRequest(req.ID); err != ErrRequestNotPending {
+		t.Errorf("Expected ErrRequestNotPending denying a timed-out request, got %v", err)
+	}
+
+	expired, _ := store.ExpireTimeRequests(c.Now())
+	if len(expired) != 1 || expired[0].ID != req.ID {
+		t.Errorf("Expected request %d to still expire, got %v", req.ID, expired)
+	}
+}
@@ -2,17 +2,21 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 
 	_ "modernc.org/sqlite"
+
+	"github.com/florian/screentime-guardian/internal/clock"
 )
 
 // Storage handles all database operations
 type Storage struct {
-	db *sql.DB
+	db    *sql.DB
+	clock clock.Clock // decides what "today" is
 }
 
 // User represents a child user account
@@ -21,6 +25,7 @@ type User struct {
 	Username       string
 	DailyLimitMins int
 	Enabled        bool
+	DegradedMode   string // what happens while enforcement is degraded
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
 }
@@ -35,14 +40,44 @@ type UsageRecord struct {
 	UpdatedAt   time.Time
 }
 
-// TimeExtension represents a time extension granted to a user
+// TimeExtension represents a time extension granted to a user. Deductions
+// are stored as extensions with negative minutes.
 type TimeExtension struct {
-	ID        int64
-	UserID    int64
-	Date      string
-	Minutes   int
-	GrantedBy string
-	CreatedAt time.Time
+	ID         int64
+	UserID     int64
+	Date       string
+	Minutes    int
+	GrantedBy  string
+	Reason     string
+	ValidUntil *time.Time
+	RevokedAt  *time.Time
+	RevokedBy  string
+	CreatedAt  time.Time
+
+	// State is StateAt the store's clock when the extension was loaded
+	State string
+}
+
+// Extension states as reported by TimeExtension.StateAt
+const (
+	ExtensionActive  = "active"
+	ExtensionExpired = "expired"
+	ExtensionRevoked = "revoked"
+)
+
+// ErrExtensionRevoked is returned when revoking an extension twice
+var ErrExtensionRevoked = errors.New("extension has already been revoked")
+
+// StateAt reports whether the extension counts towards the limit at now,
+// matching activeExtensionClause
+func (e *TimeExtension) StateAt(now time.Time) string {
+	if e.RevokedAt != nil {
+		return ExtensionRevoked
+	}
+	if e.ValidUntil != nil && !e.ValidUntil.After(now) {
+		return ExtensionExpired
+	}
+	return ExtensionActive
 }
 
 // New creates a new storage instance and initializes the database
@@ -61,13 +96,14 @@ func New(dbPath string) (*Storage, error) {
 	_, err = db.Exec(`
 		PRAGMA foreign_keys = ON;
 		PRAGMA journal_mode = WAL;
+		PRAGMA busy_timeout = 5000;
 	`)
 	if err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to set pragmas: %w", err)
 	}
 
-	s := &Storage{db: db}
+	s := &Storage{db: db, clock: clock.System}
 	if err := s.migrate(); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
@@ -76,11 +112,52 @@ func New(dbPath string) (*Storage, error) {
 	return s, nil
 }
 
+// OpenReadOnly opens an existing database for reading only, without
+// creating or migrating it. Short-lived processes such as the PAM login
+// check use it so they never write behind the daemon's back.
+func OpenReadOnly(dbPath string) (*Storage, error) {
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+dbPath+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// The pragmas apply per connection
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(`
+		PRAGMA busy_timeout = 5000;
+		PRAGMA query_only = ON;
+	`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to set pragmas: %w", err)
+	}
+
+	return &Storage{db: db, clock: clock.System}, nil
+}
+
 // Close closes the database connection
 func (s *Storage) Close() error {
 	return s.db.Close()
 }
 
+// SetClock makes the date functions use c instead of the wall clock
+func (s *Storage) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+func (s *Storage) now() time.Time {
+	return s.clock.Now()
+}
+
+// today returns the current date as stored in the database
+func (s *Storage) today() string {
+	return s.now().Format("2006-01-02")
+}
+
 func (s *Storage) migrate() error {
 	schema := `
 		CREATE TABLE IF NOT EXISTS users (
@@ -118,12 +195,198 @@ func (s *Storage) migrate() error {
 			value TEXT NOT NULL
 		);
 
+		CREATE TABLE IF NOT EXISTS chores (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			name TEXT NOT NULL,
+			minutes_reward INTEGER NOT NULL,
+			daily_cap INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS chore_completions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chore_id INTEGER NOT NULL,
+			user_id INTEGER NOT NULL,
+			date TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			submitted_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			reviewed_at DATETIME,
+			FOREIGN KEY (chore_id) REFERENCES chores(id) ON DELETE CASCADE,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS time_requests (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			minutes INTEGER NOT NULL,
+			reason TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'pending',
+			granted_minutes INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			expires_at DATETIME NOT NULL,
+			decided_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS date_overrides (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER,
+			start_date TEXT NOT NULL,
+			end_date TEXT NOT NULL,
+			limit_mins INTEGER NOT NULL DEFAULT 0,
+			unlimited INTEGER NOT NULL DEFAULT 0,
+			label TEXT NOT NULL DEFAULT '',
+			source TEXT NOT NULL DEFAULT 'manual',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS profiles (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT UNIQUE NOT NULL,
+			weekday_limit_mins INTEGER NOT NULL DEFAULT 120,
+			weekend_limit_mins INTEGER NOT NULL DEFAULT 120,
+			windows TEXT NOT NULL DEFAULT '',
+			enforcement_mode TEXT NOT NULL DEFAULT 'lock',
+			warning_intervals TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS user_profiles (
+			user_id INTEGER PRIMARY KEY,
+			profile_id INTEGER NOT NULL,
+			until_date TEXT NOT NULL DEFAULT '',
+			revert_profile_id INTEGER,
+			set_by TEXT NOT NULL DEFAULT '',
+			set_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			FOREIGN KEY (profile_id) REFERENCES profiles(id) ON DELETE CASCADE,
+			FOREIGN KEY (revert_profile_id) REFERENCES profiles(id) ON DELETE SET NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS templates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT UNIQUE NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			weekday_limit_mins INTEGER NOT NULL DEFAULT 120,
+			weekend_limit_mins INTEGER NOT NULL DEFAULT 120,
+			windows TEXT NOT NULL DEFAULT '',
+			enforcement_mode TEXT NOT NULL DEFAULT 'lock',
+			warning_intervals TEXT NOT NULL DEFAULT '',
+			built_in INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS pauses (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			reason TEXT NOT NULL DEFAULT '',
+			paused_by TEXT NOT NULL DEFAULT '',
+			started_at DATETIME NOT NULL,
+			ends_at DATETIME,
+			resumed_at DATETIME,
+			resumed_by TEXT NOT NULL DEFAULT '',
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS usage_corrections (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			date TEXT NOT NULL,
+			old_seconds INTEGER NOT NULL,
+			new_seconds INTEGER NOT NULL,
+			note TEXT NOT NULL,
+			corrected_by TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS scheduled_actions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			action TEXT NOT NULL,
+			minutes INTEGER NOT NULL DEFAULT 0,
+			message TEXT NOT NULL DEFAULT '',
+			run_at DATETIME NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			error TEXT NOT NULL DEFAULT '',
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			executed_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS lock_failures (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			message TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			acknowledged_at DATETIME,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+		);
+
 		CREATE INDEX IF NOT EXISTS idx_usage_log_user_date ON usage_log(user_id, date);
 		CREATE INDEX IF NOT EXISTS idx_extensions_user_date ON time_extensions(user_id, date);
+		CREATE INDEX IF NOT EXISTS idx_chore_completions_chore_date ON chore_completions(chore_id, date);
+		CREATE INDEX IF NOT EXISTS idx_time_requests_status ON time_requests(status, expires_at);
+		CREATE INDEX IF NOT EXISTS idx_date_overrides_dates ON date_overrides(start_date, end_date);
+		CREATE INDEX IF NOT EXISTS idx_pauses_user ON pauses(user_id, resumed_at);
+		CREATE INDEX IF NOT EXISTS idx_usage_corrections_user_date ON usage_corrections(user_id, date);
+		CREATE INDEX IF NOT EXISTS idx_scheduled_actions_status ON scheduled_actions(status, run_at);
 	`
 
-	_, err := s.db.Exec(schema)
-	return err
+	if _, err := s.db.Exec(schema); err != nil {
+		return err
+	}
+
+	// Columns added after the initial schema. CREATE TABLE IF NOT EXISTS
+	// leaves existing tables untouched, so add them explicitly.
+	columns := []struct {
+		table, column, definition string
+	}{
+		{"time_extensions", "reason", "TEXT NOT NULL DEFAULT ''"},
+		{"time_extensions", "valid_until", "DATETIME"},
+		{"time_extensions", "revoked_at", "DATETIME"},
+		{"time_extensions", "revoked_by", "TEXT NOT NULL DEFAULT ''"},
+		{"users", "degraded_mode", "TEXT NOT NULL DEFAULT 'open'"},
+	}
+
+	for _, c := range columns {
+		if err := s.addColumn(c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	return s.seedTemplates()
+}
+
+// addColumn adds a column to a table unless it already exists
+func (s *Storage) addColumn(table, column, definition string) error {
+	rows, err := s.db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return fmt.Errorf("failed to inspect table %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("failed to inspect table %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	_, err = s.db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
 }
 
 // CreateUser creates a new user
@@ -144,10 +407,10 @@ func (s *Storage) CreateUser(username string, dailyLimitMins int) (*User, error)
 func (s *Storage) GetUserByID(id int64) (*User, error) {
 	user := &User{}
 	err := s.db.QueryRow(
-		`SELECT id, username, daily_limit_mins, enabled, created_at, updated_at 
+		`SELECT id, username, daily_limit_mins, enabled, degraded_mode, created_at, updated_at 
 		 FROM users WHERE id = ?`,
 		id,
-	).Scan(&user.ID, &user.Username, &user.DailyLimitMins, &user.Enabled, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Username, &user.DailyLimitMins, &user.Enabled, &user.DegradedMode, &user.CreatedAt, &user.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -163,10 +426,10 @@ func (s *Storage) GetUserByID(id int64) (*User, error) {
 func (s *Storage) GetUserByUsername(username string) (*User, error) {
 	user := &User{}
 	err := s.db.QueryRow(
-		`SELECT id, username, daily_limit_mins, enabled, created_at, updated_at 
+		`SELECT id, username, daily_limit_mins, enabled, degraded_mode, created_at, updated_at 
 		 FROM users WHERE username = ?`,
 		username,
-	).Scan(&user.ID, &user.Username, &user.DailyLimitMins, &user.Enabled, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Username, &user.DailyLimitMins, &user.Enabled, &user.DegradedMode, &user.CreatedAt, &user.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -181,7 +444,7 @@ func (s *Storage) GetUserByUsername(username string) (*User, error) {
 // ListUsers returns all users
 func (s *Storage) ListUsers() ([]*User, error) {
 	rows, err := s.db.Query(
-		`SELECT id, username, daily_limit_mins, enabled, created_at, updated_at 
+		`SELECT id, username, daily_limit_mins, enabled, degraded_mode, created_at, updated_at 
 		 FROM users ORDER BY username`,
 	)
 	if err != nil {
@@ -192,7 +455,7 @@ func (s *Storage) ListUsers() ([]*User, error) {
 	var users []*User
 	for rows.Next() {
 		user := &User{}
-		if err := rows.Scan(&user.ID, &user.Username, &user.DailyLimitMins, &user.Enabled, &user.CreatedAt, &user.UpdatedAt); err != nil {
+		if err := rows.Scan(&user.ID, &user.Username, &user.DailyLimitMins, &user.Enabled, &user.DegradedMode, &user.CreatedAt, &user.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan user: %w", err)
 		}
 		users = append(users, user)
@@ -211,6 +474,43 @@ func (s *Storage) UpdateUser(id int64, dailyLimitMins int, enabled bool) error {
 	return err
 }
 
+// Degraded modes decide what happens to a user while enforcement is
+// degraded, i.e. logind or the database cannot be reached
+const (
+	DegradedOpen   = "open"   // neither count nor lock
+	DegradedClosed = "closed" // lock the user's sessions
+	DegradedCount  = "count"  // keep counting the last known sessions
+)
+
+// ErrInvalidDegradedMode is returned for unknown degraded modes
+var ErrInvalidDegradedMode = errors.New("degraded mode must be open, closed or count")
+
+// ValidDegradedMode reports whether mode is a known degraded mode
+func ValidDegradedMode(mode string) bool {
+	switch mode {
+	case DegradedOpen, DegradedClosed, DegradedCount:
+		return true
+	}
+	return false
+}
+
+// SetUserDegradedMode sets what happens to a user while enforcement is
+// degraded
+func (s *Storage) SetUserDegradedMode(id int64, mode string) error {
+	if !ValidDegradedMode(mode) {
+		return ErrInvalidDegradedMode
+	}
+
+	_, err := s.db.Exec(
+		`UPDATE users SET degraded_mode = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		mode, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set degraded mode: %w", err)
+	}
+	return nil
+}
+
 // DeleteUser deletes a user
 func (s *Storage) DeleteUser(id int64) error {
 	_, err := s.db.Exec(`DELETE FROM users WHERE id = ?`, id)
@@ -219,7 +519,7 @@ func (s *Storage) DeleteUser(id int64) error {
 
 // AddUsageTime adds seconds to today's usage for a user
 func (s *Storage) AddUsageTime(userID int64, seconds int) error {
-	today := time.Now().Format("2006-01-02")
+	today := s.today()
 
 	_, err := s.db.Exec(
 		`INSERT INTO usage_log (user_id, date, used_seconds) 
@@ -234,7 +534,7 @@ func (s *Storage) AddUsageTime(userID int64, seconds int) error {
 
 // GetTodayUsageSeconds returns the number of seconds used today
 func (s *Storage) GetTodayUsageSeconds(userID int64) (int, error) {
-	today := time.Now().Format("2006-01-02")
+	today := s.today()
 
 	var seconds int
 	err := s.db.QueryRow(
@@ -251,7 +551,7 @@ func (s *Storage) GetTodayUsageSeconds(userID int64) (int, error) {
 
 // GetUsageHistory returns usage records for a user over the past N days
 func (s *Storage) GetUsageHistory(userID int64, days int) ([]*UsageRecord, error) {
-	startDate := time.Now().AddDate(0, 0, -days).Format("2006-01-02")
+	startDate := s.now().AddDate(0, 0, -days).Format("2006-01-02")
 
 	rows, err := s.db.Query(
 		`SELECT id, user_id, date, used_seconds, created_at, updated_at 
@@ -277,24 +577,80 @@ func (s *Storage) GetUsageHistory(userID int64, days int) ([]*UsageRecord, error
 
 // AddTimeExtension adds a time extension for a user
 func (s *Storage) AddTimeExtension(userID int64, minutes int, grantedBy string) error {
-	today := time.Now().Format("2006-01-02")
+	_, err := s.GrantTimeExtension(userID, minutes, grantedBy, "", nil)
+	return err
+}
 
-	_, err := s.db.Exec(
-		`INSERT INTO time_extensions (user_id, date, minutes, granted_by) 
-		 VALUES (?, ?, ?, ?)`,
-		userID, today, minutes, grantedBy,
+// GrantTimeExtension adds a time extension for today with a reason and an
+// optional time after which the extra minutes no longer count
+func (s *Storage) GrantTimeExtension(userID int64, minutes int, grantedBy, reason string, validUntil *time.Time) (*TimeExtension, error) {
+	today := s.today()
+
+	var until interface{}
+	if validUntil != nil {
+		until = dbTime(*validUntil)
+	}
+
+	result, err := s.db.Exec(
+		`INSERT INTO time_extensions (user_id, date, minutes, granted_by, reason, valid_until) 
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		userID, today, minutes, grantedBy, reason, until,
 	)
-	return err
+	if err != nil {
+		return nil, fmt.Errorf("failed to add time extension: %w", err)
+	}
+
+	id, _ := result.LastInsertId()
+	return s.GetTimeExtension(id)
 }
 
-// GetTodayExtensions returns total extension minutes for today
+// activeExtensionClause restricts time_extensions to rows that have not been
+// revoked and have not passed their valid-until time
+const activeExtensionClause = `revoked_at IS NULL AND (valid_until IS NULL OR valid_until > ?)`
+
+// GetTodayExtensions returns total active extension minutes for today
 func (s *Storage) GetTodayExtensions(userID int64) (int, error) {
-	today := time.Now().Format("2006-01-02")
+	today := s.today()
 
 	var minutes int
 	err := s.db.QueryRow(
 		`SELECT COALESCE(SUM(minutes), 0) FROM time_extensions 
-		 WHERE user_id = ? AND date = ?`,
+		 WHERE user_id = ? AND date = ? AND minutes > 0 AND `+activeExtensionClause,
+		userID, today, dbTime(s.now()),
+	).Scan(&minutes)
+
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return minutes, err
+}
+
+// AddTimeDeduction takes minutes away from a user's allowance on the given
+// date (YYYY-MM-DD), which may be today or in the future. A reason is required.
+func (s *Storage) AddTimeDeduction(userID int64, minutes int, reason, date, grantedBy string) error {
+	if minutes <= 0 {
+		return fmt.Errorf("deduction must be a positive number of minutes")
+	}
+	if reason == "" {
+		return fmt.Errorf("deduction requires a reason")
+	}
+
+	_, err := s.db.Exec(
+		`INSERT INTO time_extensions (user_id, date, minutes, granted_by, reason) 
+		 VALUES (?, ?, ?, ?, ?)`,
+		userID, date, -minutes, grantedBy, reason,
+	)
+	return err
+}
+
+// GetTodayDeductions returns total deducted minutes for today as a positive number
+func (s *Storage) GetTodayDeductions(userID int64) (int, error) {
+	today := s.today()
+
+	var minutes int
+	err := s.db.QueryRow(
+		`SELECT COALESCE(-SUM(minutes), 0) FROM time_extensions 
+		 WHERE user_id = ? AND date = ? AND minutes < 0 AND revoked_at IS NULL`,
 		userID, today,
 	).Scan(&minutes)
 
@@ -304,6 +660,106 @@ func (s *Storage) GetTodayExtensions(userID int64) (int, error) {
 	return minutes, err
 }
 
+// ListDeductions returns a user's deductions from the given date onwards,
+// including ones scheduled for future days
+func (s *Storage) ListDeductions(userID int64, fromDate string) ([]*TimeExtension, error) {
+	rows, err := s.db.Query(
+		`SELECT `+timeExtensionColumns+` FROM time_extensions 
+		 WHERE user_id = ? AND date >= ? AND minutes < 0 AND revoked_at IS NULL 
+		 ORDER BY date, id`,
+		userID, fromDate,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deductions: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanTimeExtensions(rows)
+}
+
+// ListTodayExtensions returns all of today's extensions and deductions for a
+// user, including revoked and expired ones
+func (s *Storage) ListTodayExtensions(userID int64) ([]*TimeExtension, error) {
+	today := s.today()
+
+	rows, err := s.db.Query(
+		`SELECT `+timeExtensionColumns+` FROM time_extensions 
+		 WHERE user_id = ? AND date = ? ORDER BY id`,
+		userID, today,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list extensions: %w", err)
+	}
+	defer rows.Close()
+
+	return s.scanTimeExtensions(rows)
+}
+
+// GetTimeExtension retrieves an extension by ID
+func (s *Storage) GetTimeExtension(id int64) (*TimeExtension, error) {
+	rows, err := s.db.Query(
+		`SELECT `+timeExtensionColumns+` FROM time_extensions WHERE id = ?`,
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get extension: %w", err)
+	}
+	defer rows.Close()
+
+	extensions, err := s.scanTimeExtensions(rows)
+	if err != nil || len(extensions) == 0 {
+		return nil, err
+	}
+	return extensions[0], nil
+}
+
+// RevokeTimeExtension withdraws an extension or deduction so it no longer counts
+func (s *Storage) RevokeTimeExtension(id int64, revokedBy string) error {
+	result, err := s.db.Exec(
+		`UPDATE time_extensions SET revoked_at = ?, revoked_by = ? 
+		 WHERE id = ? AND revoked_at IS NULL`,
+		dbTime(s.now()), revokedBy, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke extension: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrExtensionRevoked
+	}
+	return nil
+}
+
+const timeExtensionColumns = `id, user_id, date, minutes, granted_by, reason, valid_until, revoked_at, revoked_by, created_at`
+
+func (s *Storage) scanTimeExtensions(rows *sql.Rows) ([]*TimeExtension, error) {
+	now := s.now()
+	var extensions []*TimeExtension
+	for rows.Next() {
+		ext := &TimeExtension{}
+		var validUntil, revokedAt sql.NullTime
+		if err := rows.Scan(&ext.ID, &ext.UserID, &ext.Date, &ext.Minutes, &ext.GrantedBy, &ext.Reason,
+			&validUntil, &revokedAt, &ext.RevokedBy, &ext.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan extension: %w", err)
+		}
+		if validUntil.Valid {
+			ext.ValidUntil = &validUntil.Time
+		}
+		if revokedAt.Valid {
+			ext.RevokedAt = &revokedAt.Time
+		}
+		ext.State = ext.StateAt(now)
+		extensions = append(extensions, ext)
+	}
+
+	return extensions, rows.Err()
+}
+
+// dbTime normalizes a timestamp to whole seconds in UTC so stored values
+// compare correctly as text
+func dbTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Second)
+}
+
 // GetSetting retrieves a setting value
 func (s *Storage) GetSetting(key string) (string, error) {
 	var value string
@@ -324,13 +780,33 @@ func (s *Storage) SetSetting(key, value string) error {
 	return err
 }
 
-// GetRemainingMinutes calculates remaining minutes for a user today
+// GetRemainingMinutes returns the remaining time for a user today in whole
+// minutes, rounded up so a user with seconds left still sees 1 minute
 func (s *Storage) GetRemainingMinutes(userID int64) (int, error) {
+	seconds, err := s.GetRemainingSeconds(userID)
+	if err != nil {
+		return 0, err
+	}
+	return MinutesRoundedUp(seconds), nil
+}
+
+// GetRemainingSeconds calculates remaining seconds for a user today, taking
+// date overrides into account. Unlimited days report UnlimitedSeconds.
+func (s *Storage) GetRemainingSeconds(userID int64) (int, error) {
 	user, err := s.GetUserByID(userID)
 	if err != nil || user == nil {
 		return 0, err
 	}
 
+	policy, err := s.GetTodayPolicy(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	if policy.Unlimited {
+		return UnlimitedSeconds, nil
+	}
+
 	usedSeconds, err := s.GetTodayUsageSeconds(userID)
 	if err != nil {
 		return 0, err
@@ -341,9 +817,13 @@ func (s *Storage) GetRemainingMinutes(userID int64) (int, error) {
 		return 0, err
 	}
 
-	totalLimitMins := user.DailyLimitMins + extensions
-	usedMins := usedSeconds / 60
-	remaining := totalLimitMins - usedMins
+	deductions, err := s.GetTodayDeductions(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	totalLimitMins := policy.LimitMins + extensions - deductions
+	remaining := totalLimitMins*60 - usedSeconds
 
 	if remaining < 0 {
 		return 0, nil
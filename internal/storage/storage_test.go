@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"github.com/florian/screentime-guardian/internal/clock"
 )
 
 func TestNew(t *testing.T) {
@@ -22,6 +24,40 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestOpenReadOnly(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	if _, err := OpenReadOnly(dbPath); err == nil {
+		t.Fatal("Expected an error opening a missing database")
+	}
+	if _, err := os.Stat(dbPath); !os.IsNotExist(err) {
+		t.Error("Expected no database to be created")
+	}
+
+	// The daemon keeps its connection open meanwhile
+	store, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+	user, _ := store.CreateUser("kid", 60)
+	store.AddUsageTime(user.ID, 600)
+
+	readOnly, err := OpenReadOnly(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open read-only: %v", err)
+	}
+	defer readOnly.Close()
+
+	if remaining, err := readOnly.GetRemainingSeconds(user.ID); err != nil || remaining != 50*60 {
+		t.Errorf("Expected 50 minutes remaining, got %d, %v", remaining, err)
+	}
+	if err := readOnly.AddUsageTime(user.ID, 60); err == nil {
+		t.Error("Expected writes to fail")
+	}
+}
+
 func TestCreateAndGetUser(t *testing.T) {
 	tmpDir := t.TempDir()
 	store, err := New(filepath.Join(tmpDir, "test.db"))
@@ -122,6 +158,32 @@ func TestUpdateUser(t *testing.T) {
 	}
 }
 
+func TestSetUserDegradedMode(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("testuser", 120)
+	if user.DegradedMode != DegradedOpen {
+		t.Errorf("Expected degraded mode %q by default, got %q", DegradedOpen, user.DegradedMode)
+	}
+
+	if err := store.SetUserDegradedMode(user.ID, DegradedClosed); err != nil {
+		t.Fatalf("Failed to set degraded mode: %v", err)
+	}
+	users, _ := store.ListUsers()
+	if len(users) != 1 || users[0].DegradedMode != DegradedClosed {
+		t.Errorf("Expected degraded mode %q, got %+v", DegradedClosed, users)
+	}
+
+	if err := store.SetUserDegradedMode(user.ID, "sometimes"); err != ErrInvalidDegradedMode {
+		t.Errorf("Expected ErrInvalidDegradedMode, got %v", err)
+	}
+}
+
 func TestDeleteUser(t *testing.T) {
 	tmpDir := t.TempDir()
 	store, err := New(filepath.Join(tmpDir, "test.db"))
@@ -248,6 +310,37 @@ func TestGetRemainingMinutes(t *testing.T) {
 	}
 }
 
+func TestGetRemainingSeconds(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("testuser", 10)
+
+	store.AddUsageTime(user.ID, 9*60+30)
+
+	remaining, err := store.GetRemainingSeconds(user.ID)
+	if err != nil {
+		t.Fatalf("Failed to get remaining seconds: %v", err)
+	}
+	if remaining != 30 {
+		t.Errorf("Expected 30 seconds remaining, got %d", remaining)
+	}
+
+	// Partial minutes round up so the user still sees time left
+	if mins, _ := store.GetRemainingMinutes(user.ID); mins != 1 {
+		t.Errorf("Expected 1 minute remaining, got %d", mins)
+	}
+
+	store.AddUsageTime(user.ID, 45)
+	if remaining, _ := store.GetRemainingSeconds(user.ID); remaining != 0 {
+		t.Errorf("Expected 0 seconds remaining, got %d", remaining)
+	}
+}
+
 func TestUsageHistory(t *testing.T) {
 	tmpDir := t.TempDir()
 	store, err := New(filepath.Join(tmpDir, "test.db"))
@@ -332,3 +425,169 @@ func TestDuplicateUsername(t *testing.T) {
 		t.Error("Expected error when creating duplicate username, got nil")
 	}
 }
+
+func TestTimeDeductions(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("testuser", 120)
+	today := time.Now().Format("2006-01-02")
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+
+	if err := store.AddTimeDeduction(user.ID, 30, "", today, "parent"); err == nil {
+		t.Error("Expected error for deduction without reason")
+	}
+
+	if err := store.AddTimeDeduction(user.ID, 30, "broke a rule", today, "parent"); err != nil {
+		t.Fatalf("Failed to add deduction: %v", err)
+	}
+
+	if err := store.AddTimeDeduction(user.ID, 20, "no homework", tomorrow, "parent"); err != nil {
+		t.Fatalf("Failed to add future deduction: %v", err)
+	}
+
+	store.AddTimeExtension(user.ID, 15, "parent")
+
+	extensions, _ := store.GetTodayExtensions(user.ID)
+	if extensions != 15 {
+		t.Errorf("Expected deductions not to count as extensions, got %d", extensions)
+	}
+
+	deductions, _ := store.GetTodayDeductions(user.ID)
+	if deductions != 30 {
+		t.Errorf("Expected 30 minutes deducted today, got %d", deductions)
+	}
+
+	remaining, _ := store.GetRemainingMinutes(user.ID)
+	if remaining != 105 {
+		t.Errorf("Expected 105 minutes remaining (120 + 15 - 30), got %d", remaining)
+	}
+
+	upcoming, err := store.ListDeductions(user.ID, today)
+	if err != nil {
+		t.Fatalf("Failed to list deductions: %v", err)
+	}
+
+	if len(upcoming) != 2 || upcoming[1].Date != tomorrow || upcoming[1].Reason != "no homework" {
+		t.Errorf("Expected today's and tomorrow's deductions, got %v", upcoming)
+	}
+}
+
+func TestMigrateAddsColumns(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	store, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	// Simulate a database created before the reason column existed
+	if _, err := store.db.Exec(`ALTER TABLE time_extensions DROP COLUMN reason`); err != nil {
+		t.Fatalf("Failed to drop column: %v", err)
+	}
+	store.Close()
+
+	store, err = New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("testuser", 120)
+	if err := store.AddTimeDeduction(user.ID, 10, "late for dinner", time.Now().Format("2006-01-02"), "parent"); err != nil {
+		t.Errorf("Expected reason column to be re-added, got %v", err)
+	}
+}
+
+func TestExtensionExpiryAndRevocation(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	user, _ := store.CreateUser("testuser", 60)
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	expired, err := store.GrantTimeExtension(user.ID, 10, "admin", "was valid until a minute ago", &past)
+	if err != nil {
+		t.Fatalf("Failed to grant extension: %v", err)
+	}
+
+	limited, err := store.GrantTimeExtension(user.ID, 30, "admin", "homework project", &future)
+	if err != nil {
+		t.Fatalf("Failed to grant extension: %v", err)
+	}
+
+	if expired.State != ExtensionExpired {
+		t.Errorf("Expected expired state, got %s", expired.State)
+	}
+
+	if limited.State != ExtensionActive || limited.Reason != "homework project" || limited.GrantedBy != "admin" {
+		t.Errorf("Unexpected extension: %+v", limited)
+	}
+
+	extensions, _ := store.GetTodayExtensions(user.ID)
+	if extensions != 30 {
+		t.Errorf("Expected only the unexpired 30 minutes to count, got %d", extensions)
+	}
+
+	if err := store.RevokeTimeExtension(limited.ID, "admin"); err != nil {
+		t.Fatalf("Failed to revoke extension: %v", err)
+	}
+
+	if err := store.RevokeTimeExtension(limited.ID, "admin"); err != ErrExtensionRevoked {
+		t.Errorf("Expected ErrExtensionRevoked on second revoke, got %v", err)
+	}
+
+	remaining, _ := store.GetRemainingMinutes(user.ID)
+	if remaining != 60 {
+		t.Errorf("Expected 60 minutes remaining after revocation, got %d", remaining)
+	}
+
+	all, _ := store.ListTodayExtensions(user.ID)
+	if len(all) != 2 || all[1].State != ExtensionRevoked || all[1].RevokedBy != "admin" {
+		t.Errorf("Expected revoked extension in today's list, got %+v", all)
+	}
+}
+
+func TestExtensionStateFollowsClock(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	c := clock.NewFake(time.Now())
+	store.SetClock(c)
+
+	user, _ := store.CreateUser("testuser", 60)
+	until := c.Now().Add(30 * time.Minute)
+	ext, err := store.GrantTimeExtension(user.ID, 15, "admin", "", &until)
+	if err != nil {
+		t.Fatalf("Failed to grant extension: %v", err)
+	}
+	if ext.State != ExtensionActive {
+		t.Errorf("Expected active extension, got %s", ext.State)
+	}
+
+	// Past valid_until on the store's clock, though not on the wall clock
+	c.Advance(time.Hour)
+
+	all, _ := store.ListTodayExtensions(user.ID)
+	if len(all) != 1 || all[0].State != ExtensionExpired {
+		t.Errorf("Expected the extension to have expired on the store's clock, got %+v", all)
+	}
+	if minutes, _ := store.GetTodayExtensions(user.ID); minutes != 0 {
+		t.Errorf("Expected the expired extension not to count, got %d", minutes)
+	}
+}
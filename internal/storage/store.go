@@ -0,0 +1,111 @@
+package storage
+
+import "time"
+
+// Store is the part of Storage that the scheduler, the web API and the
+// calendar importer use, so that they can run against other implementations
+// in tests
+type Store interface {
+	// Users
+	CreateUser(username string, dailyLimitMins int) (*User, error)
+	GetUserByID(id int64) (*User, error)
+	GetUserByUsername(username string) (*User, error)
+	ListUsers() ([]*User, error)
+	UpdateUser(id int64, dailyLimitMins int, enabled bool) error
+	SetUserDegradedMode(id int64, mode string) error
+	DeleteUser(id int64) error
+
+	// Usage and remaining time
+	AddUsageTime(userID int64, seconds int) error
+	GetTodayUsageSeconds(userID int64) (int, error)
+	GetUsageHistory(userID int64, days int) ([]*UsageRecord, error)
+	GetRemainingMinutes(userID int64) (int, error)
+	GetRemainingSeconds(userID int64) (int, error)
+	AdjustUsageSeconds(userID int64, date string, delta int, note, correctedBy string) (*UsageCorrection, error)
+	SetUsageSeconds(userID int64, date string, seconds int, note, correctedBy string) (*UsageCorrection, error)
+	ListUsageCorrections(userID int64, fromDate string) ([]*UsageCorrection, error)
+
+	// Extensions and deductions
+	GrantTimeExtension(userID int64, minutes int, grantedBy, reason string, validUntil *time.Time) (*TimeExtension, error)
+	GetTimeExtension(id int64) (*TimeExtension, error)
+	RevokeTimeExtension(id int64, revokedBy string) error
+	GetTodayExtensions(userID int64) (int, error)
+	ListTodayExtensions(userID int64) ([]*TimeExtension, error)
+	AddTimeDeduction(userID int64, minutes int, reason, date, grantedBy string) error
+	GetTodayDeductions(userID int64) (int, error)
+	ListDeductions(userID int64, fromDate string) ([]*TimeExtension, error)
+
+	// Settings and the household budget
+	GetSetting(key string) (string, error)
+	SetSetting(key, value string) error
+	GetHouseholdBudget() (*HouseholdBudget, error)
+	GetHouseholdBudgetMins() (int, error)
+	SetHouseholdBudgetMins(mins int) error
+
+	// Policies and date overrides
+	GetPolicy(userID int64, date string) (*Policy, error)
+	GetTodayPolicy(userID int64) (*Policy, error)
+	CreateDateOverride(o *DateOverride) (*DateOverride, error)
+	DeleteDateOverride(id int64) error
+	ListDateOverrides(fromDate string) ([]*DateOverride, error)
+	ListDateOverridesBySource(source string) ([]*DateOverride, error)
+	ListUserDateOverrides(userID int64, fromDate string) ([]*DateOverride, error)
+	ReplaceDateOverrides(source string, overrides []*DateOverride) error
+
+	// Profiles and templates
+	CreateProfile(p *Profile) (*Profile, error)
+	GetProfile(id int64) (*Profile, error)
+	ListProfiles() ([]*Profile, error)
+	UpdateProfile(p *Profile) error
+	DeleteProfile(id int64) error
+	GetUserProfile(userID int64) (*ProfileAssignment, error)
+	SetUserProfile(userID, profileID int64, untilDate, setBy string) error
+	RevertExpiredProfiles(today string) ([]*ProfileAssignment, error)
+	CreateUserFromTemplate(username string, templateID int64) (*User, error)
+	GetTemplate(id int64) (*Template, error)
+	ListTemplates() ([]*Template, error)
+	SaveProfileAsTemplate(profileID int64, name, description string) (*Template, error)
+	DeleteTemplate(id int64) error
+
+	// Pauses
+	PauseUser(userID int64, d time.Duration, reason, pausedBy string) (*Pause, error)
+	ResumeUser(userID int64, resumedBy string) (*Pause, error)
+	GetActivePause(userID int64, t time.Time) (*Pause, error)
+	ListPauses(userID int64, limit int) ([]*Pause, error)
+	ExpirePauses(now time.Time) ([]*Pause, error)
+
+	// Chores
+	CreateChore(userID int64, name string, minutesReward, dailyCap int) (*Chore, error)
+	GetChore(id int64) (*Chore, error)
+	ListChores(userID int64) ([]*Chore, error)
+	DeleteChore(id int64) error
+	SubmitChoreCompletion(choreID int64) (*ChoreCompletion, error)
+	ApproveChoreCompletion(id int64) (*ChoreCompletion, error)
+	RejectChoreCompletion(id int64) error
+	ListPendingChoreCompletions() ([]*ChoreCompletion, error)
+
+	// Time requests
+	CreateTimeRequest(userID int64, minutes int, reason string, timeout time.Duration) (*TimeRequest, error)
+	GetTimeRequest(id int64) (*TimeRequest, error)
+	ApproveTimeRequest(id int64, minutes int, grantedBy string) (*TimeRequest, error)
+	DenyTimeRequest(id int64) (*TimeRequest, error)
+	ListPendingTimeRequests() ([]*TimeRequest, error)
+	ExpireTimeRequests(now time.Time) ([]*TimeRequest, error)
+
+	// Scheduled actions
+	ScheduleAction(userID int64, action string, runAt time.Time, minutes int, message, createdBy string) (*ScheduledAction, error)
+	GetScheduledAction(id int64) (*ScheduledAction, error)
+	ListScheduledActions(userID int64, since time.Time) ([]*ScheduledAction, error)
+	CancelScheduledAction(id int64) error
+	DueScheduledActions(now time.Time) ([]*ScheduledAction, error)
+	ClaimScheduledAction(id int64) (bool, error)
+	FinishScheduledAction(id int64, runErr error) error
+	FailInterruptedActions() (int, error)
+
+	// Lock failures
+	RecordLockFailure(userID int64, message string) (*LockFailure, error)
+	ListLockFailures() ([]*LockFailure, error)
+	AcknowledgeLockFailure(id int64) error
+}
+
+var _ Store = (*Storage)(nil)
@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBuiltInTemplate is returned when deleting one of the built-in templates
+var ErrBuiltInTemplate = errors.New("built-in templates cannot be deleted")
+
+// Template is a starting point for a new child's rules. Built-in templates
+// cover common age groups; parents can save their own from any profile.
+type Template struct {
+	ID               int64
+	Name             string
+	Description      string
+	WeekdayLimitMins int
+	WeekendLimitMins int
+	Windows          []Window
+	EnforcementMode  string
+	WarningIntervals []int
+	BuiltIn          bool
+	CreatedAt        time.Time
+}
+
+// WindowsString formats the template's windows for display
+func (t *Template) WindowsString() string {
+	return FormatWindows(t.Windows)
+}
+
+// IntervalsString formats the template's warning intervals for display
+func (t *Template) IntervalsString() string {
+	return FormatIntervals(t.WarningIntervals)
+}
+
+// builtinTemplates are seeded on every start; existing rows are left alone
+var builtinTemplates = []struct {
+	name, description, windows, intervals string
+	weekday, weekend                      int
+}{
+	{"Ages 6–8", "Short sessions in the afternoon, nothing before school or after dinner", "15:00-19:00", "10,5,1", 60, 120},
+	{"Ages 9–12", "Afternoons and early evenings, more on weekends", "15:00-20:00", "10,5,1", 90, 180},
+	{"Teen", "Generous limits with a late bedtime and early warnings", "07:00-22:00", "15,5,1", 180, 300},
+}
+
+func (s *Storage) seedTemplates() error {
+	for _, t := range builtinTemplates {
+		_, err := s.db.Exec(
+			`INSERT OR IGNORE INTO templates (name, description, weekday_limit_mins, weekend_limit_mins,
+			 windows, enforcement_mode, warning_intervals, built_in)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, 1)`,
+			t.name, t.description, t.weekday, t.weekend, t.windows, EnforcementLock, t.intervals,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to seed template %q: %w", t.name, err)
+		}
+	}
+	return nil
+}
+
+const templateSelect = `SELECT id, name, description, weekday_limit_mins, weekend_limit_mins, windows,
+	enforcement_mode, warning_intervals, built_in, created_at FROM templates`
+
+func scanTemplates(rows *sql.Rows) ([]*Template, error) {
+	var templates []*Template
+	for rows.Next() {
+		t := &Template{}
+		var windows, intervals string
+		if err := rows.Scan(&t.ID, &t.Name, &t.Description, &t.WeekdayLimitMins, &t.WeekendLimitMins,
+			&windows, &t.EnforcementMode, &intervals, &t.BuiltIn, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan template: %w", err)
+		}
+
+		var err error
+		if t.Windows, err = ParseWindows(windows); err != nil {
+			return nil, fmt.Errorf("template %q: %w", t.Name, err)
+		}
+		if t.WarningIntervals, err = ParseIntervals(intervals); err != nil {
+			return nil, fmt.Errorf("template %q: %w", t.Name, err)
+		}
+
+		templates = append(templates, t)
+	}
+
+	return templates, rows.Err()
+}
+
+// ListTemplates returns the built-in templates followed by the parents' own
+func (s *Storage) ListTemplates() ([]*Template, error) {
+	rows, err := s.db.Query(templateSelect + ` ORDER BY built_in DESC, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list templates: %w", err)
+	}
+	defer rows.Close()
+
+	return scanTemplates(rows)
+}
+
+// GetTemplate retrieves a template by ID
+func (s *Storage) GetTemplate(id int64) (*Template, error) {
+	rows, err := s.db.Query(templateSelect+` WHERE id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get template: %w", err)
+	}
+	defer rows.Close()
+
+	templates, err := scanTemplates(rows)
+	if err != nil || len(templates) == 0 {
+		return nil, err
+	}
+	return templates[0], nil
+}
+
+// SaveProfileAsTemplate stores a copy of a profile's rules as a reusable
+// template
+func (s *Storage) SaveProfileAsTemplate(profileID int64, name, description string) (*Template, error) {
+	profile, err := s.GetProfile(profileID)
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		return nil, fmt.Errorf("profile %d not found", profileID)
+	}
+
+	if name == "" {
+		name = profile.Name
+	}
+
+	result, err := s.db.Exec(
+		`INSERT INTO templates (name, description, weekday_limit_mins, weekend_limit_mins,
+		 windows, enforcement_mode, warning_intervals)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		name, description, profile.WeekdayLimitMins, profile.WeekendLimitMins,
+		FormatWindows(profile.Windows), profile.EnforcementMode, FormatIntervals(profile.WarningIntervals),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save template: %w", err)
+	}
+
+	id, _ := result.LastInsertId()
+	return s.GetTemplate(id)
+}
+
+// DeleteTemplate removes a parent's own template
+func (s *Storage) DeleteTemplate(id int64) error {
+	t, err := s.GetTemplate(id)
+	if err != nil || t == nil {
+		return err
+	}
+	if t.BuiltIn {
+		return ErrBuiltInTemplate
+	}
+
+	_, err = s.db.Exec(`DELETE FROM templates WHERE id = ?`, id)
+	return err
+}
+
+// CreateUserFromTemplate creates a user together with a profile of their own,
+// named after them and copied from the template, and puts them on it. If a
+// profile of that name exists already, the template's name and then a
+// number are added to keep the name unique.
+func (s *Storage) CreateUserFromTemplate(username string, templateID int64) (*User, error) {
+	t, err := s.GetTemplate(templateID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, fmt.Errorf("template %d not found", templateID)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`INSERT INTO users (username, daily_limit_mins) VALUES (?, ?)`,
+		username, t.WeekdayLimitMins,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	userID, _ := result.LastInsertId()
+
+	profileName, err := uniqueProfileName(tx, username, t.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err = tx.Exec(
+		`INSERT INTO profiles (name, weekday_limit_mins, weekend_limit_mins, windows, enforcement_mode, warning_intervals)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		profileName, t.WeekdayLimitMins, t.WeekendLimitMins, FormatWindows(t.Windows),
+		t.EnforcementMode, FormatIntervals(t.WarningIntervals),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create profile: %w", err)
+	}
+	profileID, _ := result.LastInsertId()
+
+	_, err = tx.Exec(
+		`INSERT INTO user_profiles (user_id, profile_id, set_by) VALUES (?, ?, ?)`,
+		userID, profileID, "template:"+t.Name,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assign profile: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit user: %w", err)
+	}
+
+	return s.GetUserByID(userID)
+}
+
+// uniqueProfileName returns username if no profile has that name yet,
+// otherwise "username (template)", numbered if that is taken too
+func uniqueProfileName(tx *sql.Tx, username, template string) (string, error) {
+	name := username
+	for n := 1; ; n++ {
+		var taken int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM profiles WHERE name = ?`, name).Scan(&taken); err != nil {
+			return "", fmt.Errorf("failed to check profile name: %w", err)
+		}
+		if taken == 0 {
+			return name, nil
+		}
+
+		name = fmt.Sprintf("%s (%s)", username, template)
+		if n > 1 {
+			name = fmt.Sprintf("%s (%s) %d", username, template, n)
+		}
+	}
+}
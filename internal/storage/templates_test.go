@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestBuiltinTemplates(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+	store, err := New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+
+	templates, err := store.ListTemplates()
+	if err != nil {
+		t.Fatalf("ListTemplates failed: %v", err)
+	}
+	if len(templates) != len(builtinTemplates) {
+		t.Fatalf("Expected %d built-in templates, got %d", len(builtinTemplates), len(templates))
+	}
+	for _, tmpl := range templates {
+		if !tmpl.BuiltIn || len(tmpl.Windows) == 0 || len(tmpl.WarningIntervals) == 0 {
+			t.Errorf("Incomplete built-in template: %+v", tmpl)
+		}
+	}
+
+	if err := store.DeleteTemplate(templates[0].ID); err != ErrBuiltInTemplate {
+		t.Errorf("Expected ErrBuiltInTemplate, got %v", err)
+	}
+	store.Close()
+
+	// Reopening must not duplicate the seeded templates
+	store, err = New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to reopen storage: %v", err)
+	}
+	defer store.Close()
+
+	templates, _ = store.ListTemplates()
+	if len(templates) != len(builtinTemplates) {
+		t.Errorf("Expected %d templates after reopening, got %d", len(builtinTemplates), len(templates))
+	}
+}
+
+func TestCreateUserFromTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	templates, _ := store.ListTemplates()
+	tmpl := templates[1]
+
+	user, err := store.CreateUserFromTemplate("alice", tmpl.ID)
+	if err != nil {
+		t.Fatalf("CreateUserFromTemplate failed: %v", err)
+	}
+	if user.DailyLimitMins != tmpl.WeekdayLimitMins {
+		t.Errorf("Expected daily limit %d, got %d", tmpl.WeekdayLimitMins, user.DailyLimitMins)
+	}
+
+	policy, _ := store.GetPolicy(user.ID, "2024-12-21") // Saturday
+	if policy.Profile == nil || policy.Profile.Name != "alice" {
+		t.Fatalf("Expected alice to be on her own profile, got %+v", policy.Profile)
+	}
+	if policy.LimitMins != tmpl.WeekendLimitMins || policy.Profile.WindowsString() != tmpl.WindowsString() {
+		t.Errorf("Expected profile copied from template, got %+v", policy.Profile)
+	}
+
+	// A failed creation must not leave a half-created user behind
+	if _, err := store.CreateUserFromTemplate("alice", tmpl.ID); err == nil {
+		t.Error("Expected duplicate username to fail")
+	}
+	users, _ := store.ListUsers()
+	if len(users) != 1 {
+		t.Errorf("Expected 1 user, got %d", len(users))
+	}
+
+	// Tuned profiles can be saved as templates of their own
+	policy.Profile.WeekendLimitMins = 200
+	store.UpdateProfile(policy.Profile)
+
+	custom, err := store.SaveProfileAsTemplate(policy.Profile.ID, "Alice's rules", "Tuned for alice")
+	if err != nil {
+		t.Fatalf("SaveProfileAsTemplate failed: %v", err)
+	}
+	if custom.BuiltIn || custom.WeekendLimitMins != 200 || custom.WindowsString() != tmpl.WindowsString() {
+		t.Errorf("Unexpected custom template: %+v", custom)
+	}
+
+	bob, err := store.CreateUserFromTemplate("bob", custom.ID)
+	if err != nil {
+		t.Fatalf("Failed to create user from custom template: %v", err)
+	}
+	policy, _ = store.GetPolicy(bob.ID, "2024-12-21")
+	if policy.LimitMins != 200 {
+		t.Errorf("Expected bob's weekend limit 200, got %d", policy.LimitMins)
+	}
+
+	if err := store.DeleteTemplate(custom.ID); err != nil {
+		t.Errorf("Failed to delete custom template: %v", err)
+	}
+}
+
+func TestCreateUserFromTemplateProfileNameTaken(t *testing.T) {
+	tmpDir := t.TempDir()
+	store, err := New(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	templates, _ := store.ListTemplates()
+	tmpl := templates[0]
+
+	// Profiles that happen to be called like the new users
+	store.CreateProfile(&Profile{Name: "weekend", WeekdayLimitMins: 60, WeekendLimitMins: 60})
+	store.CreateProfile(&Profile{Name: "ben", WeekdayLimitMins: 60, WeekendLimitMins: 60})
+	store.CreateProfile(&Profile{Name: "ben (" + tmpl.Name + ")", WeekdayLimitMins: 60, WeekendLimitMins: 60})
+
+	tests := []struct {
+		username string
+		profile  string
+	}{
+		{"weekend", "weekend (" + tmpl.Name + ")"},
+		{"ben", "ben (" + tmpl.Name + ") 2"},
+	}
+
+	for _, tt := range tests {
+		user, err := store.CreateUserFromTemplate(tt.username, tmpl.ID)
+		if err != nil {
+			t.Fatalf("CreateUserFromTemplate(%q) failed: %v", tt.username, err)
+		}
+		assignment, _ := store.GetUserProfile(user.ID)
+		if assignment == nil || assignment.ProfileName != tt.profile {
+			t.Errorf("Expected %s on profile %q, got %+v", tt.username, tt.profile, assignment)
+		}
+	}
+}